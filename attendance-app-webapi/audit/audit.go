@@ -0,0 +1,197 @@
+// Package audit records who created, updated or deleted which entity through the API
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"attendance-app/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Entity types recorded in the audit log
+const (
+	EntityUser                 = "user"
+	EntityRole                 = "role"
+	EntityLocation             = "location"
+	EntitySetting              = "setting"
+	EntitySchedule             = "schedule"
+	EntityCalendarDay          = "calendar_day"
+	EntityAttendance           = "attendance"
+	EntityAttendanceCorrection = "attendance_correction"
+	EntityLeaveRequest         = "leave_request"
+	EntityLeaveApprovalStep    = "leave_approval_step"
+	EntityLeaveType            = "leave_type"
+	EntityLeaveEntitlement     = "leave_entitlement"
+	EntityLeaveLedgerEntry     = "leave_ledger_entry"
+	EntityApprovalRule         = "approval_rule"
+	EntityDelegation           = "delegation"
+	EntityAPIKey               = "api_key"
+	EntityDepartment           = "department"
+)
+
+// redacted replaces the value of sensitive fields in snapshots
+const redacted = "[REDACTED]"
+
+// ignoredChanges are fields that change on every update and are left out of the diff
+var ignoredChanges = map[string]bool{"UpdatedAt": true}
+
+// Snapshot captures the state of an entity as a map of its JSON fields.
+// Take it before changing an entity and pass it to Updated or Deleted.
+//
+// Sensitive fields (passwords, secrets, tokens) are redacted, and loaded
+// associations (nested objects or lists of objects with an ID) are left out so
+// that only the entity's own columns are compared.
+func Snapshot(entity interface{}) map[string]interface{} {
+	if entity == nil {
+		return nil
+	}
+	if snapshot, ok := entity.(map[string]interface{}); ok {
+		return snapshot
+	}
+
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil
+	}
+	var snapshot map[string]interface{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil
+	}
+
+	for key, value := range snapshot {
+		if isAssociation(value) {
+			delete(snapshot, key)
+			continue
+		}
+		if isSensitive(key) && value != nil && value != "" {
+			snapshot[key] = redacted + fingerprint(value)
+		}
+	}
+	return snapshot
+}
+
+// Created records the creation of an entity
+func Created(db *gorm.DB, c *gin.Context, entityType string, entityID uint, after interface{}) error {
+	return record(db, c, models.AuditCreate, entityType, entityID, nil, Snapshot(after))
+}
+
+// Updated records a change to an entity. before is usually a Snapshot taken before the change.
+func Updated(db *gorm.DB, c *gin.Context, entityType string, entityID uint, before, after interface{}) error {
+	return record(db, c, models.AuditUpdate, entityType, entityID, Snapshot(before), Snapshot(after))
+}
+
+// Deleted records the deletion of an entity
+func Deleted(db *gorm.DB, c *gin.Context, entityType string, entityID uint, before interface{}) error {
+	return record(db, c, models.AuditDelete, entityType, entityID, Snapshot(before), nil)
+}
+
+// record stores the audit log entry with the actor and IP of the request.
+// Pass the transaction of the change as db so both are committed together.
+func record(db *gorm.DB, c *gin.Context, action models.AuditAction, entityType string, entityID uint, before, after map[string]interface{}) error {
+	entry := models.AuditLog{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	}
+
+	if c != nil {
+		if userId, ok := c.Get("userId"); ok {
+			if id, ok := userId.(uint); ok {
+				entry.ActorID = &id
+			}
+		}
+		entry.ActorName = c.GetString("username")
+		if apiKeyId, ok := c.Get("apiKeyId"); ok {
+			if id, ok := apiKeyId.(uint); ok {
+				entry.APIKeyID = &id
+			}
+		}
+		entry.IPAddress = c.ClientIP()
+	}
+
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	changes := diff(before, after)
+	if action == models.AuditUpdate && len(changes) == 0 {
+		// Nothing changed, e.g. a setting saved with its current value
+		return nil
+	}
+	if len(changes) > 0 {
+		if entry.Changes, err = json.Marshal(changes); err != nil {
+			return err
+		}
+	}
+
+	return db.Create(&entry).Error
+}
+
+// diff returns the fields whose values differ between the snapshots
+func diff(before, after map[string]interface{}) map[string]map[string]interface{} {
+	changes := make(map[string]map[string]interface{})
+	for key, to := range after {
+		if ignoredChanges[key] {
+			continue
+		}
+		from, ok := before[key]
+		if !ok || !reflect.DeepEqual(from, to) {
+			changes[key] = map[string]interface{}{"from": from, "to": to}
+		}
+	}
+	for key, from := range before {
+		if _, ok := after[key]; !ok && !ignoredChanges[key] {
+			changes[key] = map[string]interface{}{"from": from, "to": nil}
+		}
+	}
+	return changes
+}
+
+// isSensitive reports whether a field holds a credential that must not be logged
+func isSensitive(key string) bool {
+	key = strings.ToLower(key)
+	return strings.Contains(key, "password") || strings.Contains(key, "secret") || strings.Contains(key, "token")
+}
+
+// fingerprint identifies a redacted value by a short hash so that a change is still visible in the diff
+func fingerprint(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return ":" + hex.EncodeToString(sum[:4])
+}
+
+// isAssociation reports whether a JSON value is a loaded association
+func isAssociation(value interface{}) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		_, hasID := v["ID"]
+		return hasID
+	case []interface{}:
+		if len(v) == 0 {
+			return false
+		}
+		item, ok := v[0].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		_, hasID := item["ID"]
+		return hasID
+	}
+	return false
+}
@@ -1,3 +1,4 @@
+// Package blocklist keeps track of JWTs revoked before their expiry
 package blocklist
 
 import (
@@ -6,28 +7,56 @@ import (
 	"time"
 )
 
+// ErrRevoked is returned for a token that was revoked, e.g. by logging out
+var ErrRevoked = errors.New("logged out")
+
+// Store persists revoked tokens by their JWT ID (jti)
+type Store interface {
+	// Revoke marks the token as revoked until it expires
+	Revoke(tokenID string, userID uint, expiresAt time.Time) error
+	// IsRevoked reports whether the token was revoked
+	IsRevoked(tokenID string) (bool, error)
+	// Purge removes the tokens that expired before now and returns how many were removed
+	Purge(now time.Time) (int64, error)
+}
+
 var (
-	blocklistedTokens = make(map[string]time.Time)
-	mutex             = &sync.RWMutex{}
+	store Store = NewMemoryStore()
+	mutex       = &sync.RWMutex{}
 )
 
-func Add(token string, expiration time.Duration) {
+// SetStore replaces the store used by the package functions.
+// The default in-memory store is only suitable for a single instance.
+func SetStore(s Store) {
 	mutex.Lock()
 	defer mutex.Unlock()
-	blocklistedTokens[token] = time.Now().Add(expiration)
+	store = s
 }
 
-func IsBlocklisted(token string) error {
+func currentStore() Store {
 	mutex.RLock()
 	defer mutex.RUnlock()
-	expiration, ok := blocklistedTokens[token]
-	if ok {
-		err := errors.New("logged out")
+	return store
+}
+
+// Add revokes the token with the given JWT ID until it expires
+func Add(tokenID string, userID uint, expiresAt time.Time) error {
+	return currentStore().Revoke(tokenID, userID, expiresAt)
+}
+
+// IsBlocklisted returns ErrRevoked if the token was revoked
+func IsBlocklisted(tokenID string) error {
+	revoked, err := currentStore().IsRevoked(tokenID)
+	if err != nil {
 		return err
 	}
-	if time.Now().After(expiration) {
-		delete(blocklistedTokens, token)
-		return nil
+	if revoked {
+		return ErrRevoked
 	}
 	return nil
 }
+
+// Purge removes the revoked tokens that have expired
+func Purge() (int64, error) {
+	return currentStore().Purge(time.Now())
+}
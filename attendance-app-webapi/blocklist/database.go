@@ -0,0 +1,43 @@
+package blocklist
+
+import (
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DBStore keeps revoked tokens in the database so that revocations survive
+// restarts and are shared by every instance of the API
+type DBStore struct {
+	db *gorm.DB
+}
+
+// NewDBStore creates a store backed by the revoked_tokens table
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{db: db}
+}
+
+func (s *DBStore) Revoke(tokenID string, userID uint, expiresAt time.Time) error {
+	// Revoking the same token twice, e.g. a repeated logout, is not an error
+	return s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.RevokedToken{
+		TokenID:   tokenID,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+	}).Error
+}
+
+func (s *DBStore) IsRevoked(tokenID string) (bool, error) {
+	var count int64
+	err := s.db.Model(&models.RevokedToken{}).
+		Where("token_id = ? AND expires_at > ?", tokenID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *DBStore) Purge(now time.Time) (int64, error) {
+	result := s.db.Where("expires_at <= ?", now).Delete(&models.RevokedToken{})
+	return result.RowsAffected, result.Error
+}
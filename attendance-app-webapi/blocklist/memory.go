@@ -0,0 +1,45 @@
+package blocklist
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps revoked tokens in process memory. Revocations are lost on
+// restart and not shared between instances.
+type MemoryStore struct {
+	mutex  sync.RWMutex
+	tokens map[string]time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tokens: make(map[string]time.Time)}
+}
+
+func (s *MemoryStore) Revoke(tokenID string, userID uint, expiresAt time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.tokens[tokenID] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(tokenID string) (bool, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	expiresAt, ok := s.tokens[tokenID]
+	return ok && time.Now().Before(expiresAt), nil
+}
+
+func (s *MemoryStore) Purge(now time.Time) (int64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var purged int64
+	for tokenID, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, tokenID)
+			purged++
+		}
+	}
+	return purged, nil
+}
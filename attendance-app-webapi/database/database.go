@@ -24,12 +24,35 @@ func InitDB() *gorm.DB {
 	}
 
 	err = DB.AutoMigrate(
+		&models.WorkSchedule{},
 		&models.Location{},
+		&models.Permission{},
 		&models.Role{},
 		&models.User{},
+		&models.Department{},
 		&models.Attendance{},
 		&models.LeaveRequest{},
 		&models.Setting{},
+		&models.CalendarDay{},
+		&models.LeaveTypeDefinition{},
+		&models.LeaveEntitlement{},
+		&models.LeaveLedgerEntry{},
+		&models.ApprovalDelegation{},
+		&models.ApprovalChainRule{},
+		&models.LeaveApprovalStep{},
+		&models.AttendanceCorrectionRequest{},
+		&models.AuditLog{},
+		&models.RevokedToken{},
+		&models.UserSession{},
+		&models.RefreshToken{},
+		&models.APIKey{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.RecoveryCode{},
+		&models.LoginChallenge{},
+		&models.LoginThrottle{},
+		&models.SecurityEvent{},
+		&models.RateLimitEvent{},
 	)
 
 	if err != nil {
@@ -50,6 +73,11 @@ func InitDB() *gorm.DB {
 		}
 	}
 
+	//Create Permissions and grant them all to admin roles
+	if err := utils.SyncPermissions(DB); err != nil {
+		log.Fatalf("Failed to sync permissions: %v", err)
+	}
+
 	//Create Default Office Location if not exist
 	var defaultLocation models.Location
 	if err := DB.Where("name = ?", "Main Office").First(&defaultLocation).Error; err != nil {
@@ -69,6 +97,33 @@ func InitDB() *gorm.DB {
 		}
 	}
 
+	//Create Default Work Schedule if not exist
+	var defaultSchedule models.WorkSchedule
+	if err := DB.Where("is_default = ?", true).First(&defaultSchedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			defaultSchedule = models.DefaultWorkSchedule
+			if err := DB.Create(&defaultSchedule).Error; err != nil {
+				log.Printf("Failed to create default work schedule: %v", err)
+			} else {
+				log.Println("Created default work schedule")
+			}
+		}
+	}
+
+	//Create Default Leave Types if not exist
+	for _, leaveType := range models.DefaultLeaveTypes {
+		var existing models.LeaveTypeDefinition
+		if err := DB.Unscoped().Where("code = ?", leaveType.Code).First(&existing).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if err := DB.Create(&leaveType).Error; err != nil {
+					log.Printf("Failed to create %s leave type: %v", leaveType.Code, err)
+				} else {
+					log.Printf("Created default %s leave type", leaveType.Code)
+				}
+			}
+		}
+	}
+
 	//Create Default Settings if not exist
 	var companyNameSetting models.Setting
 	if err := DB.Where("`key` = ?", "company_name").First(&companyNameSetting).Error; err != nil {
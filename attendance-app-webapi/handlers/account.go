@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"attendance-app/audit"
+	"attendance-app/config"
+	"attendance-app/models"
+	"attendance-app/utils"
+	"attendance-app/utils/email"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Email change limits
+const (
+	defaultEmailVerificationLifetime = 24 * time.Hour
+	emailChangeLimit                 = 3
+	emailChangeLimitWindow           = time.Hour
+)
+
+type updateProfilePayload struct {
+	Name string `json:"name" validate:"omitempty,max=255" example:"John Doe"`
+	// A new email only takes effect once confirmed through the link sent to it
+	Email string `json:"email" validate:"omitempty,email" example:"john.doe@example.com"`
+}
+
+type changePasswordPayload struct {
+	CurrentPassword string `json:"currentPassword" binding:"required" example:"secretpassword123"`
+	NewPassword     string `json:"newPassword" binding:"required" validate:"min=8,max=72" example:"newsecretpassword123"`
+}
+
+type verifyEmailPayload struct {
+	Token string `json:"token" binding:"required" example:"3f1c9a..."`
+}
+
+// ProfileResponse is the current user's profile after an update
+type ProfileResponse struct {
+	models.User
+	// PendingEmail is the new email waiting for confirmation
+	PendingEmail string `json:"PendingEmail,omitempty"`
+} //@name ProfileResponse
+
+// emailVerificationLifetime returns how long email verification links stay valid (EMAIL_VERIFICATION_EXP_TIME)
+func emailVerificationLifetime() time.Duration {
+	value := config.Config("EMAIL_VERIFICATION_EXP_TIME")
+	if value == "" {
+		return defaultEmailVerificationLifetime
+	}
+	lifetime, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid EMAIL_VERIFICATION_EXP_TIME configuration: %v", err)
+	}
+	return lifetime
+}
+
+// emailVerificationLink returns the link of the frontend's verification page (EMAIL_VERIFICATION_URL) for the token
+func emailVerificationLink(token string) string {
+	link := config.Config("EMAIL_VERIFICATION_URL")
+	if link == "" {
+		link = "http://localhost:3000/verify-email"
+	}
+	return link + "?token=" + url.QueryEscape(token)
+}
+
+// sendEmailVerification emails the verification link to the new address
+func sendEmailVerification(user models.User, newEmail, token string, expiresAt time.Time) {
+	if err := email.SendEmailVerification(newEmail, user.Name, emailVerificationLink(token), expiresAt); err != nil {
+		log.Printf("Failed to send email verification to user %d: %v", user.ID, err)
+	}
+}
+
+// emailTaken reports whether another user already has the email
+func emailTaken(db *gorm.DB, emailAddress string, userID uint) (bool, error) {
+	var count int64
+	err := db.Model(&models.User{}).Where("email = ? AND id != ?", emailAddress, userID).Count(&count).Error
+	return count > 0, err
+}
+
+// @Summary Update my profile
+// @Description Update the current user's name and email. A new email is only applied once confirmed through the verification link sent to it.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param profile body updateProfilePayload true "Profile data"
+// @Success 200 {object} ProfileResponse
+// @Failure 400 {object} map[string]string "Validation error or email already exists"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 429 {object} map[string]string "Too many email change requests"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/profile [put]
+// @Security BearerAuth
+func UpdateMyProfile(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var payload updateProfilePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var user models.User
+	if err := DB.Preload("Role").First(&user, userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user profile"})
+		return
+	}
+
+	changeName := payload.Name != "" && payload.Name != user.Name
+	changeEmail := payload.Email != "" && payload.Email != user.Email
+
+	// Check the email change before anything is written
+	var token string
+	if changeEmail {
+		taken, err := emailTaken(DB, payload.Email, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
+			return
+		}
+
+		allowed, err := utils.AllowRateLimited(DB, fmt.Sprintf("email_change:user:%d", user.ID), emailChangeLimit, emailChangeLimitWindow)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many email change requests, try again later"})
+			return
+		}
+
+		if token, err = utils.RandomToken(32); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate verification link"})
+			return
+		}
+	}
+
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if changeName {
+		before := audit.Snapshot(user)
+		user.Name = payload.Name
+		if err := tx.Model(&user).Update("name", payload.Name).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+		if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+	}
+
+	var verification models.EmailVerificationToken
+	if changeEmail {
+		// Only the latest link works
+		if err := tx.Unscoped().Where("user_id = ? AND used_at IS NULL", user.ID).
+			Delete(&models.EmailVerificationToken{}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+
+		verification = models.EmailVerificationToken{
+			UserID:    user.ID,
+			NewEmail:  payload.Email,
+			TokenHash: utils.HashToken(token),
+			ExpiresAt: time.Now().Add(emailVerificationLifetime()),
+		}
+		if err := tx.Create(&verification).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update profile"})
+		return
+	}
+
+	response := ProfileResponse{User: user}
+	if changeEmail {
+		go sendEmailVerification(user, verification.NewEmail, token, verification.ExpiresAt)
+		response.PendingEmail = verification.NewEmail
+	}
+
+	response.Password = ""
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Change my password
+// @Description Change the current user's password. The current password is required. Every other session of the user is logged out; the current one stays logged in.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param password body changePasswordPayload true "Current and new password"
+// @Success 200 {object} map[string]string "Password changed successfully"
+// @Failure 400 {object} map[string]string "Validation error or incorrect current password"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/password [post]
+// @Security BearerAuth
+func ChangeMyPassword(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+	currentSessionId := c.GetUint("sessionId")
+
+	var payload changePasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var user models.User
+	if err := DB.First(&user, userId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if !utils.CheckPasswordHash(payload.CurrentPassword, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+	if payload.NewPassword == payload.CurrentPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "New password must be different from the current password"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(payload.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		return
+	}
+
+	var sessions []models.UserSession
+	if err := DB.Where("user_id = ? AND id != ? AND revoked_at IS NULL", user.ID, currentSessionId).
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	before := audit.Snapshot(user)
+	user.Password = hashedPassword
+	if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	// Reset links requested before the change must not undo it
+	if err := tx.Unscoped().Where("user_id = ? AND used_at IS NULL", user.ID).
+		Delete(&models.PasswordResetToken{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	for i := range sessions {
+		if err := revokeSession(tx, &sessions[i], models.SessionPasswordChanged); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
+}
+
+// @Summary Verify email
+// @Description Confirm an email change with the token from the verification link sent to the new address. The token can be used once.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param request body verifyEmailPayload true "Verification token"
+// @Success 200 {object} map[string]string "Email changed successfully"
+// @Failure 400 {object} map[string]string "Invalid or expired token, or email already exists"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /email/verify [post]
+func VerifyEmail(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var payload verifyEmailPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var verification models.EmailVerificationToken
+	if err := DB.Where("token_hash = ?", utils.HashToken(payload.Token)).First(&verification).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+	now := time.Now()
+	if verification.UsedAt != nil || !now.Before(verification.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	var user models.User
+	if err := DB.First(&user, verification.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	// Another account may have taken the email since the link was sent
+	taken, err := emailTaken(DB, verification.NewEmail, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		return
+	}
+	if taken {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already exists"})
+		return
+	}
+
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only the first use succeeds, also when the link is opened twice at once
+	result := tx.Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", verification.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired verification token"})
+		return
+	}
+
+	before := audit.Snapshot(user)
+	user.Email = verification.NewEmail
+	if err := tx.Model(&user).Update("email", verification.NewEmail).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify email"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
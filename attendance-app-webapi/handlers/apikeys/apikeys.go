@@ -0,0 +1,252 @@
+// Package apikeys handles the API keys that services use instead of a user's token
+package apikeys
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// keyPrefix starts every API key so that leaked keys are easy to recognize
+const keyPrefix = "ak_"
+
+// APIKeyRequest represents the request payload for creating an API key
+type APIKeyRequest struct {
+	Name string `json:"Name" validate:"required,max=100" example:"BI reports"`
+	// Comma separated area:access list, e.g. attendance:read,leave:read or *:read
+	Scopes string `json:"Scopes" validate:"required,max=500" example:"attendance:read,leave:read"`
+	// User the key acts as, defaults to the admin creating it. Another user
+	// may only be chosen when the creator holds all of their permissions.
+	UserID uint `json:"UserID" example:"1"`
+	// Last day the key is valid (YYYY-MM-DD), empty for no expiry
+	ExpiresAt string `json:"ExpiresAt" example:"2026-12-31"`
+}
+
+// APIKeyCreatedResponse is the created API key with the key itself, which is only returned once
+type APIKeyCreatedResponse struct {
+	models.APIKey
+	Key string `json:"Key" example:"ak_3f1c9a..."`
+}
+
+// @Summary Get API keys
+// @Description List every API key with its scopes, expiry and last use. The keys themselves are not stored and cannot be shown again.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage API keys"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/api-keys [get]
+// @Security BearerAuth
+func GetAPIKeys(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var keys []models.APIKey
+	if err := DB.Preload("User").Order("created_at DESC").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// @Summary Create API key
+// @Description Create a named API key for a service. Send it in the X-API-Key header. The key acts as its user, limited to its scopes: area:read allows GET requests and area:write every request to routes under /admin/{area} or /user/{area}, and * matches every area. The key is only returned in this response.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param apiKey body APIKeyRequest true "API key data"
+// @Success 201 {object} APIKeyCreatedResponse
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage API keys, or the user holds permissions the creator does not"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/api-keys [post]
+// @Security BearerAuth
+func CreateAPIKey(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var req APIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	scopes, err := models.ParseScopes(req.Scopes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one scope is required"})
+		return
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresAt != "" {
+		lastDay, err := time.ParseInLocation(utils.CalendarDateFormat, req.ExpiresAt, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid expiry date format. Use YYYY-MM-DD"})
+			return
+		}
+		// The key is valid through the whole last day
+		end := lastDay.AddDate(0, 0, 1)
+		if !end.After(time.Now()) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Expiry date cannot be in the past"})
+			return
+		}
+		expiresAt = &end
+	}
+
+	ownerId := req.UserID
+	if ownerId == 0 {
+		ownerId = userId
+	}
+	var owner models.User
+	if err := DB.First(&owner, ownerId).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// A key acting as another user must not grant more than the creator holds
+	if owner.ID != userId {
+		ownerPermissions, err := utils.UserPermissions(DB, owner.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			return
+		}
+		allowed, err := utils.HoldsPermissions(DB, userId, ownerPermissions)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Cannot create an API key for a user with permissions you do not hold"})
+			return
+		}
+	}
+
+	secret, err := utils.RandomToken(24)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate API key"})
+		return
+	}
+	key := keyPrefix + secret
+
+	apiKey := models.APIKey{
+		Name:        req.Name,
+		Prefix:      key[:len(keyPrefix)+8],
+		KeyHash:     utils.HashToken(key),
+		Scopes:      strings.Join(scopes, ","),
+		UserID:      owner.ID,
+		CreatedByID: &userId,
+		ExpiresAt:   expiresAt,
+	}
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&apiKey).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityAPIKey, apiKey.ID, apiKey); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	apiKey.User = &owner
+	c.JSON(http.StatusCreated, APIKeyCreatedResponse{APIKey: apiKey, Key: key})
+}
+
+// @Summary Revoke API key
+// @Description Revoke an API key immediately. Requests with the key are rejected afterwards.
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param id path int true "API key ID"
+// @Success 200 {object} models.APIKey
+// @Failure 400 {object} map[string]string "API key already revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage API keys"
+// @Failure 404 {object} map[string]string "API key not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/api-keys/{id} [delete]
+// @Security BearerAuth
+func RevokeAPIKey(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var apiKey models.APIKey
+	if err := DB.First(&apiKey, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API key"})
+		return
+	}
+
+	if apiKey.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "API key has already been revoked"})
+		return
+	}
+
+	before := audit.Snapshot(apiKey)
+	now := time.Now()
+	apiKey.RevokedAt = &now
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(&apiKey).Update("revoked_at", now).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityAPIKey, apiKey.ID, before, apiKey); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, apiKey)
+}
@@ -1,13 +1,15 @@
 package attendance
 
 import (
+	"attendance-app/audit"
 	"attendance-app/models"
 	"attendance-app/services"
 	"attendance-app/storage"
 	"attendance-app/utils"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -53,8 +55,50 @@ type AttendanceResponse struct {
 	UpdatedAt time.Time `json:"updated_at" example:"2025-10-21T09:00:00Z"`
 } //@name AttendanceResponse
 
+// checkInLateAfter returns the time after which a check-in on the shift day is
+// late, and the user's approved partial-day leave on that day. Leave that covers
+// the start of the shift moves the threshold to the end of the leave.
+func checkInLateAfter(db *gorm.DB, schedule models.WorkSchedule, userId uint, shiftDate time.Time) (time.Time, []models.LeaveRequest, error) {
+	partialLeaves, err := utils.GetApprovedPartialLeaves(db, userId, shiftDate)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	return utils.LateThreshold(schedule, shiftDate, partialLeaves), partialLeaves, nil
+}
+
+// matchUserLocation finds the nearest assigned location containing the coordinates.
+// It writes the error response and returns false when no location matches.
+func matchUserLocation(c *gin.Context, db *gorm.DB, userId uint, latitude, longitude float64) (*utils.LocationMatch, bool) {
+	locations, err := utils.GetUserLocations(db, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assigned locations"})
+		return nil, false
+	}
+
+	match := utils.FindNearestLocation(latitude, longitude, locations)
+	if match == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "No check-in location is assigned to you or your role. Ask an administrator to assign one."})
+		return nil, false
+	}
+
+	if !match.Within {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Location is too far from any assigned site. The closest site is %s, %.0f meters away",
+				match.Location.Name, match.Distance),
+			"details": map[string]interface{}{
+				"closestLocationId":   match.Location.ID,
+				"closestLocationName": match.Location.Name,
+				"distanceMeters":      math.Round(match.Distance),
+			},
+		})
+		return nil, false
+	}
+
+	return match, true
+}
+
 // @Summary Check-in attendance
-// @Description Record user's check-in with photo and location. Location must be within defined radius of office coordinates.
+// @Description Record user's check-in with photo and location. Location must be within the radius of any location assigned to the user (directly or through their role); the nearest matching location is stored. Lateness is evaluated against the user's work schedule; approved partial-day leave is linked to the record and leave covering the start of the shift delays the lateness threshold.
 // @Tags attendance
 // @Security BearerAuth
 // @Accept multipart/form-data
@@ -65,6 +109,7 @@ type AttendanceResponse struct {
 // @Success 201 {object} AttendanceResponse "Successfully created attendance record"
 // @Failure 400 {object} models.ErrorResponse "Invalid request, location too far from office, or invalid photo"
 // @Failure 401 {object} models.ErrorResponse "Unauthorized or invalid token"
+// @Failure 403 {object} models.ErrorResponse "No location assigned"
 // @Failure 429 {object} models.ErrorResponse "Already checked in today"
 // @Failure 500 {object} models.ErrorResponse "Server error"
 // @Router /user/attendance/check-in [post]
@@ -108,47 +153,29 @@ func CheckIn(c *gin.Context) {
 		return
 	}
 
-	// Get default location from settings
-	var defaultLocationSetting models.Setting
-	if err := db.Where("`key` = ?", "default_location_id").First(&defaultLocationSetting).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Default location not configured in settings"})
+	// Verify the coordinates are inside any location assigned to the user
+	match, ok := matchUserLocation(c, db, userId, req.Latitude, req.Longitude)
+	if !ok {
 		return
 	}
 
-	// Convert location ID from string to uint
-	locationID, err := strconv.ParseUint(defaultLocationSetting.Value, 10, 32)
+	// Evaluate the check-in against the user's assigned work schedule
+	schedule, err := utils.GetUserWorkSchedule(db, userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid location ID in settings"})
-		return
-	}
-
-	// Find the valid office location using default_location_id from settings
-	var location models.Location
-	if err := db.First(&location, uint(locationID)).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Default location not found"})
-		return
-	}
-
-	// Verify location is within radius
-	distance := utils.CalculateDistance(
-		location.Latitude, location.Longitude,
-		req.Latitude, req.Longitude,
-	)
-
-	if distance > float64(location.Radius) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Location is too far from %s office", location.Name)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
 		return
 	}
 
 	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	shiftDate := schedule.ShiftDate(now)
+	windowStart, windowEnd := schedule.AttendanceWindow(shiftDate)
 
 	var attendance models.Attendance
-	result := db.Where("user_id = ? AND check_in_time >= ?", userId, startOfDay).First(&attendance)
+	result := db.Where("user_id = ? AND check_in_time >= ? AND check_in_time < ?", userId, windowStart, windowEnd).First(&attendance)
 
 	if result.Error != nil {
-		// Create new attendance record if none exists
-		locID := location.ID
+		// Create new attendance record if none exists, linked to the nearest matching location
+		locID := match.Location.ID
 		attendance = models.Attendance{
 			UserID:           userId,
 			LocationID:       &locID,
@@ -160,14 +187,42 @@ func CheckIn(c *gin.Context) {
 			ValidationStatus: models.Present,
 		}
 
-		if now.Hour() > 7 || (now.Hour() == 7 && now.Minute() > 30) {
+		// Approved partial-day leave is linked to the record
+		lateAfter, partialLeaves, err := checkInLateAfter(db, schedule, userId, shiftDate)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leave requests"})
+			return
+		}
+		if len(partialLeaves) > 0 {
+			attendance.LeaveRequestID = &partialLeaves[0].ID
+		}
+
+		if now.Truncate(time.Minute).After(lateAfter) {
 			attendance.Status = models.Late
 		}
 
-		if err := db.Create(&attendance).Error; err != nil {
+		// Save the change and its audit entry together
+		tx := db.Begin()
+		defer func() {
+			if r := recover(); r != nil {
+				tx.Rollback()
+			}
+		}()
+
+		if err := tx.Create(&attendance).Error; err != nil {
+			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create attendance record"})
 			return
 		}
+		if err := audit.Created(tx, c, audit.EntityAttendance, attendance.ID, attendance); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+			return
+		}
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Already checked in today"})
 		return
@@ -177,7 +232,7 @@ func CheckIn(c *gin.Context) {
 }
 
 // @Summary Check-out attendance
-// @Description Record user's check-out with photo and location
+// @Description Record user's check-out with photo and location. Location must be within the radius of any location assigned to the user.
 // @Tags attendance
 // @Security BearerAuth
 // @Accept multipart/form-data
@@ -188,6 +243,7 @@ func CheckIn(c *gin.Context) {
 // @Success 200 {object} models.AttendanceSwagger
 // @Failure 400 {object} map[string]string "Invalid request, location too far, or invalid photo"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "No location assigned"
 // @Failure 404 {object} map[string]string "No check-in record found"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /user/attendance/check-out [post]
@@ -225,44 +281,25 @@ func CheckOut(c *gin.Context) {
 		return
 	}
 
-	// Get default location from settings
-	var defaultLocationSetting models.Setting
-	if err := db.Where("`key` = ?", "default_location_id").First(&defaultLocationSetting).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Default location not configured in settings"})
+	// Verify the coordinates are inside any location assigned to the user
+	if _, ok := matchUserLocation(c, db, userId, req.Latitude, req.Longitude); !ok {
 		return
 	}
 
-	// Convert location ID from string to uint
-	locationID, err := strconv.ParseUint(defaultLocationSetting.Value, 10, 32)
+	// Find the check-in belonging to the user's current shift
+	schedule, err := utils.GetUserWorkSchedule(db, userId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid location ID in settings"})
-		return
-	}
-
-	// Find the valid office location using default_location_id from settings
-	var location models.Location
-	if err := db.First(&location, uint(locationID)).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Default location not found"})
-		return
-	}
-
-	// Verify location is within radius
-	distance := utils.CalculateDistance(
-		location.Latitude, location.Longitude,
-		req.Latitude, req.Longitude,
-	)
-
-	if distance > float64(location.Radius) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Location is too far from %s office", location.Name)})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
 		return
 	}
 
 	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	windowStart, windowEnd := schedule.AttendanceWindow(schedule.ShiftDate(now))
 
 	var attendance models.Attendance
 	// Only allow checkout if user actually checked in (not ABSENT)
-	result := db.Where("user_id = ? AND check_in_time >= ? AND validation_status != ?", userId, startOfDay, models.Absent).First(&attendance)
+	result := db.Where("user_id = ? AND check_in_time >= ? AND check_in_time < ? AND validation_status != ?",
+		userId, windowStart, windowEnd, models.Absent).First(&attendance)
 
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "No check-in record found for today. Cannot checkout without checking in first."})
@@ -281,6 +318,7 @@ func CheckOut(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(attendance)
 	attendance.CheckOutTime = &now
 	attendance.CheckOutLatitude = req.Latitude
 	attendance.CheckOutLongitude = req.Longitude
@@ -288,10 +326,28 @@ func CheckOut(c *gin.Context) {
 	// Keep the validation status as Present since they've checked out
 	attendance.ValidationStatus = models.Present
 
-	if err := db.Save(&attendance).Error; err != nil {
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&attendance).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attendance record"})
 		return
 	}
+	if err := audit.Updated(tx, c, audit.EntityAttendance, attendance.ID, before, attendance); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
 
 	c.JSON(http.StatusOK, attendance)
 }
@@ -321,7 +377,8 @@ func GetMyAttendanceRecords(c *gin.Context) {
 	query := db.Model(&models.Attendance{}).
 		Where("user_id = ?", userId).
 		Preload("Location").
-		Preload("Validator")
+		Preload("Validator").
+		Preload("LeaveRequest")
 
 	// Apply search if provided
 	if params.Search != "" {
@@ -375,11 +432,13 @@ func GetMyAttendanceRecords(c *gin.Context) {
 }
 
 // @Summary Get subordinate attendance records
-// @Description Retrieve attendance records for all subordinates (supervisor only)
+// @Description Retrieve attendance records for all subordinates (supervisor only). With depth the records of subordinates further down the reporting tree are included; every record carries the reporting path from the supervisor down to its user.
 // @Tags attendance
 // @Security BearerAuth
 // @Produce json
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
 // @Success 200 {array} models.AttendanceSwagger
+// @Failure 400 {object} map[string]string "Invalid depth"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden - Not a supervisor"
 // @Failure 500 {object} map[string]string "Server error"
@@ -391,17 +450,29 @@ func GetSubordinateAttendanceRecords(c *gin.Context) {
 
 	fmt.Printf("[DEBUG] GetSubordinateAttendanceRecords - Start for supervisorId: %d\n", supervisorId)
 
-	var subordinateIds []uint
-	if err := db.Model(&models.User{}).Where("supervisor_id = ?", supervisorId).Pluck("id", &subordinateIds).Error; err != nil {
+	depth, err := utils.ReportingDepthQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Includes the subordinates of supervisors who delegated their approval rights
+	subordinates, err := utils.GetReportingLines(db, supervisorId, depth)
+	if err != nil {
 		fmt.Printf("[ERROR] Failed to fetch subordinates: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates", "details": err.Error()})
 		return
 	}
 
-	fmt.Printf("[DEBUG] Found %d subordinates: %v\n", len(subordinateIds), subordinateIds)
+	fmt.Printf("[DEBUG] Found %d subordinates: %v\n", len(subordinates.UserIDs), subordinates.UserIDs)
+
+	listAttendanceRecords(c, db, subordinates)
+}
 
-	if len(subordinateIds) == 0 {
-		fmt.Printf("[DEBUG] No subordinates found, returning empty response\n")
+// listAttendanceRecords responds with a page of the attendance records of the users
+func listAttendanceRecords(c *gin.Context, db *gorm.DB, lines utils.ReportingLines) {
+	if len(lines.UserIDs) == 0 {
+		fmt.Printf("[DEBUG] No users found, returning empty response\n")
 		// Return empty paginated response
 		response := utils.BuildPaginatedResponse([]models.Attendance{}, 0, utils.GetPaginationParams(c))
 		c.JSON(http.StatusOK, response)
@@ -415,10 +486,11 @@ func GetSubordinateAttendanceRecords(c *gin.Context) {
 
 	// Build base query
 	query := db.Model(&models.Attendance{}).
-		Where("user_id IN ?", subordinateIds).
+		Where("user_id IN ?", lines.UserIDs).
 		Preload("User").
 		Preload("Location").
-		Preload("Validator")
+		Preload("Validator").
+		Preload("LeaveRequest")
 
 	// Apply search if provided
 	if params.Search != "" {
@@ -466,14 +538,66 @@ func GetSubordinateAttendanceRecords(c *gin.Context) {
 	}
 	fmt.Printf("[DEBUG] Retrieved %d attendance records\n", len(attendances))
 
+	for i := range attendances {
+		attendances[i].ReportingPath = lines.Path(attendances[i].UserID)
+	}
+
 	// Build paginated response
 	response := utils.BuildPaginatedResponse(attendances, totalRows, params)
 	fmt.Printf("[DEBUG] Sending response with %d records, page %d of %d\n", len(attendances), params.Page, response.TotalPages)
 	c.JSON(http.StatusOK, response)
 }
 
+// departmentMemberIds returns the members of the departments headed by the
+// current user, limited by the departmentId query parameter. It responds with
+// the error and returns false when the user may not see them.
+func departmentMemberIds(c *gin.Context, db *gorm.DB) ([]uint, bool) {
+	headId := c.MustGet("userId").(uint)
+
+	departmentId, err := utils.DepartmentQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return nil, false
+	}
+
+	memberIds, err := utils.GetHeadedDepartmentMemberIDs(db, headId, departmentId)
+	if errors.Is(err, utils.ErrNotDepartmentHead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not the head of the department"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department members"})
+		return nil, false
+	}
+	return memberIds, true
+}
+
+// @Summary Get department attendance records
+// @Description Retrieve the attendance records of everyone in the departments headed by the current user and in the departments below them (department head only). departmentId limits the records to one of those departments and its sub-departments.
+// @Tags attendance
+// @Security BearerAuth
+// @Produce json
+// @Param departmentId query int false "Department ID within the headed departments"
+// @Success 200 {array} models.AttendanceSwagger
+// @Failure 400 {object} map[string]string "Invalid department ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the head of the department"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/department [get]
+// GetDepartmentAttendanceRecords gets the attendance records of the current user's departments
+func GetDepartmentAttendanceRecords(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	memberIds, ok := departmentMemberIds(c, db)
+	if !ok {
+		return
+	}
+
+	listAttendanceRecords(c, db, utils.ReportingLines{UserIDs: memberIds})
+}
+
 // @Summary Update subordinate attendance record
-// @Description Update attendance record for a subordinate (supervisor only)
+// @Description Update attendance record for a subordinate (supervisor or a user they delegated their approval rights to)
 // @Tags attendance
 // @Security BearerAuth
 // @Accept json
@@ -512,7 +636,13 @@ func UpdateSubordinateAttendanceRecord(c *gin.Context) {
 		return
 	}
 
-	if user.SupervisorID == nil || *user.SupervisorID != supervisorId {
+	// The supervisor or their delegate can validate
+	delegation, allowed, err := utils.CanActForSupervisor(db, user.ID, supervisorId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approval delegation"})
+		return
+	}
+	if !allowed {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to update this attendance record"})
 		return
 	}
@@ -523,14 +653,37 @@ func UpdateSubordinateAttendanceRecord(c *gin.Context) {
 	// - Supervisor can change PRESENT to REJECTED with reason
 	// - Supervisor can also change REJECTED back to PRESENT if needed
 	// - No restrictions on when validation can be performed
+	before := audit.Snapshot(attendance)
 	attendance.ValidationStatus = req.ValidationStatus
 	attendance.ValidatorID = &supervisorId
+	attendance.ValidationDelegationID = nil
+	if delegation != nil {
+		attendance.ValidationDelegationID = &delegation.ID
+	}
 	attendance.Notes = req.Notes
 
-	if err := db.Save(&attendance).Error; err != nil {
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&attendance).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attendance record"})
 		return
 	}
+	if err := audit.Updated(tx, c, audit.EntityAttendance, attendance.ID, before, attendance); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
 
 	// Send email notification
 	emailService := services.NewEmailService()
@@ -723,11 +876,13 @@ func ExportMyAttendanceToExcel(c *gin.Context) {
 }
 
 // @Summary Export subordinate attendance records to Excel
-// @Description Export subordinate attendance records to Excel file (supervisor only)
+// @Description Export subordinate attendance records to Excel file (supervisor only). With depth the records of subordinates further down the reporting tree are included; the Reporting Path column shows the reporting line down to each user.
 // @Tags attendance
 // @Security BearerAuth
 // @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
 // @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid depth or no subordinates"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden - Not a supervisor"
 // @Failure 500 {object} map[string]string "Server error"
@@ -736,21 +891,33 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	supervisorId := c.MustGet("userId").(uint)
 
+	depth, err := utils.ReportingDepthQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get subordinate IDs
-	var subordinateIds []uint
-	if err := db.Model(&models.User{}).Where("supervisor_id = ?", supervisorId).Pluck("id", &subordinateIds).Error; err != nil {
+	// Includes the subordinates of supervisors who delegated their approval rights
+	subordinates, err := utils.GetReportingLines(db, supervisorId, depth)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates"})
 		return
 	}
 
-	if len(subordinateIds) == 0 {
+	if len(subordinates.UserIDs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No subordinates found"})
 		return
 	}
 
-	// Fetch subordinate attendance records with preloads
+	exportAttendanceRecords(c, db, subordinates, "Subordinate Attendance", "subordinate_attendance")
+}
+
+// exportAttendanceRecords writes the attendance records of the users to an Excel download
+func exportAttendanceRecords(c *gin.Context, db *gorm.DB, lines utils.ReportingLines, sheetName, filePrefix string) {
+	// Fetch attendance records with preloads
 	var attendances []models.Attendance
-	if err := db.Where("user_id IN ?", subordinateIds).
+	if err := db.Where("user_id IN ?", lines.UserIDs).
 		Preload("User").
 		Preload("Location").
 		Preload("Validator").
@@ -768,7 +935,6 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 		}
 	}()
 
-	sheetName := "Subordinate Attendance"
 	index, err := f.NewSheet(sheetName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Excel sheet"})
@@ -782,6 +948,9 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 		"Check In Photo URL", "Check Out Photo URL", "Location Name", "Location Address",
 		"Status", "Validation Status", "Validator Name", "Notes", "Created At", "Updated At",
 	}
+	if lines.Paths != nil {
+		headers = append(headers, "Reporting Path")
+	}
 	for i, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, header)
@@ -793,7 +962,8 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 		Fill: excelize.Fill{Type: "pattern", Color: []string{"#E0E0E0"}, Pattern: 1},
 	})
 	if err == nil {
-		f.SetCellStyle(sheetName, "A1", "T1", headerStyle)
+		lastHeader, _ := excelize.CoordinatesToCellName(len(headers), 1)
+		f.SetCellStyle(sheetName, "A1", lastHeader, headerStyle)
 	}
 
 	// Write attendance data
@@ -895,6 +1065,12 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 		// Updated At
 		cell, _ = excelize.CoordinatesToCellName(20, row)
 		f.SetCellValue(sheetName, cell, attendance.UpdatedAt.Format("2006-01-02 15:04:05"))
+
+		// Reporting Path
+		if lines.Paths != nil {
+			cell, _ = excelize.CoordinatesToCellName(21, row)
+			f.SetCellValue(sheetName, cell, utils.FormatReportingPath(lines.Path(attendance.UserID)))
+		}
 	}
 
 	// Auto-fit columns
@@ -908,7 +1084,7 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 	f.DeleteSheet("Sheet1")
 
 	// Generate filename with current date
-	filename := fmt.Sprintf("subordinate_attendance_%s.xlsx", time.Now().Format("2006-01-02"))
+	filename := fmt.Sprintf("%s_%s.xlsx", filePrefix, time.Now().Format("2006-01-02"))
 
 	// Set headers for file download
 	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
@@ -921,3 +1097,31 @@ func ExportSubordinateAttendanceToExcel(c *gin.Context) {
 		return
 	}
 }
+
+// @Summary Export department attendance records to Excel
+// @Description Export the attendance records of everyone in the departments headed by the current user and in the departments below them to an Excel file (department head only)
+// @Tags attendance
+// @Security BearerAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param departmentId query int false "Department ID within the headed departments"
+// @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid department ID or no department members"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the head of the department"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/department/export/excel [get]
+func ExportDepartmentAttendanceToExcel(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	memberIds, ok := departmentMemberIds(c, db)
+	if !ok {
+		return
+	}
+
+	if len(memberIds) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No department members found"})
+		return
+	}
+
+	exportAttendanceRecords(c, db, utils.ReportingLines{UserIDs: memberIds}, "Department Attendance", "department_attendance")
+}
@@ -0,0 +1,661 @@
+package attendance
+
+import (
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/storage"
+	"attendance-app/utils"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// correctionTimeFormat is the layout of proposed check-in and check-out times, in server local time
+const correctionTimeFormat = "2006-01-02 15:04"
+
+// AttendanceCorrectionForm represents the request payload for submitting an attendance correction
+type AttendanceCorrectionForm struct {
+	// Attendance record to correct; omit to add a missing record
+	AttendanceID uint `json:"attendanceId" example:"12" form:"attendanceId"`
+	// Corrected check-in time (YYYY-MM-DD HH:MM), required when adding a missing record
+	CheckInTime string `json:"checkInTime" example:"2025-07-01 08:00" form:"checkInTime"`
+	// Corrected check-out time (YYYY-MM-DD HH:MM)
+	CheckOutTime string `json:"checkOutTime" example:"2025-07-01 17:05" form:"checkOutTime"`
+	// Why the record needs correcting
+	Reason string `json:"reason" binding:"required" example:"Forgot to check out" form:"reason"`
+} //@name AttendanceCorrectionForm
+
+// CorrectionValidationRequest represents the request payload for deciding on an attendance correction
+type CorrectionValidationRequest struct {
+	// Status to set for the correction request (APPROVED, REJECTED)
+	Status models.CorrectionStatus `json:"status" binding:"required" example:"APPROVED"`
+	// Optional notes from the reviewer
+	ReviewerNotes string `json:"reviewerNotes" example:"Confirmed with the security log"`
+} //@name CorrectionValidationRequest
+
+// parseCorrectionTime parses an optional proposed time
+func parseCorrectionTime(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation(correctionTimeFormat, value, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// findAttendanceInWindow returns the user's attendance record checked in within the window, if any
+func findAttendanceInWindow(db *gorm.DB, userId uint, windowStart, windowEnd time.Time) (*models.Attendance, error) {
+	var attendance models.Attendance
+	err := db.Where("user_id = ? AND check_in_time >= ? AND check_in_time < ?", userId, windowStart, windowEnd).
+		First(&attendance).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &attendance, nil
+}
+
+// @Summary Submit attendance correction
+// @Description Propose corrected check-in and/or check-out times for one of your attendance records, or a missing record for a day you worked, with a reason and optional evidence. Your supervisor approves or rejects it.
+// @Tags attendance
+// @Security BearerAuth
+// @Accept multipart/form-data
+// @Produce json
+// @Param attendanceId formData int false "Attendance record to correct; omit to add a missing record"
+// @Param checkInTime formData string false "Corrected check-in time (YYYY-MM-DD HH:MM)"
+// @Param checkOutTime formData string false "Corrected check-out time (YYYY-MM-DD HH:MM)"
+// @Param reason formData string true "Reason for the correction"
+// @Param evidence formData file false "Supporting evidence (JPG, JPEG, PNG, PDF, max 5MB)"
+// @Success 200 {object} models.AttendanceCorrectionRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Attendance record not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/corrections [post]
+// SubmitAttendanceCorrection handles an employee's attendance correction request
+func SubmitAttendanceCorrection(c *gin.Context) {
+	var req AttendanceCorrectionForm
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	checkInTime, err := parseCorrectionTime(req.CheckInTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check-in time format. Use YYYY-MM-DD HH:MM"})
+		return
+	}
+	checkOutTime, err := parseCorrectionTime(req.CheckOutTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid check-out time format. Use YYYY-MM-DD HH:MM"})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if user.SupervisorID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You have no supervisor to approve attendance corrections"})
+		return
+	}
+
+	schedule, err := utils.GetUserWorkSchedule(db, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
+		return
+	}
+
+	// The check-in after the correction decides the shift the record belongs to
+	effectiveCheckIn := checkInTime
+	var attendanceId *uint
+	if req.AttendanceID != 0 {
+		var attendance models.Attendance
+		if err := db.Where("user_id = ?", userId).First(&attendance, req.AttendanceID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Attendance record not found"})
+			return
+		}
+		if attendance.ValidationStatus == models.Leave {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Leave records cannot be corrected. Cancel the leave request instead"})
+			return
+		}
+		if checkInTime == nil && checkOutTime == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Provide a corrected check-in or check-out time"})
+			return
+		}
+		if attendance.ValidationStatus == models.Absent && checkInTime == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Provide the check-in time for a shift recorded as absent"})
+			return
+		}
+		if effectiveCheckIn == nil {
+			effectiveCheckIn = attendance.CheckInTime
+		}
+
+		windowStart, windowEnd := schedule.AttendanceWindow(schedule.ShiftDate(*attendance.CheckInTime))
+		if effectiveCheckIn.Before(windowStart) || !effectiveCheckIn.Before(windowEnd) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Corrected check-in time must fall on the same shift as the record"})
+			return
+		}
+		attendanceId = &attendance.ID
+	} else {
+		if checkInTime == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Check-in time is required to add a missing attendance record"})
+			return
+		}
+
+		windowStart, windowEnd := schedule.AttendanceWindow(schedule.ShiftDate(*checkInTime))
+		existing, err := findAttendanceInWindow(db, userId, windowStart, windowEnd)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing attendance records"})
+			return
+		}
+		if existing != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "An attendance record already exists for this shift",
+				"details": map[string]interface{}{
+					"attendanceId": existing.ID,
+					"message":      "Submit the correction for the existing record instead.",
+				},
+			})
+			return
+		}
+	}
+
+	now := time.Now()
+	if effectiveCheckIn.After(now) || (checkOutTime != nil && checkOutTime.After(now)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Corrected times cannot be in the future"})
+		return
+	}
+	if checkOutTime != nil && !checkOutTime.After(*effectiveCheckIn) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Check-out time must be after check-in time"})
+		return
+	}
+
+	// Only one pending correction per record or missing shift
+	pendingQuery := db.Model(&models.AttendanceCorrectionRequest{}).
+		Where("user_id = ? AND status = ?", userId, models.CorrectionPending)
+	if attendanceId != nil {
+		pendingQuery = pendingQuery.Where("attendance_id = ?", *attendanceId)
+	} else {
+		windowStart, windowEnd := schedule.AttendanceWindow(schedule.ShiftDate(*checkInTime))
+		pendingQuery = pendingQuery.Where("attendance_id IS NULL AND check_in_time >= ? AND check_in_time < ?",
+			windowStart, windowEnd)
+	}
+	var pendingCount int64
+	if err := pendingQuery.Count(&pendingCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing correction requests"})
+		return
+	}
+	if pendingCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A correction request for this record is already pending"})
+		return
+	}
+
+	var evidenceURL string
+	if file, err := c.FormFile("evidence"); err == nil {
+		// Initialize storage
+		fileStorage := storage.NewLocalStorage(storage.Current.BasePath)
+
+		// Validate file type
+		if err := fileStorage.ValidateFileType(file.Filename, storage.Current.AllowedTypes["correction"]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Validate file size
+		if err := fileStorage.ValidateFileSize(file.Size, storage.Current.MaxFileSize); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Save file
+		evidenceURL, err = fileStorage.Save(file, "correction")
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save evidence"})
+			return
+		}
+	}
+
+	correction := models.AttendanceCorrectionRequest{
+		UserID:       userId,
+		AttendanceID: attendanceId,
+		CheckInTime:  checkInTime,
+		CheckOutTime: checkOutTime,
+		Reason:       req.Reason,
+		EvidenceURL:  evidenceURL,
+		Status:       models.CorrectionPending,
+	}
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&correction).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create correction request"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityAttendanceCorrection, correction.ID, correction); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, correction)
+}
+
+// @Summary Get my attendance corrections
+// @Description Get the attendance correction requests submitted by the current user
+// @Tags attendance
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.AttendanceCorrectionRequestSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/corrections/my-requests [get]
+// GetMyAttendanceCorrections gets the current user's correction requests
+func GetMyAttendanceCorrections(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	// Get pagination params
+	params := utils.GetPaginationParams(c)
+
+	// Build base query
+	query := db.Model(&models.AttendanceCorrectionRequest{}).
+		Where("user_id = ?", userId).
+		Preload("Attendance").
+		Preload("Reviewer")
+
+	// Apply search if provided
+	if params.Search != "" {
+		searchPattern := "%" + params.Search + "%"
+		query = query.Where("status LIKE ? OR reason LIKE ?", searchPattern, searchPattern)
+	}
+
+	// Count total rows
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count correction requests"})
+		return
+	}
+
+	// Validate sortBy field
+	allowedSortFields := map[string]bool{
+		"id": true, "check_in_time": true, "status": true, "created_at": true,
+	}
+	if !allowedSortFields[params.SortBy] {
+		params.SortBy = "created_at"
+	}
+
+	var corrections []models.AttendanceCorrectionRequest
+	query = utils.ApplyPagination(query, params)
+	if err := query.Find(&corrections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch correction requests"})
+		return
+	}
+
+	// Build paginated response
+	response := utils.BuildPaginatedResponse(corrections, totalRows, params)
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get subordinate attendance corrections
+// @Description Get the attendance correction requests of the current user's subordinates, including those of supervisors who delegated their approval rights. With depth the requests of subordinates further down the reporting tree are included; every request carries the reporting path from the supervisor down to its user.
+// @Tags attendance
+// @Security BearerAuth
+// @Produce json
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
+// @Success 200 {array} models.AttendanceCorrectionRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid depth"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/corrections/subordinates [get]
+// GetSubordinateAttendanceCorrections gets the correction requests of the current user's subordinates
+func GetSubordinateAttendanceCorrections(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	supervisorId := c.MustGet("userId").(uint)
+
+	depth, err := utils.ReportingDepthQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	subordinates, err := utils.GetReportingLines(db, supervisorId, depth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates"})
+		return
+	}
+
+	listAttendanceCorrections(c, db, subordinates)
+}
+
+// listAttendanceCorrections responds with a page of the correction requests of the users
+func listAttendanceCorrections(c *gin.Context, db *gorm.DB, lines utils.ReportingLines) {
+	if len(lines.UserIDs) == 0 {
+		// Return empty paginated response
+		response := utils.BuildPaginatedResponse([]models.AttendanceCorrectionRequest{}, 0, utils.GetPaginationParams(c))
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	// Get pagination params
+	params := utils.GetPaginationParams(c)
+
+	// Build base query
+	query := db.Model(&models.AttendanceCorrectionRequest{}).
+		Where("user_id IN ?", lines.UserIDs).
+		Preload("User").
+		Preload("Attendance").
+		Preload("Reviewer")
+
+	// Apply search if provided
+	if params.Search != "" {
+		searchPattern := "%" + params.Search + "%"
+		query = query.Where("attendance_correction_requests.status LIKE ? OR EXISTS (SELECT 1 FROM users WHERE users.id = attendance_correction_requests.user_id AND users.name LIKE ?)",
+			searchPattern, searchPattern)
+	}
+
+	// Count total rows
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count correction requests"})
+		return
+	}
+
+	// Validate sortBy field
+	allowedSortFields := map[string]bool{
+		"id": true, "check_in_time": true, "status": true, "created_at": true,
+	}
+	if !allowedSortFields[params.SortBy] {
+		params.SortBy = "created_at"
+	}
+
+	var corrections []models.AttendanceCorrectionRequest
+	query = utils.ApplyPagination(query, params)
+	if err := query.Find(&corrections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch correction requests"})
+		return
+	}
+
+	for i := range corrections {
+		corrections[i].ReportingPath = lines.Path(corrections[i].UserID)
+	}
+
+	// Build paginated response
+	response := utils.BuildPaginatedResponse(corrections, totalRows, params)
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get department attendance corrections
+// @Description Get the attendance correction requests of everyone in the departments headed by the current user and in the departments below them (department head only). departmentId limits the requests to one of those departments and its sub-departments.
+// @Tags attendance
+// @Security BearerAuth
+// @Produce json
+// @Param departmentId query int false "Department ID within the headed departments"
+// @Success 200 {array} models.AttendanceCorrectionRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid department ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the head of the department"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/corrections/department [get]
+// GetDepartmentAttendanceCorrections gets the correction requests of the current user's departments
+func GetDepartmentAttendanceCorrections(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	memberIds, ok := departmentMemberIds(c, db)
+	if !ok {
+		return
+	}
+
+	listAttendanceCorrections(c, db, utils.ReportingLines{UserIDs: memberIds})
+}
+
+// @Summary Validate attendance correction
+// @Description Approve or reject a subordinate's attendance correction (supervisor or a user they delegated their approval rights to). Approval applies the corrected times to the attendance record, or creates the missing record, and keeps the original values on the correction request.
+// @Tags attendance
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Correction request ID"
+// @Param validation body CorrectionValidationRequest true "Decision"
+// @Success 200 {object} models.AttendanceCorrectionRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid request or already processed"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the supervisor or a delegate"
+// @Failure 404 {object} map[string]string "Correction request not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/attendance/corrections/validate/{id} [put]
+// ValidateAttendanceCorrection allows supervisors to approve or reject a correction request
+func ValidateAttendanceCorrection(c *gin.Context) {
+	var req CorrectionValidationRequest
+	db := c.MustGet("db").(*gorm.DB)
+	reviewerId := c.MustGet("userId").(uint)
+	correctionId := c.Param("id")
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Status != models.CorrectionApproved && req.Status != models.CorrectionRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be APPROVED or REJECTED"})
+		return
+	}
+
+	// Start transaction for atomic operation
+	tx := db.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The request stays locked until the decision is committed, so a
+	// correction is applied once however many decisions race
+	var correction models.AttendanceCorrectionRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&correction, correctionId).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Correction request not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch correction request"})
+		}
+		return
+	}
+
+	// The supervisor of the requester, or their delegate, decides
+	delegation, allowed, err := utils.CanActForSupervisor(tx, correction.UserID, reviewerId)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approval delegation"})
+		return
+	}
+	if !allowed {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to validate this correction request"})
+		return
+	}
+
+	if correction.Status != models.CorrectionPending {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Correction request has already been processed"})
+		return
+	}
+
+	before := audit.Snapshot(correction)
+	now := time.Now()
+	correction.Status = req.Status
+	correction.ReviewerID = &reviewerId
+	correction.ReviewerNotes = req.ReviewerNotes
+	correction.ReviewedAt = &now
+	if delegation != nil {
+		correction.DelegationID = &delegation.ID
+	}
+
+	if req.Status == models.CorrectionRejected {
+		if err := tx.Save(&correction).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update correction request"})
+			return
+		}
+		if err := audit.Updated(tx, c, audit.EntityAttendanceCorrection, correction.ID, before, correction); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+			return
+		}
+		c.JSON(http.StatusOK, correction)
+		return
+	}
+
+	schedule, err := utils.GetUserWorkSchedule(tx, correction.UserID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
+		return
+	}
+
+	// A missing record is created, an existing one updated
+	var attendanceBefore map[string]interface{}
+	var attendance models.Attendance
+	if correction.AttendanceID != nil {
+		if err := tx.First(&attendance, *correction.AttendanceID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The attendance record no longer exists"})
+			return
+		}
+
+		attendanceBefore = audit.Snapshot(attendance)
+
+		// Keep the original values for the audit record
+		correction.OriginalCheckInTime = attendance.CheckInTime
+		correction.OriginalCheckOutTime = attendance.CheckOutTime
+		correction.OriginalStatus = attendance.Status
+		correction.OriginalValidationStatus = attendance.ValidationStatus
+	} else {
+		windowStart, windowEnd := schedule.AttendanceWindow(schedule.ShiftDate(*correction.CheckInTime))
+		existing, err := findAttendanceInWindow(tx, correction.UserID, windowStart, windowEnd)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing attendance records"})
+			return
+		}
+		if existing != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "An attendance record was recorded for this shift after the request was submitted"})
+			return
+		}
+
+		attendance = models.Attendance{
+			UserID:           correction.UserID,
+			LocationID:       nil, // No location was recorded for the missing check-in
+			ValidationStatus: models.Present,
+		}
+	}
+
+	if correction.CheckInTime != nil {
+		checkInTime := *correction.CheckInTime
+		attendance.CheckInTime = &checkInTime
+
+		// Lateness is re-evaluated for the corrected check-in
+		shiftDate := schedule.ShiftDate(checkInTime)
+		lateAfter, partialLeaves, err := checkInLateAfter(tx, schedule, correction.UserID, shiftDate)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leave requests"})
+			return
+		}
+		attendance.Status = models.OnTime
+		if checkInTime.Truncate(time.Minute).After(lateAfter) {
+			attendance.Status = models.Late
+		}
+		if attendance.LeaveRequestID == nil && len(partialLeaves) > 0 {
+			attendance.LeaveRequestID = &partialLeaves[0].ID
+		}
+	}
+	if correction.CheckOutTime != nil {
+		checkOutTime := *correction.CheckOutTime
+		attendance.CheckOutTime = &checkOutTime
+	}
+
+	// A corrected record counts as present once it has a check-in, like a regular check-out
+	if attendance.ValidationStatus == models.Absent ||
+		(attendance.ValidationStatus == models.DidntCheckout && attendance.CheckOutTime != nil) {
+		attendance.ValidationStatus = models.Present
+	}
+	attendance.ValidatorID = &reviewerId
+	attendance.ValidationDelegationID = correction.DelegationID
+	note := fmt.Sprintf("Corrected by request #%d: %s", correction.ID, correction.Reason)
+	if attendance.Notes != "" {
+		note = attendance.Notes + "\n" + note
+	}
+	attendance.Notes = note
+
+	if err := tx.Save(&attendance).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update attendance record"})
+		return
+	}
+	if attendanceBefore != nil {
+		err = audit.Updated(tx, c, audit.EntityAttendance, attendance.ID, attendanceBefore, attendance)
+	} else {
+		err = audit.Created(tx, c, audit.EntityAttendance, attendance.ID, attendance)
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	correction.AttendanceID = &attendance.ID
+	if err := tx.Save(&correction).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update correction request"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityAttendanceCorrection, correction.ID, before, correction); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	correction.Attendance = &attendance
+	c.JSON(http.StatusOK, correction)
+}
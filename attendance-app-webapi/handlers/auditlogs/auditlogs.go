@@ -0,0 +1,106 @@
+// Package auditlogs exposes the audit log of changes made through the API
+package auditlogs
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// @Summary Get audit logs
+// @Description Query the audit log of creates, updates and deletes, newest first. Filter by entity, actor, action and date range (inclusive, YYYY-MM-DD).
+// @Tags audit
+// @Accept json
+// @Produce json
+// @Param entityType query string false "Entity type (user, role, location, setting, attendance, leave_request, ...)"
+// @Param entityId query int false "Entity ID"
+// @Param actorId query int false "ID of the user who made the change"
+// @Param apiKeyId query int false "ID of the API key the change was made with"
+// @Param action query string false "CREATE, UPDATE or DELETE"
+// @Param from query string false "From date (YYYY-MM-DD)"
+// @Param to query string false "To date (YYYY-MM-DD)"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size (max 100)"
+// @Success 200 {array} models.AuditLogSwagger
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can view audit logs"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/audit-logs [get]
+// @Security BearerAuth
+func GetAuditLogs(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	query := DB.Model(&models.AuditLog{})
+	if entityType := c.Query("entityType"); entityType != "" {
+		query = query.Where("entity_type = ?", entityType)
+	}
+	if entityId := c.Query("entityId"); entityId != "" {
+		query = query.Where("entity_id = ?", entityId)
+	}
+	if actorId := c.Query("actorId"); actorId != "" {
+		query = query.Where("actor_id = ?", actorId)
+	}
+	if apiKeyId := c.Query("apiKeyId"); apiKeyId != "" {
+		query = query.Where("api_key_id = ?", apiKeyId)
+	}
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.ParseInLocation(utils.CalendarDateFormat, from, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date format. Use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("created_at >= ?", fromDate)
+	}
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.ParseInLocation(utils.CalendarDateFormat, to, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date format. Use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("created_at < ?", toDate.AddDate(0, 0, 1))
+	}
+
+	// Get pagination params
+	params := utils.GetPaginationParams(c)
+
+	// Apply search if provided
+	if params.Search != "" {
+		searchPattern := "%" + params.Search + "%"
+		query = query.Where("actor_name LIKE ? OR entity_type LIKE ?", searchPattern, searchPattern)
+	}
+
+	// Count total rows
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count audit logs"})
+		return
+	}
+
+	// Validate sortBy field
+	allowedSortFields := map[string]bool{
+		"id": true, "created_at": true, "entity_type": true, "action": true,
+	}
+	if !allowedSortFields[params.SortBy] {
+		params.SortBy = "id"
+	}
+
+	var logs []models.AuditLog
+	query = utils.ApplyPagination(query, params)
+	if err := query.Find(&logs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch audit logs"})
+		return
+	}
+
+	// Build paginated response
+	response := utils.BuildPaginatedResponse(logs, totalRows, params)
+	c.JSON(http.StatusOK, response)
+}
@@ -4,10 +4,13 @@ import (
 	"attendance-app/blocklist"
 	"attendance-app/config"
 	"attendance-app/models"
+	"attendance-app/security"
 	"attendance-app/utils"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,27 +20,228 @@ import (
 
 var jwtKey = []byte(config.Config("JWT_SECRET_KEY"))
 
+// defaultRefreshTokenLifetime applies when REFRESH_TOKEN_EXP_TIME is not set
+const defaultRefreshTokenLifetime = 30 * 24 * time.Hour
+
 // JWT Datas Template
 type Claims struct {
 	Id       uint   `json:"id"`
 	Username string `json:"username"`
+	// Token version of the user at login, see models.User.TokenVersion
+	TokenVersion uint `json:"ver"`
+	// Session the token was issued for
+	SessionID uint `json:"sid,omitempty"`
 	jwt.RegisteredClaims
 }
 
 type loginPayload struct {
 	Username string `json:"username" binding:"required" example:"john_doe"`
 	Password string `json:"password" binding:"required" example:"secretpassword123"`
+	// Optional name of the device, shown in the list of sessions
+	DeviceName string `json:"deviceName" validate:"max=100" example:"Pixel 8"`
+}
+
+type refreshPayload struct {
+	RefreshToken string `json:"refreshToken" binding:"required" example:"3f1c9a..."`
+}
+
+// TokenResponse is returned on login and refresh
+type TokenResponse struct {
+	// Short-lived access token for the Authorization header
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	// Single-use token to get a new access token from /refresh
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+	SessionID             uint      `json:"sessionId"`
+} //@name TokenResponse
+
+// accessTokenLifetime returns the lifetime of access tokens (JWT_EXP_TIME)
+func accessTokenLifetime() time.Duration {
+	lifetime, err := time.ParseDuration(config.Config("JWT_EXP_TIME"))
+	if err != nil {
+		log.Fatalf("Invalid JWT_EXP_TIME configuration: %v", err)
+	}
+	return lifetime
+}
+
+// refreshTokenLifetime returns the lifetime of refresh tokens (REFRESH_TOKEN_EXP_TIME)
+func refreshTokenLifetime() time.Duration {
+	value := config.Config("REFRESH_TOKEN_EXP_TIME")
+	if value == "" {
+		return defaultRefreshTokenLifetime
+	}
+	lifetime, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid REFRESH_TOKEN_EXP_TIME configuration: %v", err)
+	}
+	return lifetime
+}
+
+// generateAccessToken signs an access token for the user's session and
+// records it on the session so that it can be revoked with it
+func generateAccessToken(user models.User, session *models.UserSession, now time.Time) (string, error) {
+	// The token ID lets a single token be revoked on logout
+	tokenID, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	expirationTime := now.Add(accessTokenLifetime())
+	claims := &Claims{
+		Id:           user.ID,
+		Username:     user.Username,
+		TokenVersion: user.TokenVersion,
+		SessionID:    session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenString, err := token.SignedString(jwtKey)
+	if err != nil {
+		return "", err
+	}
+
+	session.AccessTokenID = tokenID
+	session.AccessTokenExpiresAt = expirationTime
+	return tokenString, nil
+}
+
+// issueRefreshToken stores a new refresh token for the session and returns it with its plain value
+func issueRefreshToken(db *gorm.DB, session *models.UserSession, now time.Time) (models.RefreshToken, string, error) {
+	value, err := utils.RandomToken(32)
+	if err != nil {
+		return models.RefreshToken{}, "", err
+	}
+
+	refreshToken := models.RefreshToken{
+		SessionID: session.ID,
+		TokenHash: utils.HashToken(value),
+		ExpiresAt: now.Add(refreshTokenLifetime()),
+	}
+	if err := db.Create(&refreshToken).Error; err != nil {
+		return models.RefreshToken{}, "", err
+	}
+
+	// The session lasts as long as its latest refresh token
+	session.ExpiresAt = refreshToken.ExpiresAt
+	return refreshToken, value, nil
+}
+
+// startSession creates a session for the user on the requesting device and issues its first tokens
+func startSession(db *gorm.DB, c *gin.Context, user models.User, deviceName string) (TokenResponse, error) {
+	now := time.Now()
+	session := models.UserSession{
+		UserID:     user.ID,
+		DeviceName: deviceName,
+		UserAgent:  truncate(c.Request.UserAgent(), 255),
+		IPAddress:  c.ClientIP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenLifetime()),
+	}
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&session).Error; err != nil {
+		tx.Rollback()
+		return TokenResponse{}, err
+	}
+
+	refreshToken, refreshValue, err := issueRefreshToken(tx, &session, now)
+	if err != nil {
+		tx.Rollback()
+		return TokenResponse{}, err
+	}
+
+	tokenString, err := generateAccessToken(user, &session, now)
+	if err != nil {
+		tx.Rollback()
+		return TokenResponse{}, err
+	}
+
+	if err := tx.Save(&session).Error; err != nil {
+		tx.Rollback()
+		return TokenResponse{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return TokenResponse{}, err
+	}
+
+	return TokenResponse{
+		Token:                 tokenString,
+		ExpiresAt:             session.AccessTokenExpiresAt,
+		RefreshToken:          refreshValue,
+		RefreshTokenExpiresAt: refreshToken.ExpiresAt,
+		SessionID:             session.ID,
+	}, nil
+}
+
+// revokeSession ends the session and revokes its latest access token
+func revokeSession(db *gorm.DB, session *models.UserSession, reason string) error {
+	now := time.Now()
+	session.RevokedAt = &now
+	session.RevokedReason = reason
+	if err := db.Model(session).Updates(map[string]interface{}{
+		"revoked_at":     now,
+		"revoked_reason": reason,
+	}).Error; err != nil {
+		return err
+	}
+
+	if session.AccessTokenID != "" && session.AccessTokenExpiresAt.After(now) {
+		return blocklist.Add(session.AccessTokenID, session.UserID, session.AccessTokenExpiresAt)
+	}
+	return nil
+}
+
+// revokeAllSessions invalidates every access token and session of the user
+func revokeAllSessions(db *gorm.DB, userID uint, reason string) error {
+	// Tokens carry the version they were issued with and are rejected once it changes
+	if err := db.Model(&models.User{}).Where("id = ?", userID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		return err
+	}
+
+	// Refresh tokens would otherwise issue access tokens with the new version
+	return db.Model(&models.UserSession{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"revoked_reason": reason,
+		}).Error
+}
+
+// truncate shortens a value to fit its column
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
 }
 
 // @Summary User login
-// @Description Authenticate user and return JWT token
+// @Description Authenticate user and start a session. Repeated failed logins of a username or from an IP address are delayed with exponential backoff and then locked out for a while. Returns a short-lived access token and a refresh token to renew it. For accounts with two-factor authentication it returns a challenge token instead, to complete the login at /login/2fa.
 // @Tags auth
 // @Accept json
 // @Produce json
 // @Param login body loginPayload true "Login credentials"
-// @Success 200 {object} map[string]string "Returns JWT token"
+// @Success 200 {object} TokenResponse "Returns the access and refresh tokens"
+// @Success 202 {object} TwoFactorChallengeResponse "Two-factor authentication required"
 // @Failure 400 {object} map[string]string "Invalid request payload or validation error"
 // @Failure 401 {object} map[string]string "Invalid username or password"
+// @Failure 403 {object} map[string]string "Account suspended or terminated"
+// @Failure 429 {object} map[string]string "Too many failed login attempts"
+// @Failure 500 {object} map[string]string "Server error"
 // @Router /login [post]
 func Login(c *gin.Context) {
 
@@ -53,66 +257,297 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	log.Printf("Login Request Body: %+v", payload)
-
 	db, _ := c.Get("db")
 	conn := db.(*gorm.DB)
 
+	if !checkLoginThrottle(conn, c, payload.Username) {
+		return
+	}
+
 	var user models.User
 	if err := conn.Where("username = ?", payload.Username).First(&user).Error; err != nil {
+		loginFailed(conn, c, models.SecurityLoginFailed, payload.Username, nil, "Unknown username")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 		return
 	}
 
 	if !utils.CheckPasswordHash(payload.Password, user.Password) {
+		loginFailed(conn, c, models.SecurityLoginFailed, payload.Username, &user.ID, "Invalid password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
 		return
 	}
 
-	jwtExpiryTime, err := time.ParseDuration(config.Config("JWT_EXP_TIME"))
+	if !checkUserActive(conn, c, user) {
+		return
+	}
+
+	// The tokens are only issued once the second factor is checked at /login/2fa
+	if user.TOTPEnabled {
+		challenge, err := startLoginChallenge(conn, user, payload.DeviceName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not start two-factor login"})
+			return
+		}
+		c.JSON(http.StatusAccepted, challenge)
+		return
+	}
+
+	response, err := startSession(conn, c, user, payload.DeviceName)
 	if err != nil {
-		log.Fatalf("Invalid JWT_EXP_TIME configuration: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		return
 	}
-	log.Println(&user)
 
-	expirationTime := time.Now().Add(jwtExpiryTime)
-	claims := &Claims{
-		Id:       user.ID,
-		Username: user.Username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(expirationTime),
-		},
+	if err := security.LoginSucceeded(conn, user.Username); err != nil {
+		log.Printf("Failed to clear failed logins of %s: %v", user.Username, err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(jwtKey)
+	c.JSON(http.StatusOK, response)
+}
+
+// checkLoginThrottle rejects the login with 429 while the username or IP
+// address is backed off or locked out after failed logins
+func checkLoginThrottle(db *gorm.DB, c *gin.Context, username string) bool {
+	wait, err := security.CheckLogin(db, username, c.ClientIP(), time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not check failed logins"})
+		return false
+	}
+	if wait <= 0 {
+		return true
+	}
+
+	if err := security.Record(db, c, models.SecurityLoginBlocked, username, nil, "Login attempted while blocked"); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("too many failed login attempts, try again in %d seconds", seconds)})
+	return false
+}
+
+// checkUserActive rejects the login with 403 when the user is suspended or
+// terminated. It is not a failed login, the credentials were right.
+func checkUserActive(db *gorm.DB, c *gin.Context, user models.User) bool {
+	if user.IsActive(time.Now()) {
+		return true
+	}
+
+	if err := security.Record(db, c, models.SecurityLoginInactive, user.Username, &user.ID, "Login attempted by an inactive user"); err != nil {
+		log.Printf("Failed to record security event: %v", err)
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "account is not active"})
+	return false
+}
+
+// loginFailed counts a failed login; failing to record it must not change the response
+func loginFailed(db *gorm.DB, c *gin.Context, eventType, username string, userID *uint, detail string) {
+	if err := security.LoginFailed(db, c, eventType, username, userID, detail); err != nil {
+		log.Printf("Failed to record failed login: %v", err)
+	}
+}
+
+// @Summary Refresh access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. Each refresh token can be used once; presenting a used one again revokes the whole session. The previous access token of the session stops working.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param refresh body refreshPayload true "Refresh token"
+// @Success 200 {object} TokenResponse "Returns the new access and refresh tokens"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Invalid, expired or reused refresh token, or inactive account"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /refresh [post]
+func Refresh(c *gin.Context) {
+	var payload refreshPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	conn := c.MustGet("db").(*gorm.DB)
+
+	var refreshToken models.RefreshToken
+	if err := conn.Preload("Session").
+		Where("token_hash = ?", utils.HashToken(payload.RefreshToken)).
+		First(&refreshToken).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
+	now := time.Now()
+	session := refreshToken.Session
+	if session == nil || !session.IsActive(now) || !now.Before(refreshToken.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token expired or revoked"})
+		return
+	}
+
+	tx := conn.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only the first use succeeds, also when two refreshes race
+	result := tx.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", refreshToken.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh token"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		// A used token was presented again: either the client or an attacker
+		// holds a stolen copy, so neither may keep the session
+		if err := revokeSession(tx, session, models.SessionTokenReused); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke session"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke session"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, the session has been revoked"})
+		return
+	}
+
+	var user models.User
+	if err := tx.First(&user, session.UserID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+	if !user.IsActive(now) {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "account is not active"})
+		return
+	}
+
+	next, refreshValue, err := issueRefreshToken(tx, session, now)
 	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh token"})
+		return
+	}
+
+	if err := tx.Model(&refreshToken).Update("replaced_by_id", next.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh token"})
+		return
+	}
+
+	// The session only remembers its latest access token, so the one being
+	// replaced is revoked now rather than when the session is revoked
+	if session.AccessTokenID != "" && session.AccessTokenExpiresAt.After(now) {
+		if err := blocklist.Add(session.AccessTokenID, session.UserID, session.AccessTokenExpiresAt); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke previous token"})
+			return
+		}
+	}
+
+	tokenString, err := generateAccessToken(user, session, now)
+	if err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"token": tokenString})
+	session.LastUsedAt = now
+	session.IPAddress = c.ClientIP()
+	session.UserAgent = truncate(c.Request.UserAgent(), 255)
+	if err := tx.Save(session).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh token"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not refresh token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TokenResponse{
+		Token:                 tokenString,
+		ExpiresAt:             session.AccessTokenExpiresAt,
+		RefreshToken:          refreshValue,
+		RefreshTokenExpiresAt: next.ExpiresAt,
+		SessionID:             session.ID,
+	})
 }
 
 // @Summary User logout
-// @Description Invalidate the current JWT token
+// @Description Invalidate the current JWT token and end its session
 // @Tags auth
 // @Security BearerAuth
 // @Accept json
 // @Produce json
 // @Success 200 {object} map[string]string "Successfully logged out"
 // @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
 // @Router /logout [post]
 func Logout(c *gin.Context) {
-	authHeader := c.GetHeader("Authorization")
-	tokenString := strings.TrimPrefix(authHeader, "Bearer ")
+	conn := c.MustGet("db").(*gorm.DB)
 
-	jwtExpiryTime, err := time.ParseDuration(config.Config("JWT_EXP_TIME"))
-	if err != nil {
-		log.Fatalf("Invalid JWT_EXP_TIME configuration: %v", err)
+	tokenID := c.GetString("tokenId")
+	if tokenID != "" {
+		userId := c.MustGet("userId").(uint)
+		expiresAt := c.MustGet("tokenExpiresAt").(time.Time)
+		if err := blocklist.Add(tokenID, userId, expiresAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not revoke token"})
+			return
+		}
 	}
 
-	blocklist.Add(tokenString, jwtExpiryTime)
+	// The session's refresh token must not outlive the logout
+	if sessionId := c.GetUint("sessionId"); sessionId != 0 {
+		var session models.UserSession
+		if err := conn.Where("revoked_at IS NULL").First(&session, sessionId).Error; err == nil {
+			if err := revokeSession(conn, &session, models.SessionLogout); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "could not end session"})
+				return
+			}
+		}
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "successfully logged out"})
 }
+
+// @Summary Log out all sessions
+// @Description Invalidate every JWT token and session of the current user, including the one used for this request
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string "Successfully logged out of all sessions"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /logout/all [post]
+func LogoutAll(c *gin.Context) {
+	conn := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	tx := conn.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := revokeAllSessions(tx, userId, models.SessionLogoutAll); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not log out sessions"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not log out sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "successfully logged out of all sessions"})
+}
@@ -0,0 +1,557 @@
+// Package calendar handles the shared company calendar of holidays and working day overrides
+package calendar
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/storage"
+	"attendance-app/utils"
+)
+
+// maxCalendarRangeDays limits how many days a single calendar query may cover
+const maxCalendarRangeDays = 366
+
+// maxImportedCalendarDays limits how many days a single calendar import may write
+const maxImportedCalendarDays = 3660
+
+// CalendarDayRequest represents the request payload for creating or updating a calendar day
+type CalendarDayRequest struct {
+	Date        string                 `json:"Date" validate:"required" example:"2025-12-25"`
+	Name        string                 `json:"Name" validate:"required,max=255" example:"Christmas Day"`
+	Type        models.CalendarDayType `json:"Type" validate:"required" example:"PUBLIC_HOLIDAY"`
+	Description string                 `json:"Description" validate:"max=1000" example:"National holiday"`
+}
+
+// CalendarImportResponse summarizes the result of an iCalendar import
+type CalendarImportResponse struct {
+	Created int                  `json:"created" example:"14"`
+	Updated int                  `json:"updated" example:"2"`
+	Skipped int                  `json:"skipped" example:"1"`
+	Days    []models.CalendarDay `json:"days"`
+}
+
+// CalendarDayView describes a single day of the calendar for the current user
+type CalendarDayView struct {
+	Date         string                 `json:"date" example:"2025-12-25"`
+	IsWorkingDay bool                   `json:"isWorkingDay" example:"false"`
+	Name         string                 `json:"name,omitempty" example:"Christmas Day"`
+	Type         models.CalendarDayType `json:"type,omitempty" example:"PUBLIC_HOLIDAY"`
+}
+
+// parseCalendarDate parses a YYYY-MM-DD date in the server's local time
+func parseCalendarDate(value string) (time.Time, error) {
+	return time.ParseInLocation(utils.CalendarDateFormat, value, time.Local)
+}
+
+// parseCalendarRange reads the from/to or year query parameters.
+// Without parameters the current year is used.
+func parseCalendarRange(c *gin.Context) (time.Time, time.Time, error) {
+	if c.Query("from") != "" || c.Query("to") != "" {
+		from, err := parseCalendarDate(c.Query("from"))
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid from date format. Use YYYY-MM-DD")
+		}
+		to, err := parseCalendarDate(c.Query("to"))
+		if err != nil {
+			return time.Time{}, time.Time{}, errors.New("invalid to date format. Use YYYY-MM-DD")
+		}
+		if to.Before(from) {
+			return time.Time{}, time.Time{}, errors.New("to date cannot be before from date")
+		}
+		if to.Sub(from) > maxCalendarRangeDays*24*time.Hour {
+			return time.Time{}, time.Time{}, errors.New("date range cannot exceed 366 days")
+		}
+		return from, to, nil
+	}
+
+	year := time.Now().Year()
+	if value := c.Query("year"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1900 || parsed > 9999 {
+			return time.Time{}, time.Time{}, errors.New("invalid year")
+		}
+		year = parsed
+	}
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	return from, from.AddDate(1, 0, -1), nil
+}
+
+// bindCalendarDay validates the request and copies it onto the calendar day
+func bindCalendarDay(req CalendarDayRequest, day *models.CalendarDay) error {
+	date, err := parseCalendarDate(req.Date)
+	if err != nil {
+		return errors.New("invalid date format. Use YYYY-MM-DD")
+	}
+	if !models.IsValidCalendarDayType(req.Type) {
+		return errors.New("type must be PUBLIC_HOLIDAY, COMPANY_HOLIDAY or WORKING_DAY")
+	}
+
+	day.Date = date
+	day.Name = req.Name
+	day.Type = req.Type
+	day.Description = req.Description
+	return nil
+}
+
+// @Summary Get calendar days
+// @Description Retrieve holidays and working day overrides between two dates, or for a whole year (defaults to the current year)
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD)"
+// @Param year query int false "Calendar year, used when from/to are not given"
+// @Success 200 {array} models.CalendarDay
+// @Failure 400 {object} map[string]string "Invalid date range"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can access the calendar"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/calendar [get]
+// @Security BearerAuth
+func GetCalendarDays(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	from, to, err := parseCalendarRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var days []models.CalendarDay
+	if err := DB.Where("date BETWEEN ? AND ?", from.Format(utils.CalendarDateFormat), to.Format(utils.CalendarDateFormat)).
+		Order("date ASC").Find(&days).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar days"})
+		return
+	}
+
+	c.JSON(http.StatusOK, days)
+}
+
+// @Summary Get calendar day by ID
+// @Description Retrieve a single calendar day by its ID
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param id path int true "Calendar day ID"
+// @Success 200 {object} models.CalendarDay
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can access the calendar"
+// @Failure 404 {object} map[string]string "Calendar day not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/calendar/{id} [get]
+// @Security BearerAuth
+func GetCalendarDayByID(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var day models.CalendarDay
+	if err := DB.First(&day, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar day not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar day"})
+		return
+	}
+
+	c.JSON(http.StatusOK, day)
+}
+
+// @Summary Create calendar day
+// @Description Add a holiday or working day override. PUBLIC_HOLIDAY and COMPANY_HOLIDAY are days off for everyone, WORKING_DAY makes a scheduled day off a working day.
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param day body CalendarDayRequest true "Calendar day data"
+// @Success 201 {object} models.CalendarDay
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage the calendar"
+// @Failure 409 {object} map[string]string "A calendar entry already exists for this date"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/calendar [post]
+// @Security BearerAuth
+func CreateCalendarDay(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var req CalendarDayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	day := models.CalendarDay{Source: models.CalendarSourceManual}
+	if err := bindCalendarDay(req, &day); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	DB.Model(&models.CalendarDay{}).Where("date = ?", req.Date).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A calendar entry already exists for this date"})
+		return
+	}
+
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&day).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create calendar day"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityCalendarDay, day.ID, day); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, day)
+}
+
+// @Summary Update calendar day
+// @Description Update an existing holiday or working day override
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param id path int true "Calendar day ID"
+// @Param day body CalendarDayRequest true "Calendar day data"
+// @Success 200 {object} models.CalendarDay
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage the calendar"
+// @Failure 404 {object} map[string]string "Calendar day not found"
+// @Failure 409 {object} map[string]string "A calendar entry already exists for this date"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/calendar/{id} [put]
+// @Security BearerAuth
+func UpdateCalendarDay(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req CalendarDayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var day models.CalendarDay
+	if err := DB.First(&day, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar day not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar day"})
+		return
+	}
+
+	before := audit.Snapshot(day)
+
+	if err := bindCalendarDay(req, &day); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	DB.Model(&models.CalendarDay{}).Where("date = ? AND id != ?", req.Date, day.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A calendar entry already exists for this date"})
+		return
+	}
+
+	// Manual edits detach the day from the imported calendar event
+	day.Source = models.CalendarSourceManual
+
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&day).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update calendar day"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityCalendarDay, day.ID, before, day); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, day)
+}
+
+// @Summary Delete calendar day
+// @Description Remove a holiday or working day override. The day falls back to the work schedules.
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param id path int true "Calendar day ID"
+// @Success 200 {object} map[string]string "Calendar day deleted successfully"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage the calendar"
+// @Failure 404 {object} map[string]string "Calendar day not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/calendar/{id} [delete]
+// @Security BearerAuth
+func DeleteCalendarDay(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var day models.CalendarDay
+	if err := DB.First(&day, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Calendar day not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar day"})
+		return
+	}
+
+	// Hard delete so the date can be added again (the date is unique)
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Unscoped().Delete(&day).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete calendar day"})
+		return
+	}
+	if err := audit.Deleted(tx, c, audit.EntityCalendarDay, day.ID, day); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar day deleted successfully"})
+}
+
+// @Summary Import calendar from iCalendar
+// @Description Bulk import holidays from an iCalendar (.ics) file. Every day covered by an event becomes a calendar day. Days that were imported before are updated; manually created days are only replaced when overwrite is true. An event may span at most 366 days and a file at most 3660 days in total. Recurring events (RRULE or RDATE) are rejected, every occurrence must be a separate event.
+// @Tags calendar
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "iCalendar file (.ics, max 5MB)"
+// @Param type formData string false "Type for the imported days (PUBLIC_HOLIDAY, COMPANY_HOLIDAY, WORKING_DAY)" default(PUBLIC_HOLIDAY)
+// @Param overwrite formData bool false "Replace manually created days on the same date" default(false)
+// @Success 200 {object} CalendarImportResponse
+// @Failure 400 {object} map[string]string "Invalid file"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage the calendar"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/calendar/import [post]
+// @Security BearerAuth
+func ImportCalendar(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Calendar file is required"})
+		return
+	}
+
+	fileStorage := storage.NewLocalStorage(storage.Current.BasePath)
+	if err := fileStorage.ValidateFileType(file.Filename, storage.Current.AllowedTypes["calendar"]); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := fileStorage.ValidateFileSize(file.Size, storage.Current.MaxFileSize); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	dayType := models.CalendarDayType(c.DefaultPostForm("type", string(models.PublicHoliday)))
+	if !models.IsValidCalendarDayType(dayType) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be PUBLIC_HOLIDAY, COMPANY_HOLIDAY or WORKING_DAY"})
+		return
+	}
+	overwrite, _ := strconv.ParseBool(c.DefaultPostForm("overwrite", "false"))
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read calendar file"})
+		return
+	}
+	defer src.Close()
+
+	events, err := utils.ParseICS(src, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar file: " + err.Error()})
+		return
+	}
+
+	totalDays := 0
+	for _, event := range events {
+		totalDays += int(event.End.Sub(event.Start).Hours()/24 + 0.5)
+	}
+	if totalDays > maxImportedCalendarDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid calendar file: events cover more than %d days", maxImportedCalendarDays)})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	response := CalendarImportResponse{Days: []models.CalendarDay{}}
+	for _, event := range events {
+		for _, date := range event.Days() {
+			var day models.CalendarDay
+			err := tx.Where("date = ?", date.Format(utils.CalendarDateFormat)).First(&day).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch calendar days"})
+				return
+			}
+
+			exists := err == nil
+			if exists && day.Source == models.CalendarSourceManual && !overwrite {
+				response.Skipped++
+				continue
+			}
+
+			before := audit.Snapshot(day)
+			day.Date = date
+			day.Name = event.Summary
+			day.Type = dayType
+			day.Description = event.Description
+			day.Source = models.CalendarSourceICS
+			day.ExternalUID = event.UID
+			if day.Name == "" {
+				day.Name = "Holiday"
+			}
+
+			if err := tx.Save(&day).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save calendar day"})
+				return
+			}
+
+			if exists {
+				err = audit.Updated(tx, c, audit.EntityCalendarDay, day.ID, before, day)
+			} else {
+				err = audit.Created(tx, c, audit.EntityCalendarDay, day.ID, day)
+			}
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
+
+			if exists {
+				response.Updated++
+			} else {
+				response.Created++
+			}
+			response.Days = append(response.Days, day)
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get my calendar
+// @Description Get every day between two dates (defaults to the current year) with whether it is a working day for the current user, taking holidays and the user's work schedule into account
+// @Tags calendar
+// @Accept json
+// @Produce json
+// @Param from query string false "Start date (YYYY-MM-DD)"
+// @Param to query string false "End date (YYYY-MM-DD)"
+// @Param year query int false "Calendar year, used when from/to are not given"
+// @Success 200 {array} CalendarDayView
+// @Failure 400 {object} map[string]string "Invalid date range"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/calendar [get]
+// @Security BearerAuth
+func GetMyCalendar(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	from, to, err := parseCalendarRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, err := utils.GetUserWorkSchedule(DB, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
+		return
+	}
+
+	workingCalendar, err := utils.LoadWorkingCalendar(DB, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load calendar"})
+		return
+	}
+
+	days := make([]CalendarDayView, 0, int(to.Sub(from).Hours()/24)+1)
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		view := CalendarDayView{
+			Date:         d.Format(utils.CalendarDateFormat),
+			IsWorkingDay: workingCalendar.IsWorkingDay(schedule, d),
+		}
+		if entry, ok := workingCalendar.Day(d); ok {
+			view.Name = entry.Name
+			view.Type = entry.Type
+		}
+		days = append(days, view)
+	}
+
+	c.JSON(http.StatusOK, days)
+}
@@ -0,0 +1,351 @@
+// Package delegations handles temporary delegation of a supervisor's approval rights
+package delegations
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// DelegationRequest represents the request payload for granting approval rights to another user
+type DelegationRequest struct {
+	// Supervisor whose rights are delegated, required for admins and ignored for users
+	DelegatorID uint   `json:"DelegatorID" example:"3"`
+	DelegateID  uint   `json:"DelegateID" validate:"required" example:"4"`
+	StartDate   string `json:"StartDate" validate:"required" example:"2025-07-01"`
+	EndDate     string `json:"EndDate" validate:"required" example:"2025-07-14"`
+	Reason      string `json:"Reason" validate:"max=1000" example:"Annual leave"`
+}
+
+// MyDelegationsResponse lists the delegations granted by and to the current user
+type MyDelegationsResponse struct {
+	Given    []models.ApprovalDelegation `json:"given"`
+	Received []models.ApprovalDelegation `json:"received"`
+}
+
+// parseDelegationDate parses a YYYY-MM-DD date in the server's local time
+func parseDelegationDate(value string) (time.Time, error) {
+	return time.ParseInLocation(utils.CalendarDateFormat, value, time.Local)
+}
+
+// createDelegation checks the dates and users of the request and stores the delegation of the delegator's rights
+func createDelegation(c *gin.Context, db *gorm.DB, delegatorID uint, req DelegationRequest, createdByID uint) (models.ApprovalDelegation, int, error) {
+	startDate, err := parseDelegationDate(req.StartDate)
+	if err != nil {
+		return models.ApprovalDelegation{}, http.StatusBadRequest, errors.New("invalid start date format. Use YYYY-MM-DD")
+	}
+	endDate, err := parseDelegationDate(req.EndDate)
+	if err != nil {
+		return models.ApprovalDelegation{}, http.StatusBadRequest, errors.New("invalid end date format. Use YYYY-MM-DD")
+	}
+	if endDate.Before(startDate) {
+		return models.ApprovalDelegation{}, http.StatusBadRequest, errors.New("end date cannot be before start date")
+	}
+	if endDate.Before(utils.LocalDate(time.Now())) {
+		return models.ApprovalDelegation{}, http.StatusBadRequest, errors.New("end date cannot be in the past")
+	}
+
+	if req.DelegateID == delegatorID {
+		return models.ApprovalDelegation{}, http.StatusBadRequest, errors.New("cannot delegate approval rights to yourself")
+	}
+
+	var delegator, delegate models.User
+	if err := db.First(&delegator, delegatorID).Error; err != nil {
+		return models.ApprovalDelegation{}, http.StatusNotFound, errors.New("delegator not found")
+	}
+	if err := db.First(&delegate, req.DelegateID).Error; err != nil {
+		return models.ApprovalDelegation{}, http.StatusNotFound, errors.New("delegate not found")
+	}
+
+	delegation := models.ApprovalDelegation{
+		DelegatorID: delegatorID,
+		DelegateID:  req.DelegateID,
+		StartDate:   startDate,
+		EndDate:     endDate,
+		Reason:      req.Reason,
+		CreatedByID: &createdByID,
+	}
+
+	// Save the delegation and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&delegation).Error; err != nil {
+		tx.Rollback()
+		return models.ApprovalDelegation{}, http.StatusInternalServerError, errors.New("failed to create delegation")
+	}
+	if err := audit.Created(tx, c, audit.EntityDelegation, delegation.ID, delegation); err != nil {
+		tx.Rollback()
+		return models.ApprovalDelegation{}, http.StatusInternalServerError, errors.New("failed to record audit log")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return models.ApprovalDelegation{}, http.StatusInternalServerError, errors.New("failed to commit changes")
+	}
+
+	delegation.Delegator = &delegator
+	delegation.Delegate = &delegate
+	return delegation, http.StatusCreated, nil
+}
+
+// revokeDelegation ends a delegation immediately
+func revokeDelegation(c *gin.Context, db *gorm.DB, delegation *models.ApprovalDelegation) (int, error) {
+	if delegation.RevokedAt != nil {
+		return http.StatusBadRequest, errors.New("delegation has already been revoked")
+	}
+
+	before := audit.Snapshot(delegation)
+	now := time.Now()
+	delegation.RevokedAt = &now
+
+	// Save the revocation and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Model(delegation).Update("revoked_at", now).Error; err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, errors.New("failed to revoke delegation")
+	}
+	if err := audit.Updated(tx, c, audit.EntityDelegation, delegation.ID, before, delegation); err != nil {
+		tx.Rollback()
+		return http.StatusInternalServerError, errors.New("failed to record audit log")
+	}
+	if err := tx.Commit().Error; err != nil {
+		return http.StatusInternalServerError, errors.New("failed to commit changes")
+	}
+	return http.StatusOK, nil
+}
+
+// @Summary Get my delegations
+// @Description Get the approval delegations granted by and to the current user
+// @Tags delegations
+// @Accept json
+// @Produce json
+// @Success 200 {object} MyDelegationsResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/delegations [get]
+// @Security BearerAuth
+func GetMyDelegations(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var response MyDelegationsResponse
+	if err := DB.Where("delegator_id = ?", userId).Preload("Delegate").
+		Order("start_date DESC").Find(&response.Given).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegations"})
+		return
+	}
+	if err := DB.Where("delegate_id = ?", userId).Preload("Delegator").
+		Order("start_date DESC").Find(&response.Received).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Delegate my approval rights
+// @Description Let another user approve leave and validate attendance of your subordinates for a date range, e.g. while you are on leave. Decisions made by the delegate record the delegation.
+// @Tags delegations
+// @Accept json
+// @Produce json
+// @Param delegation body DelegationRequest true "Delegation data"
+// @Success 201 {object} models.ApprovalDelegation
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Delegate not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/delegations [post]
+// @Security BearerAuth
+func CreateMyDelegation(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var req DelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	delegation, status, err := createDelegation(c, DB, userId, req, userId)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(status, delegation)
+}
+
+// @Summary Revoke my delegation
+// @Description End a delegation of your approval rights immediately
+// @Tags delegations
+// @Accept json
+// @Produce json
+// @Param id path int true "Delegation ID"
+// @Success 200 {object} models.ApprovalDelegation
+// @Failure 400 {object} map[string]string "Delegation already revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Delegation not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/delegations/{id} [delete]
+// @Security BearerAuth
+func RevokeMyDelegation(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+	id := c.Param("id")
+
+	var delegation models.ApprovalDelegation
+	if err := DB.Where("delegator_id = ?", userId).First(&delegation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delegation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegation"})
+		return
+	}
+
+	if status, err := revokeDelegation(c, DB, &delegation); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delegation)
+}
+
+// @Summary Get delegations
+// @Description List approval delegations, optionally filtered by delegator, delegate or only those active today
+// @Tags delegations
+// @Accept json
+// @Produce json
+// @Param delegatorId query int false "Filter by delegator ID"
+// @Param delegateId query int false "Filter by delegate ID"
+// @Param active query bool false "Only delegations in effect today"
+// @Success 200 {array} models.ApprovalDelegation
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage delegations"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/delegations [get]
+// @Security BearerAuth
+func GetDelegations(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	query := DB.Model(&models.ApprovalDelegation{})
+	if delegatorId := c.Query("delegatorId"); delegatorId != "" {
+		query = query.Where("delegator_id = ?", delegatorId)
+	}
+	if delegateId := c.Query("delegateId"); delegateId != "" {
+		query = query.Where("delegate_id = ?", delegateId)
+	}
+	if c.Query("active") == "true" {
+		today := time.Now().Format(utils.CalendarDateFormat)
+		query = query.Where("revoked_at IS NULL AND start_date <= ? AND end_date >= ?", today, today)
+	}
+
+	var delegations []models.ApprovalDelegation
+	if err := query.Preload("Delegator").Preload("Delegate").
+		Order("start_date DESC").Find(&delegations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delegations)
+}
+
+// @Summary Create delegation
+// @Description Delegate a supervisor's approval rights to another user on the supervisor's behalf
+// @Tags delegations
+// @Accept json
+// @Produce json
+// @Param delegation body DelegationRequest true "Delegation data"
+// @Success 201 {object} models.ApprovalDelegation
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage delegations"
+// @Failure 404 {object} map[string]string "Delegator or delegate not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/delegations [post]
+// @Security BearerAuth
+func CreateDelegation(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var req DelegationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	if req.DelegatorID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "DelegatorID is required"})
+		return
+	}
+
+	delegation, status, err := createDelegation(c, DB, req.DelegatorID, req, userId)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(status, delegation)
+}
+
+// @Summary Revoke delegation
+// @Description End an approval delegation immediately
+// @Tags delegations
+// @Accept json
+// @Produce json
+// @Param id path int true "Delegation ID"
+// @Success 200 {object} models.ApprovalDelegation
+// @Failure 400 {object} map[string]string "Delegation already revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage delegations"
+// @Failure 404 {object} map[string]string "Delegation not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/delegations/{id} [delete]
+// @Security BearerAuth
+func RevokeDelegation(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var delegation models.ApprovalDelegation
+	if err := DB.First(&delegation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Delegation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegation"})
+		return
+	}
+
+	if status, err := revokeDelegation(c, DB, &delegation); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, delegation)
+}
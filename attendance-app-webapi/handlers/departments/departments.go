@@ -0,0 +1,676 @@
+package departments
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// DepartmentRequest represents the request payload for creating or updating a department
+type DepartmentRequest struct {
+	Name       string `json:"Name" validate:"required,max=255" example:"Finance"`
+	Code       string `json:"Code" validate:"required,max=50" example:"FIN"`
+	CostCentre string `json:"CostCentre" validate:"max=50" example:"CC-1200"`
+	// ParentID places the department below another one, omit it for a top-level department
+	ParentID *uint `json:"ParentID,omitempty" example:"1"`
+	// HeadID is the user who heads the department, omit it to leave the department without a head
+	HeadID *uint `json:"HeadID,omitempty" example:"3"`
+}
+
+// DepartmentAssignmentRequest represents the users to assign to or unassign from a department
+type DepartmentAssignmentRequest struct {
+	UserIDs []uint `json:"UserIDs" validate:"required,min=1" example:"2,3"`
+}
+
+// validateDepartmentRequest checks the parent and head of a department. The
+// parent may not be the department itself or one of the departments below it.
+// It returns the message for a bad request, or an error when the check failed.
+func validateDepartmentRequest(db *gorm.DB, departmentID uint, req DepartmentRequest) (string, error) {
+	var count int64
+	if err := db.Unscoped().Model(&models.Department{}).
+		Where("code = ? AND id != ?", req.Code, departmentID).
+		Count(&count).Error; err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "Department code already exists", nil
+	}
+
+	if req.ParentID != nil {
+		if err := db.Select("id").First(&models.Department{}, *req.ParentID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "Parent department not found", nil
+			}
+			return "", err
+		}
+		if departmentID != 0 {
+			inSubtree, err := utils.IsDepartmentInSubtree(db, departmentID, *req.ParentID)
+			if err != nil {
+				return "", err
+			}
+			if inSubtree {
+				return "A department cannot be placed below itself or one of its sub-departments", nil
+			}
+		}
+	}
+
+	if req.HeadID != nil {
+		if err := db.Select("id").First(&models.User{}, *req.HeadID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return "Department head not found", nil
+			}
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// preloadHead loads the department head without sensitive fields
+func preloadHead(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "username", "name", "email", "department_id")
+}
+
+// buildDepartmentTree nests the departments under their parents and returns the top-level ones
+func buildDepartmentTree(departments []models.Department) []models.Department {
+	children := make(map[uint][]models.Department)
+	known := make(map[uint]bool)
+	for _, department := range departments {
+		known[department.ID] = true
+	}
+	var roots []models.Department
+	for _, department := range departments {
+		if department.ParentID != nil && known[*department.ParentID] {
+			children[*department.ParentID] = append(children[*department.ParentID], department)
+		} else {
+			roots = append(roots, department)
+		}
+	}
+
+	var attach func(list []models.Department) []models.Department
+	attach = func(list []models.Department) []models.Department {
+		for i := range list {
+			list[i].Children = attach(children[list[i].ID])
+		}
+		return list
+	}
+	return attach(roots)
+}
+
+// @Summary Get departments
+// @Description List all departments ordered by name, or as a tree of top-level departments with their sub-departments nested under Children when tree=true
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param tree query bool false "Return the departments as a tree"
+// @Success 200 {array} models.DepartmentSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.read"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments [get]
+// @Security BearerAuth
+func GetDepartments(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var departments []models.Department
+	if err := DB.Preload("Head", preloadHead).Order("name ASC").Find(&departments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch departments"})
+		return
+	}
+
+	if c.Query("tree") == "true" {
+		tree := buildDepartmentTree(departments)
+		if tree == nil {
+			tree = []models.Department{}
+		}
+		c.JSON(http.StatusOK, tree)
+		return
+	}
+
+	c.JSON(http.StatusOK, departments)
+}
+
+// @Summary Get department by ID
+// @Description Retrieve a department with its head and direct sub-departments
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path int true "Department ID"
+// @Success 200 {object} models.DepartmentSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.read"
+// @Failure 404 {object} map[string]string "Department not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments/{id} [get]
+// @Security BearerAuth
+func GetDepartmentByID(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var department models.Department
+	if err := DB.Preload("Head", preloadHead).
+		Preload("Children", func(db *gorm.DB) *gorm.DB {
+			return db.Order("name ASC")
+		}).
+		First(&department, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+
+	c.JSON(http.StatusOK, department)
+}
+
+// @Summary Create department
+// @Description Create a department, optionally below a parent department and with a head
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param department body DepartmentRequest true "Department data"
+// @Success 201 {object} models.DepartmentSwagger
+// @Failure 400 {object} map[string]string "Invalid request, duplicate code or unknown parent or head"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.manage"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments [post]
+// @Security BearerAuth
+func CreateDepartment(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	message, err := validateDepartmentRequest(DB, 0, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate department"})
+		return
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	department := models.Department{
+		Name:       req.Name,
+		Code:       req.Code,
+		CostCentre: req.CostCentre,
+		ParentID:   req.ParentID,
+		HeadID:     req.HeadID,
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&department).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create department"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityDepartment, department.ID, department); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, department)
+}
+
+// @Summary Update department
+// @Description Replace the name, code, cost centre, parent and head of a department. A department cannot be moved below itself or one of its sub-departments.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path int true "Department ID"
+// @Param department body DepartmentRequest true "Department data"
+// @Success 200 {object} models.DepartmentSwagger
+// @Failure 400 {object} map[string]string "Invalid request, duplicate code, unknown parent or head, or cyclic parent"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.manage"
+// @Failure 404 {object} map[string]string "Department not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments/{id} [put]
+// @Security BearerAuth
+func UpdateDepartment(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req DepartmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	req.Code = strings.TrimSpace(req.Code)
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var department models.Department
+	if err := DB.First(&department, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+
+	message, err := validateDepartmentRequest(DB, department.ID, req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate department"})
+		return
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": message})
+		return
+	}
+
+	before := audit.Snapshot(department)
+	department.Name = req.Name
+	department.Code = req.Code
+	department.CostCentre = req.CostCentre
+	department.ParentID = req.ParentID
+	department.HeadID = req.HeadID
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&department).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityDepartment, department.ID, before, department); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, department)
+}
+
+// @Summary Delete department
+// @Description Soft delete a department. Departments that still have sub-departments or members cannot be deleted.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path int true "Department ID"
+// @Success 200 {object} map[string]string "Department deleted successfully"
+// @Failure 400 {object} map[string]string "Department has sub-departments or members"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.manage"
+// @Failure 404 {object} map[string]string "Department not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments/{id} [delete]
+// @Security BearerAuth
+func DeleteDepartment(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var department models.Department
+	if err := DB.First(&department, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+
+	var childCount int64
+	if err := DB.Model(&models.Department{}).Where("parent_id = ?", department.ID).Count(&childCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check sub-departments"})
+		return
+	}
+	if childCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a department with sub-departments"})
+		return
+	}
+
+	var memberCount int64
+	if err := DB.Model(&models.User{}).Where("department_id = ?", department.ID).Count(&memberCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check department members"})
+		return
+	}
+	if memberCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete a department with members"})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Soft delete
+	if err := tx.Delete(&department).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete department"})
+		return
+	}
+	if err := audit.Deleted(tx, c, audit.EntityDepartment, department.ID, department); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Department deleted successfully"})
+}
+
+// @Summary Get department members
+// @Description List the users assigned to a department, or to the department and every department below it when subtree=true
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path int true "Department ID"
+// @Param subtree query bool false "Include the members of sub-departments"
+// @Success 200 {array} models.UserSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.read"
+// @Failure 404 {object} map[string]string "Department not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments/{id}/members [get]
+// @Security BearerAuth
+func GetDepartmentMembers(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var department models.Department
+	if err := DB.First(&department, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+
+	departmentIDs := []uint{department.ID}
+	if c.Query("subtree") == "true" {
+		var err error
+		if departmentIDs, err = utils.GetDepartmentSubtreeIDs(DB, department.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-departments"})
+			return
+		}
+	}
+
+	members, err := findMembers(DB, "department_id IN ?", departmentIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// findMembers loads the users matching the condition without sensitive data
+func findMembers(db *gorm.DB, condition string, ids []uint) ([]models.User, error) {
+	members := []models.User{}
+	if len(ids) == 0 {
+		return members, nil
+	}
+	if err := db.Where(condition, ids).
+		Preload("Role").
+		Preload("Department").
+		Order("name ASC").
+		Find(&members).Error; err != nil {
+		return nil, err
+	}
+
+	// Clear sensitive data
+	for i := range members {
+		members[i].Password = ""
+	}
+	return members, nil
+}
+
+// @Summary Assign users to department
+// @Description Move users into a department. A user belongs to one department at a time, so this replaces their current department.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param id path int true "Department ID"
+// @Param assignment body DepartmentAssignmentRequest true "Users to assign"
+// @Success 200 {object} map[string]interface{} "Users assigned successfully"
+// @Failure 400 {object} map[string]string "Invalid request or unknown users"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.manage"
+// @Failure 404 {object} map[string]string "Department not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments/{id}/assign [post]
+// @Security BearerAuth
+func AssignDepartment(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var department models.Department
+	if err := DB.First(&department, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Department not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department"})
+		return
+	}
+
+	updateDepartmentAssignments(c, &department.ID)
+}
+
+// @Summary Unassign users from their department
+// @Description Remove users from whatever department they belong to
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param assignment body DepartmentAssignmentRequest true "Users to unassign"
+// @Success 200 {object} map[string]interface{} "Users unassigned successfully"
+// @Failure 400 {object} map[string]string "Invalid request or unknown users"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires departments.manage"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/departments/unassign [post]
+// @Security BearerAuth
+func UnassignDepartment(c *gin.Context) {
+	updateDepartmentAssignments(c, nil)
+}
+
+// updateDepartmentAssignments sets the department of the requested users, nil removes it
+func updateDepartmentAssignments(c *gin.Context, departmentID *uint) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var req DepartmentAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var users []struct {
+		ID           uint
+		DepartmentID *uint
+	}
+	if err := DB.Model(&models.User{}).Where("id IN ?", req.UserIDs).
+		Select("id, department_id").Scan(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return
+	}
+	if len(users) != len(uniqueIDs(req.UserIDs)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "One or more users not found"})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	result := tx.Model(&models.User{}).Where("id IN ?", req.UserIDs).Update("department_id", departmentID)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update department assignments"})
+		return
+	}
+
+	for _, user := range users {
+		before := map[string]interface{}{"DepartmentID": user.DepartmentID}
+		after := map[string]interface{}{"DepartmentID": departmentID}
+		if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, after); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	message := "Users unassigned from department successfully"
+	if departmentID != nil {
+		message = "Users assigned to department successfully"
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message, "usersUpdated": result.RowsAffected})
+}
+
+// uniqueIDs removes duplicate IDs from a list
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool)
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// @Summary Get my department members
+// @Description List the users in the departments headed by the current user and in every department below them. departmentId limits the list to one of those departments and its sub-departments.
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Param departmentId query int false "Department ID within the headed departments"
+// @Success 200 {array} models.UserSwagger
+// @Failure 400 {object} map[string]string "Invalid department ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the head of the department"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/department/members [get]
+// @Security BearerAuth
+func GetMyDepartmentMembers(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	departmentID, err := utils.DepartmentQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return
+	}
+
+	memberIds, err := utils.GetHeadedDepartmentMemberIDs(DB, userId, departmentID)
+	if errors.Is(err, utils.ErrNotDepartmentHead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not the head of the department"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department members"})
+		return
+	}
+
+	members, err := findMembers(DB, "id IN ?", memberIds)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department members"})
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// @Summary Get my departments
+// @Description List the departments headed by the current user and every department below them
+// @Tags departments
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.DepartmentSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/department [get]
+// @Security BearerAuth
+func GetMyDepartments(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	departmentIds, err := utils.GetHeadedDepartmentIDs(DB, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch departments"})
+		return
+	}
+
+	departments := []models.Department{}
+	if len(departmentIds) > 0 {
+		if err := DB.Where("id IN ?", departmentIds).
+			Preload("Head", preloadHead).
+			Order("name ASC").
+			Find(&departments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch departments"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, departments)
+}
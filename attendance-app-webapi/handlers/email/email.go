@@ -7,6 +7,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"attendance-app/models"
+	"attendance-app/utils"
 	"attendance-app/utils/email"
 
 	"gorm.io/gorm"
@@ -85,7 +86,7 @@ func TestEmail(c *gin.Context) {
 }
 
 // @Summary Send reminder to all users
-// @Description Send clock-in or clock-out reminder to all users in the database (Admin only)
+// @Description Send clock-in or clock-out reminder to all active users in the database (Admin only)
 // @Tags email
 // @Accept json
 // @Produce json
@@ -106,9 +107,9 @@ func SendReminderToAll(c *gin.Context) {
 		return
 	}
 
-	// Get all user emails
+	// Get the emails of all active users
 	var users []models.User
-	if err := DB.Where("email IS NOT NULL AND email != ''").Find(&users).Error; err != nil {
+	if err := utils.WhereUserActive(DB, time.Now()).Where("email IS NOT NULL AND email != ''").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
@@ -0,0 +1,347 @@
+package leave
+
+import (
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ApprovalChainRuleRequest represents the request payload for creating or updating an approval chain rule
+type ApprovalChainRuleRequest struct {
+	// Leave type code the rule applies to; empty applies to every type
+	LeaveType models.LeaveType `json:"LeaveType" validate:"max=20" example:"ANNUAL"`
+	// Number of leave days from which the rule applies
+	MinDays float64 `json:"MinDays" validate:"min=0,max=366" example:"5"`
+	// Number of supervisor levels that must approve
+	Levels      uint   `json:"Levels" validate:"required,min=1,max=10" example:"2"`
+	Description string `json:"Description" validate:"max=1000" example:"Leaves of a week or more need the manager's manager"`
+} //@name ApprovalChainRuleRequest
+
+// applyApprovalChainRuleRequest validates the request and copies it onto the rule
+func applyApprovalChainRuleRequest(db *gorm.DB, req ApprovalChainRuleRequest, rule *models.ApprovalChainRule) (int, error) {
+	leaveType := models.LeaveType(strings.ToUpper(strings.TrimSpace(string(req.LeaveType))))
+	if leaveType != "" {
+		if _, err := utils.GetLeaveTypeDefinition(db, leaveType); err != nil {
+			if errors.Is(err, utils.ErrUnknownLeaveType) {
+				return http.StatusBadRequest, errors.New("invalid leave type")
+			}
+			return http.StatusInternalServerError, errors.New("failed to load leave type")
+		}
+	}
+
+	rule.LeaveType = leaveType
+	rule.MinDays = req.MinDays
+	rule.Levels = req.Levels
+	rule.Description = req.Description
+	return 0, nil
+}
+
+// @Summary Get leave requests awaiting my approval
+// @Description Get the pending leave requests whose current approval step is assigned to the current user or to a supervisor who delegated their approval rights to them. Holders of the leave.approve.any permission can pass all=true to list every pending request.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param all query bool false "List every pending request (requires leave.approve.any)"
+// @Success 200 {array} models.LeaveRequestSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - all=true requires leave.approve.any"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/approvals [get]
+// @Security BearerAuth
+func GetPendingApprovals(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	// Steps of supervisors who delegated their approval rights are included
+	approverIds, err := utils.GetDelegatorIDs(db, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch approval delegations"})
+		return
+	}
+	approverIds = append(approverIds, userId)
+
+	// Holders of leave.approve.any can decide every request
+	all := false
+	if c.Query("all") == "true" {
+		if all, err = utils.HasPermission(db, userId, models.PermLeaveApproveAny); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+		if !all {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to view every pending approval"})
+			return
+		}
+	}
+
+	// Get pagination params
+	params := utils.GetPaginationParams(c)
+
+	// Requests where the user or a delegator holds the lowest undecided step
+	query := db.Model(&models.LeaveRequest{}).
+		Joins("JOIN leave_approval_steps ON leave_approval_steps.leave_request_id = leave_requests.id "+
+			"AND leave_approval_steps.deleted_at IS NULL").
+		Where("leave_requests.status = ? AND leave_approval_steps.status = ?",
+			models.LeavePending, models.ApprovalStepPending)
+	if !all {
+		query = query.Where("leave_approval_steps.approver_id IN ?", approverIds)
+	}
+	query = query.
+		Where("leave_requests.user_id != ?", userId).
+		Where("NOT EXISTS (SELECT 1 FROM leave_approval_steps earlier "+
+			"WHERE earlier.leave_request_id = leave_requests.id AND earlier.status = ? "+
+			"AND earlier.level < leave_approval_steps.level AND earlier.deleted_at IS NULL)",
+			models.ApprovalStepPending).
+		Preload("User").
+		Preload("ApprovalSteps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("level ASC")
+		})
+
+	// Apply search if provided
+	if params.Search != "" {
+		searchPattern := "%" + params.Search + "%"
+		query = query.Joins("LEFT JOIN users ON leave_requests.user_id = users.id").
+			Where("users.name LIKE ? OR leave_requests.leave_type LIKE ?", searchPattern, searchPattern)
+	}
+
+	// Count total rows
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count leave requests"})
+		return
+	}
+
+	// Validate sortBy field
+	allowedSortFields := map[string]bool{
+		"id": true, "start_date": true, "end_date": true, "leave_type": true, "created_at": true,
+	}
+	if !allowedSortFields[params.SortBy] {
+		params.SortBy = "start_date"
+	}
+
+	// Apply pagination and sorting
+	params.SortBy = "leave_requests." + params.SortBy
+	var leaveRequests []models.LeaveRequest
+	query = utils.ApplyPagination(query, params)
+	if err := query.Find(&leaveRequests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave requests"})
+		return
+	}
+
+	// Build paginated response
+	response := utils.BuildPaginatedResponse(leaveRequests, totalRows, params)
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get approval chain rules
+// @Description List the rules that decide how many supervisor levels must approve a leave request
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.ApprovalChainRule
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage approval rules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/approval-rules [get]
+// @Security BearerAuth
+func GetApprovalChainRules(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var rules []models.ApprovalChainRule
+	if err := db.Order("leave_type ASC, min_days ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch approval rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// @Summary Create approval chain rule
+// @Description Require approval from several supervisor levels for leave requests of at least MinDays days. When several rules match a request, the highest Levels applies. Rules apply to requests submitted afterwards.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param rule body ApprovalChainRuleRequest true "Approval rule data"
+// @Success 201 {object} models.ApprovalChainRule
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage approval rules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/approval-rules [post]
+// @Security BearerAuth
+func CreateApprovalChainRule(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var req ApprovalChainRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var rule models.ApprovalChainRule
+	if status, err := applyApprovalChainRuleRequest(db, req, &rule); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&rule).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create approval rule"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityApprovalRule, rule.ID, rule); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// @Summary Update approval chain rule
+// @Description Update an approval chain rule. Requests already submitted keep their approval steps.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path int true "Approval rule ID"
+// @Param rule body ApprovalChainRuleRequest true "Approval rule data"
+// @Success 200 {object} models.ApprovalChainRule
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage approval rules"
+// @Failure 404 {object} map[string]string "Approval rule not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/approval-rules/{id} [put]
+// @Security BearerAuth
+func UpdateApprovalChainRule(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req ApprovalChainRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var rule models.ApprovalChainRule
+	if err := db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Approval rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch approval rule"})
+		return
+	}
+
+	before := audit.Snapshot(rule)
+
+	if status, err := applyApprovalChainRuleRequest(db, req, &rule); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&rule).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval rule"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityApprovalRule, rule.ID, before, rule); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// @Summary Delete approval chain rule
+// @Description Delete an approval chain rule. Requests already submitted keep their approval steps.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path int true "Approval rule ID"
+// @Success 200 {object} map[string]string "Approval rule deleted successfully"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage approval rules"
+// @Failure 404 {object} map[string]string "Approval rule not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/approval-rules/{id} [delete]
+// @Security BearerAuth
+func DeleteApprovalChainRule(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var rule models.ApprovalChainRule
+	if err := db.First(&rule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Approval rule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch approval rule"})
+		return
+	}
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Delete(&rule).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete approval rule"})
+		return
+	}
+	if err := audit.Deleted(tx, c, audit.EntityApprovalRule, rule.ID, rule); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Approval rule deleted successfully"})
+}
@@ -0,0 +1,508 @@
+package leave
+
+import (
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LeaveEntitlementRequest represents the request payload for creating or updating a leave entitlement
+type LeaveEntitlementRequest struct {
+	UserID         uint                 `json:"UserID" validate:"required" example:"2"`
+	LeaveType      models.LeaveType     `json:"LeaveType" validate:"required,max=20" example:"ANNUAL"`
+	Year           int                  `json:"Year" validate:"required,min=2000,max=9999" example:"2025"`
+	AnnualDays     float64              `json:"AnnualDays" validate:"min=0,max=366" example:"12"`
+	AccrualMethod  models.AccrualMethod `json:"AccrualMethod" validate:"required" example:"MONTHLY"`
+	CarryOverLimit float64              `json:"CarryOverLimit" validate:"min=0,max=366" example:"5"`
+} //@name LeaveEntitlementRequest
+
+// LeaveAdjustmentRequest represents a manual correction of a leave balance
+type LeaveAdjustmentRequest struct {
+	UserID    uint             `json:"UserID" validate:"required" example:"2"`
+	LeaveType models.LeaveType `json:"LeaveType" validate:"required,max=20" example:"ANNUAL"`
+	Year      int              `json:"Year" validate:"required,min=2000,max=9999" example:"2025"`
+	// Days to add (positive) or remove (negative)
+	Days        float64 `json:"Days" validate:"required" example:"-1.5"`
+	Description string  `json:"Description" validate:"required,max=1000" example:"Correction for unrecorded leave in March"`
+} //@name LeaveAdjustmentRequest
+
+// LeaveBalancesResponse represents a user's balances and ledger history for a year
+type LeaveBalancesResponse struct {
+	Year     int                       `json:"year" example:"2025"`
+	Balances []utils.LeaveBalance      `json:"balances"`
+	Ledger   []models.LeaveLedgerEntry `json:"ledger"`
+} //@name LeaveBalancesResponse
+
+// parseBalanceYear reads the year query parameter, defaulting to the current year
+func parseBalanceYear(c *gin.Context) (int, error) {
+	value := c.Query("year")
+	if value == "" {
+		return time.Now().Year(), nil
+	}
+	year, err := strconv.Atoi(value)
+	if err != nil || year < 2000 || year > 9999 {
+		return 0, errors.New("invalid year")
+	}
+	return year, nil
+}
+
+// loadLeaveBalances builds the balances and ledger response for a user and year
+func loadLeaveBalances(db *gorm.DB, userID uint, year int) (LeaveBalancesResponse, error) {
+	balances, err := utils.GetLeaveBalances(db, userID, year)
+	if err != nil {
+		return LeaveBalancesResponse{}, err
+	}
+
+	var ledger []models.LeaveLedgerEntry
+	if err := db.Where("user_id = ? AND year = ?", userID, year).
+		Order("created_at DESC, id DESC").Find(&ledger).Error; err != nil {
+		return LeaveBalancesResponse{}, err
+	}
+
+	return LeaveBalancesResponse{Year: year, Balances: balances, Ledger: ledger}, nil
+}
+
+// @Summary Get my leave balances
+// @Description Get the current user's leave balance for each leave type with an entitlement, and the ledger of accruals, carry-overs, deductions and adjustments
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param year query int false "Year (defaults to the current year)"
+// @Success 200 {object} LeaveBalancesResponse
+// @Failure 400 {object} map[string]string "Invalid year"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/balances [get]
+// @Security BearerAuth
+func GetMyLeaveBalances(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	year, err := parseBalanceYear(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	response, err := loadLeaveBalances(db, userId, year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave balances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get a user's leave balances
+// @Description Get a user's leave balances and ledger history for a year
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param year query int false "Year (defaults to the current year)"
+// @Success 200 {object} LeaveBalancesResponse
+// @Failure 400 {object} map[string]string "Invalid year"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can view other users' balances"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/balances/{userId} [get]
+// @Security BearerAuth
+func GetUserLeaveBalances(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	userId, err := strconv.ParseUint(c.Param("userId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	year, err := parseBalanceYear(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := db.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	response, err := loadLeaveBalances(db, user.ID, year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave balances"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get leave entitlements
+// @Description List leave entitlements, optionally filtered by user and year
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param userId query int false "Filter by user ID"
+// @Param year query int false "Filter by year"
+// @Success 200 {array} models.LeaveEntitlement
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage entitlements"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/entitlements [get]
+// @Security BearerAuth
+func GetLeaveEntitlements(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	query := db.Model(&models.LeaveEntitlement{})
+	if userId := c.Query("userId"); userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+	if year := c.Query("year"); year != "" {
+		query = query.Where("year = ?", year)
+	}
+
+	var entitlements []models.LeaveEntitlement
+	if err := query.Order("year DESC, user_id ASC, leave_type ASC").Find(&entitlements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave entitlements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entitlements)
+}
+
+// validateEntitlementRequest checks the request, that the leave type counts
+// against a balance and that the user exists
+func validateEntitlementRequest(db *gorm.DB, req LeaveEntitlementRequest) (int, error) {
+	if !models.IsValidAccrualMethod(req.AccrualMethod) {
+		return http.StatusBadRequest, errors.New("AccrualMethod must be YEARLY or MONTHLY")
+	}
+
+	definition, err := utils.GetLeaveTypeDefinition(db, req.LeaveType)
+	if err != nil {
+		if errors.Is(err, utils.ErrUnknownLeaveType) {
+			return http.StatusBadRequest, errors.New("invalid leave type")
+		}
+		return http.StatusInternalServerError, errors.New("failed to load leave type")
+	}
+	if !definition.CountsAgainstBalance {
+		return http.StatusBadRequest, errors.New("leave type does not count against a balance")
+	}
+
+	var user models.User
+	if err := db.First(&user, req.UserID).Error; err != nil {
+		return http.StatusNotFound, errors.New("user not found")
+	}
+	return http.StatusOK, nil
+}
+
+// @Summary Create leave entitlement
+// @Description Give a user a yearly quota for a leave type. Accruals that are already due are posted immediately; the rest are posted by the daily leave scheduler.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param entitlement body LeaveEntitlementRequest true "Entitlement data"
+// @Success 201 {object} models.LeaveEntitlement
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage entitlements"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 409 {object} map[string]string "Entitlement already exists"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/entitlements [post]
+// @Security BearerAuth
+func CreateLeaveEntitlement(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var req LeaveEntitlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	if status, err := validateEntitlementRequest(db, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	var count int64
+	db.Model(&models.LeaveEntitlement{}).
+		Where("user_id = ? AND leave_type = ? AND year = ?", req.UserID, req.LeaveType, req.Year).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "An entitlement for this user, leave type and year already exists"})
+		return
+	}
+
+	entitlement := models.LeaveEntitlement{
+		UserID:         req.UserID,
+		LeaveType:      req.LeaveType,
+		Year:           req.Year,
+		AnnualDays:     req.AnnualDays,
+		AccrualMethod:  req.AccrualMethod,
+		CarryOverLimit: req.CarryOverLimit,
+	}
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&entitlement).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create leave entitlement"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityLeaveEntitlement, entitlement.ID, entitlement); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := utils.PostLeaveAccruals(tx, entitlement, time.Now()); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to post leave accruals"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entitlement)
+}
+
+// @Summary Update leave entitlement
+// @Description Update a leave entitlement. Accruals already posted are corrected in the ledger to match the new settings.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path int true "Entitlement ID"
+// @Param entitlement body LeaveEntitlementRequest true "Entitlement data (UserID, LeaveType and Year cannot be changed)"
+// @Success 200 {object} models.LeaveEntitlement
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage entitlements"
+// @Failure 404 {object} map[string]string "Entitlement not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/entitlements/{id} [put]
+// @Security BearerAuth
+func UpdateLeaveEntitlement(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	adminId := c.MustGet("userId").(uint)
+	id := c.Param("id")
+
+	var req LeaveEntitlementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var entitlement models.LeaveEntitlement
+	if err := db.First(&entitlement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Leave entitlement not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave entitlement"})
+		return
+	}
+
+	// The owner, type and year identify the ledger and cannot change
+	req.UserID = entitlement.UserID
+	req.LeaveType = entitlement.LeaveType
+	req.Year = entitlement.Year
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	if status, err := validateEntitlementRequest(db, req); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	before := audit.Snapshot(entitlement)
+
+	entitlement.AnnualDays = req.AnnualDays
+	entitlement.AccrualMethod = req.AccrualMethod
+	entitlement.CarryOverLimit = req.CarryOverLimit
+
+	// Start transaction
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&entitlement).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave entitlement"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityLeaveEntitlement, entitlement.ID, before, entitlement); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := utils.ReconcileLeaveAccruals(tx, entitlement, time.Now(), &adminId); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave accruals"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, entitlement)
+}
+
+// @Summary Delete leave entitlement
+// @Description Delete a leave entitlement. The leave type is no longer limited for that user and year; the ledger history is kept.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path int true "Entitlement ID"
+// @Success 200 {object} map[string]string "Leave entitlement deleted successfully"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage entitlements"
+// @Failure 404 {object} map[string]string "Entitlement not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/entitlements/{id} [delete]
+// @Security BearerAuth
+func DeleteLeaveEntitlement(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var entitlement models.LeaveEntitlement
+	if err := db.First(&entitlement, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Leave entitlement not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave entitlement"})
+		return
+	}
+
+	// Hard delete so an entitlement for the same user, type and year can be created again
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Unscoped().Delete(&entitlement).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete leave entitlement"})
+		return
+	}
+	if err := audit.Deleted(tx, c, audit.EntityLeaveEntitlement, entitlement.ID, entitlement); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Leave entitlement deleted successfully"})
+}
+
+// @Summary Adjust leave balance
+// @Description Add or remove days from a user's leave balance with a manual ledger entry
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param adjustment body LeaveAdjustmentRequest true "Adjustment data"
+// @Success 201 {object} models.LeaveLedgerEntry
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can adjust balances"
+// @Failure 404 {object} map[string]string "Entitlement not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/adjustments [post]
+// @Security BearerAuth
+func CreateLeaveAdjustment(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	adminId := c.MustGet("userId").(uint)
+
+	var req LeaveAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	entitlement, err := utils.GetLeaveEntitlement(db, req.UserID, req.LeaveType, req.Year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave entitlement"})
+		return
+	}
+	if entitlement == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The user has no entitlement for this leave type and year"})
+		return
+	}
+
+	entry := models.LeaveLedgerEntry{
+		UserID:      req.UserID,
+		LeaveType:   req.LeaveType,
+		Year:        req.Year,
+		EntryType:   models.LedgerAdjustment,
+		Days:        req.Days,
+		Description: req.Description,
+		CreatedByID: &adminId,
+	}
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&entry).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create leave adjustment"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityLeaveLedgerEntry, entry.ID, entry); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, entry)
+}
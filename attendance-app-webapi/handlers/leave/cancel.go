@@ -0,0 +1,305 @@
+package leave
+
+import (
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LeaveCancellationRequest represents the request payload for cancelling a leave request
+type LeaveCancellationRequest struct {
+	// Reason for the cancellation, required for approved leave
+	Reason string `json:"reason" validate:"max=1000" example:"Trip was postponed"`
+} //@name LeaveCancellationRequest
+
+// @Summary Cancel leave request
+// @Description Cancel one of your own leave requests. A pending request is withdrawn immediately. An approved request needs a reason and is cancelled once the supervisor approves the cancellation.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path string true "Leave request ID"
+// @Param request body LeaveCancellationRequest false "Cancellation details"
+// @Success 200 {object} models.LeaveRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid request or leave request cannot be cancelled"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not your leave request"
+// @Failure 404 {object} map[string]string "Leave request not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/cancel/{id} [put]
+// @Security BearerAuth
+func CancelLeaveRequest(c *gin.Context) {
+	var req LeaveCancellationRequest
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+	leaveRequestId := c.Param("id")
+
+	// The body is optional when withdrawing a pending request
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	// Start transaction for atomic operation
+	tx := db.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The request stays locked until the cancellation is committed, so a
+	// concurrent approval cannot be overwritten
+	leaveRequest, ok := lockLeaveRequest(c, tx, leaveRequestId)
+	if !ok {
+		return
+	}
+
+	if leaveRequest.UserID != userId {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to cancel this leave request"})
+		return
+	}
+
+	before := audit.Snapshot(leaveRequest)
+	leaveRequest.CancellationReason = req.Reason
+
+	switch leaveRequest.Status {
+	case models.LeavePending:
+		// Nothing has been deducted or recorded yet
+		now := time.Now()
+		leaveRequest.Status = models.LeaveCancelled
+		leaveRequest.CancelledAt = &now
+		leaveRequest.CancelledByID = &userId
+	case models.LeaveApproved:
+		if req.Reason == "" {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A reason is required to cancel an approved leave request"})
+			return
+		}
+		leaveRequest.Status = models.LeaveCancellationRequested
+		leaveRequest.CancellationNotes = ""
+	default:
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending or approved leave requests can be cancelled"})
+		return
+	}
+
+	if leaveRequest.Status == models.LeaveCancelled {
+		if err := utils.SkipPendingApprovalSteps(tx, leaveRequest.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval steps"})
+			return
+		}
+	}
+
+	if err := tx.Save(&leaveRequest).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel leave request"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityLeaveRequest, leaveRequest.ID, before, leaveRequest); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaveRequest)
+}
+
+// @Summary Validate leave cancellation
+// @Description Approve or reject the cancellation of an approved leave as the supervisor, their delegate or a holder of the leave.approve.any permission. Approving it removes the leave attendance records, credits back the deducted balance and marks the request CANCELLED. Rejecting it keeps the leave APPROVED.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path string true "Leave request ID"
+// @Param request body LeaveValidationRequest true "Cancellation decision"
+// @Success 200 {object} models.LeaveRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid request payload or no cancellation requested"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the supervisor or a delegate"
+// @Failure 404 {object} map[string]string "Leave request or user not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/cancellation/{id} [put]
+// @Security BearerAuth
+func ValidateLeaveCancellation(c *gin.Context) {
+	var req LeaveValidationRequest
+	db := c.MustGet("db").(*gorm.DB)
+	supervisorId := c.MustGet("userId").(uint)
+	leaveRequestId := c.Param("id")
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Status != models.LeaveApproved && req.Status != models.LeaveRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be APPROVED or REJECTED"})
+		return
+	}
+
+	// Start transaction for atomic operation
+	tx := db.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The request stays locked until the decision is committed, so the balance
+	// is credited back once however many decisions race
+	leaveRequest, ok := lockLeaveRequest(c, tx, leaveRequestId)
+	if !ok {
+		return
+	}
+
+	// Check if the user is the supervisor of the leave request owner, or their delegate
+	delegation, allowed, err := utils.CanActForSupervisor(tx, leaveRequest.UserID, supervisorId)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approval delegation"})
+		return
+	}
+	// Holders of leave.approve.any, e.g. HR staff, may decide any cancellation but their own
+	if !allowed && leaveRequest.UserID != supervisorId {
+		if allowed, err = utils.HasPermission(tx, supervisorId, models.PermLeaveApproveAny); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+	}
+	if !allowed {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to validate this leave cancellation"})
+		return
+	}
+
+	if leaveRequest.Status != models.LeaveCancellationRequested {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No cancellation has been requested for this leave"})
+		return
+	}
+
+	before := audit.Snapshot(leaveRequest)
+	leaveRequest.CancellationNotes = req.ApproverNotes
+	leaveRequest.CancellationDelegationID = nil
+	if delegation != nil {
+		leaveRequest.CancellationDelegationID = &delegation.ID
+	}
+
+	// A rejected cancellation leaves the approved leave untouched
+	if req.Status == models.LeaveRejected {
+		leaveRequest.Status = models.LeaveApproved
+		if err := tx.Save(&leaveRequest).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave request"})
+			return
+		}
+		if err := audit.Updated(tx, c, audit.EntityLeaveRequest, leaveRequest.ID, before, leaveRequest); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+			return
+		}
+		c.JSON(http.StatusOK, leaveRequest)
+		return
+	}
+
+	now := time.Now()
+	leaveRequest.Status = models.LeaveCancelled
+	leaveRequest.CancelledAt = &now
+	leaveRequest.CancelledByID = &supervisorId
+
+	// Remove the attendance records created for the leave on approval
+	if err := tx.Where("leave_request_id = ? AND validation_status = ?", leaveRequest.ID, models.Leave).
+		Delete(&models.Attendance{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove leave attendance records"})
+		return
+	}
+
+	// Attendance of the worked part of a partial-day leave is kept but no longer linked
+	if err := tx.Model(&models.Attendance{}).
+		Where("leave_request_id = ?", leaveRequest.ID).
+		Update("leave_request_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink attendance records from leave"})
+		return
+	}
+
+	if err := utils.ReverseLeaveDeduction(tx, leaveRequest, &supervisorId); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reverse leave balance"})
+		return
+	}
+
+	if err := tx.Save(&leaveRequest).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave request"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityLeaveRequest, leaveRequest.ID, before, leaveRequest); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	// Commit transaction
+	if err := tx.Commit().Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	c.JSON(http.StatusOK, leaveRequest)
+}
+
+// lockLeaveRequest loads the leave request FOR UPDATE within the transaction,
+// answering and rolling back when it cannot be loaded
+func lockLeaveRequest(c *gin.Context, tx *gorm.DB, id string) (models.LeaveRequest, bool) {
+	var leaveRequest models.LeaveRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&leaveRequest, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Leave request not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave request"})
+		}
+		return models.LeaveRequest{}, false
+	}
+	return leaveRequest, true
+}
@@ -2,10 +2,13 @@
 package leave
 
 import (
+	"attendance-app/audit"
 	"attendance-app/models"
 	"attendance-app/storage"
 	"attendance-app/utils"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"time"
 
@@ -16,7 +19,7 @@ import (
 
 // LeaveRequest represents the request payload for submitting a leave request
 type LeaveRequest struct {
-	// Type of leave (SICK, VACATION, etc.)
+	// Code of an active leave type (SICK, PERMIT or an admin-defined type)
 	LeaveType models.LeaveType `json:"leaveType" binding:"required" example:"SICK" form:"leaveType"`
 	// Start date of the leave period (YYYY-MM-DD)
 	StartDate string `json:"startDate" binding:"required" example:"2025-10-22" form:"startDate" time_format:"2006-01-02"`
@@ -24,6 +27,12 @@ type LeaveRequest struct {
 	EndDate string `json:"endDate" binding:"required" example:"2025-10-24" form:"endDate" time_format:"2006-01-02"`
 	// Reason for requesting leave
 	Reason string `json:"reason" binding:"required" example:"Medical appointment and recovery" form:"reason"`
+	// Part of the day (FULL_DAY, MORNING, AFTERNOON, HOURS); partial portions require a single day
+	DayPortion models.LeaveDayPortion `json:"dayPortion" example:"HOURS" form:"dayPortion"`
+	// Start of the leave (HH:MM), required for HOURS
+	StartTime string `json:"startTime" example:"09:00" form:"startTime"`
+	// End of the leave (HH:MM), required for HOURS
+	EndTime string `json:"endTime" example:"11:00" form:"endTime"`
 } //@name LeaveRequest
 
 // LeaveValidationRequest represents the request payload for validating a leave request
@@ -34,16 +43,39 @@ type LeaveValidationRequest struct {
 	ApproverNotes string `json:"approverNotes" example:"Approved based on medical documentation"`
 } //@name LeaveValidationRequest
 
+// respondLeaveBalanceError writes the response for a failed leave balance check
+func respondLeaveBalanceError(c *gin.Context, err error) {
+	var insufficient *utils.InsufficientLeaveBalanceError
+	switch {
+	case errors.As(err, &insufficient):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Insufficient leave balance",
+			"details": map[string]interface{}{
+				"requestedDays": insufficient.Requested,
+				"availableDays": insufficient.Available,
+				"message":       "Pending leave requests are counted against your balance.",
+			},
+		})
+	case errors.Is(err, utils.ErrLeaveSpansYears):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check leave balance"})
+	}
+}
+
 // @Summary Submit leave request
-// @Description Submit a new leave request with supporting documentation
+// @Description Submit a new leave request with supporting documentation. The rules of the leave type (attachment, minimum notice, maximum consecutive days) are enforced, and types that count against a balance are rejected when they exceed the remaining balance (pending requests count as taken).
 // @Tags leave
 // @Accept multipart/form-data
 // @Produce json
-// @Param attachment formData file true "Supporting document (JPG, JPEG, PNG, PDF, max 5MB)"
-// @Param leaveType formData string true "Type of leave (SICK, PERMIT)" example(SICK)
+// @Param attachment formData file false "Supporting document (JPG, JPEG, PNG, PDF, max 5MB), required when the leave type requires an attachment"
+// @Param leaveType formData string true "Code of an active leave type (see /user/leave/types)" example(SICK)
 // @Param startDate formData string true "Start date of leave (YYYY-MM-DD)" example(2025-10-22)
 // @Param endDate formData string true "End date of leave (YYYY-MM-DD)" example(2025-10-24)
 // @Param reason formData string true "Reason for leave request" example(Medical_appointment_and_recovery)
+// @Param dayPortion formData string false "Part of the day: FULL_DAY (default), MORNING, AFTERNOON or HOURS. Partial portions must start and end on the same day" example(HOURS)
+// @Param startTime formData string false "Start of the leave (HH:MM), required for HOURS" example(09:00)
+// @Param endTime formData string false "End of the leave (HH:MM), required for HOURS" example(11:00)
 // @Success 200 {object} models.LeaveRequestSwagger
 // @Failure 400 {object} map[string]string "Invalid request payload, dates, or attachment"
 // @Failure 401 {object} map[string]string "Unauthorized"
@@ -55,6 +87,52 @@ func SubmitLeaveRequest(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	userId := c.MustGet("userId").(uint)
 
+	// Bind form data
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Parse dates by appending time part
+	startDate, err := time.Parse(time.RFC3339, req.StartDate+"T00:00:00Z")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	endDate, err := time.Parse(time.RFC3339, req.EndDate+"T00:00:00Z")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format. Use YYYY-MM-DD"})
+		return
+	}
+
+	// Validate dates
+	if endDate.Before(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "End date cannot be before start date"})
+		return
+	}
+
+	currentTime := time.Now()
+	currentDate := currentTime.Truncate(24 * time.Hour)
+
+	if startDate.Before(currentDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot submit leave request for past dates"})
+		return
+	}
+
+	if req.DayPortion == "" {
+		req.DayPortion = models.LeaveFullDay
+	}
+	if !models.IsValidLeaveDayPortion(req.DayPortion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Day portion must be FULL_DAY, MORNING, AFTERNOON or HOURS"})
+		return
+	}
+	partialDay := req.DayPortion != models.LeaveFullDay
+	if partialDay && !endDate.Equal(startDate) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Partial-day leave must start and end on the same day"})
+		return
+	}
+
 	// Start transaction
 	tx := db.Begin()
 	if tx.Error != nil {
@@ -67,70 +145,105 @@ func SubmitLeaveRequest(c *gin.Context) {
 		}
 	}()
 
-	// Bind form data
-	if err := c.ShouldBind(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	// Rules such as attachments and notice periods depend on the leave type
+	leaveType, err := utils.GetActiveLeaveTypeDefinition(tx, req.LeaveType)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, utils.ErrUnknownLeaveType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid leave type"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leave type"})
 		return
 	}
 
-	// Handle attachment upload
+	// Validate the attachment here, it is only saved once the request is accepted
 	file, err := c.FormFile("attachment")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment is required"})
+	if err != nil && leaveType.AttachmentRequired {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Attachment is required for " + leaveType.Name})
 		return
 	}
 
 	// Initialize storage
 	fileStorage := storage.NewLocalStorage(storage.Current.BasePath)
+	if file != nil {
+		// Validate file type
+		if err := fileStorage.ValidateFileType(file.Filename, storage.Current.AllowedTypes["leave"]); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 
-	// Validate file type
-	if err := fileStorage.ValidateFileType(file.Filename, storage.Current.AllowedTypes["leave"]); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		// Validate file size
+		if err := fileStorage.ValidateFileSize(file.Size, storage.Current.MaxFileSize); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 	}
 
-	// Validate file size
-	if err := fileStorage.ValidateFileSize(file.Size, storage.Current.MaxFileSize); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if leaveType.MinNoticeDays > 0 && startDate.Before(currentDate.AddDate(0, 0, int(leaveType.MinNoticeDays))) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%s must be requested at least %d days in advance", leaveType.Name, leaveType.MinNoticeDays),
+		})
 		return
 	}
 
-	// Save file
-	attachmentURL, err := fileStorage.Save(file, "leave")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+	consecutiveDays := int(endDate.Sub(startDate).Hours()/24) + 1
+	if leaveType.MaxConsecutiveDays > 0 && consecutiveDays > int(leaveType.MaxConsecutiveDays) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%s cannot exceed %d consecutive days", leaveType.Name, leaveType.MaxConsecutiveDays),
+		})
 		return
 	}
 
-	// Parse dates by appending time part
-	startDate, err := time.Parse(time.RFC3339, req.StartDate+"T00:00:00Z")
+	// A leave request must cover at least one working day
+	schedule, err := utils.GetUserWorkSchedule(tx, userId)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid start date format. Use YYYY-MM-DD"})
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
 		return
 	}
-
-	endDate, err := time.Parse(time.RFC3339, req.EndDate+"T00:00:00Z")
+	workingCalendar, err := utils.LoadWorkingCalendar(tx, startDate, endDate)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid end date format. Use YYYY-MM-DD"})
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load working days"})
 		return
 	}
-
-	// Validate dates
-	if endDate.Before(startDate) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "End date cannot be before start date"})
+	workingDays := workingCalendar.WorkingDays(schedule, startDate, endDate)
+	if len(workingDays) == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The selected period has no working days"})
 		return
 	}
+	days := float64(len(workingDays))
 
-	currentTime := time.Now()
-	currentDate := currentTime.Truncate(24 * time.Hour)
+	// Partial-day leave counts the share of the shift it covers
+	var leaveFrom, leaveTo time.Time
+	if partialDay {
+		leaveFrom, leaveTo, days, err = utils.LeavePortionWindow(schedule, startDate, req.DayPortion, req.StartTime, req.EndTime)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
-	if startDate.Before(currentDate) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot submit leave request for past dates"})
-		return
+	// Reject requests that exceed the remaining balance, counting pending requests as taken
+	if leaveType.CountsAgainstBalance {
+		if err := utils.CheckLeaveBalance(tx, userId, leaveType.Code, startDate, endDate, days, 0); err != nil {
+			tx.Rollback()
+			respondLeaveBalanceError(c, err)
+			return
+		}
 	}
 
 	// Check for existing attendance records in the date range
-	// Users cannot submit leave requests for days where they already checked in
+	// Users cannot submit full-day leave requests for days where they already checked in.
+	// Partial-day leave is linked to the day's attendance record on approval instead.
 	var existingAttendance models.Attendance
 	result := tx.Where(
 		"user_id = ? AND DATE(check_in_time) BETWEEN ? AND ?",
@@ -139,7 +252,7 @@ func SubmitLeaveRequest(c *gin.Context) {
 		endDate.Format("2006-01-02"),
 	).First(&existingAttendance)
 
-	if result.RowsAffected > 0 {
+	if result.RowsAffected > 0 && !partialDay {
 		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Cannot submit leave request for days with existing attendance records",
@@ -153,22 +266,42 @@ func SubmitLeaveRequest(c *gin.Context) {
 	}
 
 	// Check for overlapping leave requests
-	var existingLeave models.LeaveRequest
+	var existingLeaves []models.LeaveRequest
 	result = tx.Where(
-		"user_id = ? AND status != ? AND "+
+		"user_id = ? AND status NOT IN ? AND "+
 			"((start_date BETWEEN ? AND ?) OR (end_date BETWEEN ? AND ?) OR "+
 			"(start_date <= ? AND end_date >= ?))",
 		userId,
-		models.LeaveRejected,
+		[]models.LeaveRequestStatus{models.LeaveRejected, models.LeaveCancelled},
 		startDate.Format("2006-01-02"),
 		endDate.Format("2006-01-02"),
 		startDate.Format("2006-01-02"),
 		endDate.Format("2006-01-02"),
 		startDate.Format("2006-01-02"),
 		endDate.Format("2006-01-02"),
-	).First(&existingLeave)
+	).Find(&existingLeaves)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing leave requests"})
+		return
+	}
 
-	if result.RowsAffected > 0 {
+	// Partial-day requests on the same day only overlap when their times do
+	var existingLeave models.LeaveRequest
+	overlapping := false
+	for _, leave := range existingLeaves {
+		if partialDay && leave.IsPartialDay() {
+			from, to, err := utils.LeaveRequestWindow(schedule, leave)
+			if err == nil && (!from.Before(leaveTo) || !to.After(leaveFrom)) {
+				continue
+			}
+		}
+		existingLeave = leave
+		overlapping = true
+		break
+	}
+
+	if overlapping {
 		tx.Rollback()
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Overlapping leave request exists",
@@ -185,22 +318,66 @@ func SubmitLeaveRequest(c *gin.Context) {
 		return
 	}
 
+	// Save the attachment last, and remove it again if the request is not stored
+	var attachmentURL string
+	committed := false
+	if file != nil {
+		attachmentURL, err = fileStorage.Save(file, "leave")
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save attachment"})
+			return
+		}
+		defer func() {
+			if !committed {
+				if err := fileStorage.Delete(attachmentURL); err != nil {
+					log.Printf("Failed to remove attachment of rejected leave request: %v", err)
+				}
+			}
+		}()
+	}
+
 	// Create leave request
 	leaveRequest := models.LeaveRequest{
 		UserID:        userId,
-		LeaveType:     req.LeaveType,
+		LeaveType:     leaveType.Code,
 		StartDate:     startDate,
 		EndDate:       endDate,
+		DayPortion:    req.DayPortion,
+		Days:          days,
 		Reason:        req.Reason,
 		AttachmentURL: attachmentURL,
 		Status:        models.LeavePending,
 	}
 
+	if partialDay {
+		leaveRequest.StartTime = leaveFrom.Format("15:04")
+		leaveRequest.EndTime = leaveTo.Format("15:04")
+	}
+
 	if err := tx.Create(&leaveRequest).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create leave request"})
 		return
 	}
+	if err := audit.Created(tx, c, audit.EntityLeaveRequest, leaveRequest.ID, leaveRequest); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	// The approvers are taken from the supervisor hierarchy at submission
+	steps, err := utils.CreateLeaveApprovalSteps(tx, leaveRequest)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, utils.ErrNoApprover) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "You have no supervisor to approve leave requests"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create approval steps"})
+		return
+	}
+	leaveRequest.ApprovalSteps = steps
 
 	// NOTE: Attendance records are NOT created here.
 	// They will only be created when the leave request is APPROVED in ValidateLeaveRequest.
@@ -212,6 +389,7 @@ func SubmitLeaveRequest(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
 		return
 	}
+	committed = true
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Leave request submitted successfully",
@@ -243,7 +421,10 @@ func GetMyLeaveRequests(c *gin.Context) {
 	// Build base query
 	query := db.Model(&models.LeaveRequest{}).
 		Where("user_id = ?", userId).
-		Preload("Approver")
+		Preload("Approver").
+		Preload("ApprovalSteps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("level ASC")
+		})
 
 	// Apply search if provided
 	if params.Search != "" {
@@ -282,11 +463,13 @@ func GetMyLeaveRequests(c *gin.Context) {
 }
 
 // @Summary Get subordinate leave requests
-// @Description Get all leave requests submitted by users who report to the current user
+// @Description Get all leave requests submitted by users who report to the current user. With depth the requests of subordinates further down the reporting tree are included; every request carries the reporting path from the supervisor down to its user.
 // @Tags leave
 // @Accept json
 // @Produce json
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
 // @Success 200 {array} models.LeaveRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid depth"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /user/leave/subordinates [get]
@@ -295,13 +478,25 @@ func GetSubordinateLeaveRequests(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	supervisorId := c.MustGet("userId").(uint)
 
-	var subordinateIds []uint
-	if err := db.Model(&models.User{}).Where("supervisor_id = ?", supervisorId).Pluck("id", &subordinateIds).Error; err != nil {
+	depth, err := utils.ReportingDepthQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Includes the subordinates of supervisors who delegated their approval rights
+	subordinates, err := utils.GetReportingLines(db, supervisorId, depth)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates"})
 		return
 	}
 
-	if len(subordinateIds) == 0 {
+	listLeaveRequests(c, db, subordinates)
+}
+
+// listLeaveRequests responds with a page of the leave requests of the users
+func listLeaveRequests(c *gin.Context, db *gorm.DB, lines utils.ReportingLines) {
+	if len(lines.UserIDs) == 0 {
 		// Return empty paginated response
 		response := utils.BuildPaginatedResponse([]models.LeaveRequest{}, 0, utils.GetPaginationParams(c))
 		c.JSON(http.StatusOK, response)
@@ -313,9 +508,12 @@ func GetSubordinateLeaveRequests(c *gin.Context) {
 
 	// Build base query
 	query := db.Model(&models.LeaveRequest{}).
-		Where("user_id IN ?", subordinateIds).
+		Where("user_id IN ?", lines.UserIDs).
 		Preload("User").
-		Preload("Approver")
+		Preload("Approver").
+		Preload("ApprovalSteps", func(db *gorm.DB) *gorm.DB {
+			return db.Order("level ASC")
+		})
 
 	// Apply search if provided
 	if params.Search != "" {
@@ -350,13 +548,65 @@ func GetSubordinateLeaveRequests(c *gin.Context) {
 		return
 	}
 
+	for i := range leaveRequests {
+		leaveRequests[i].ReportingPath = lines.Path(leaveRequests[i].UserID)
+	}
+
 	// Build paginated response
 	response := utils.BuildPaginatedResponse(leaveRequests, totalRows, params)
 	c.JSON(http.StatusOK, response)
 }
 
+// departmentMemberIds returns the members of the departments headed by the
+// current user, limited by the departmentId query parameter. It responds with
+// the error and returns false when the user may not see them.
+func departmentMemberIds(c *gin.Context, db *gorm.DB) ([]uint, bool) {
+	headId := c.MustGet("userId").(uint)
+
+	departmentId, err := utils.DepartmentQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return nil, false
+	}
+
+	memberIds, err := utils.GetHeadedDepartmentMemberIDs(db, headId, departmentId)
+	if errors.Is(err, utils.ErrNotDepartmentHead) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not the head of the department"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch department members"})
+		return nil, false
+	}
+	return memberIds, true
+}
+
+// @Summary Get department leave requests
+// @Description Get the leave requests of everyone in the departments headed by the current user and in the departments below them (department head only). departmentId limits the requests to one of those departments and its sub-departments.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param departmentId query int false "Department ID within the headed departments"
+// @Success 200 {array} models.LeaveRequestSwagger
+// @Failure 400 {object} map[string]string "Invalid department ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the head of the department"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/department [get]
+// @Security BearerAuth
+func GetDepartmentLeaveRequests(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	memberIds, ok := departmentMemberIds(c, db)
+	if !ok {
+		return
+	}
+
+	listLeaveRequests(c, db, utils.ReportingLines{UserIDs: memberIds})
+}
+
 // @Summary Validate leave request
-// @Description Approve or reject a leave request as a supervisor
+// @Description Approve or reject the current approval step of a leave request. Long leaves may need approval from several levels of supervisors; the request becomes APPROVED once every step is approved and REJECTED as soon as one approver rejects it. Holders of the leave.approve.any permission may decide the current step of any request but their own.
 // @Tags leave
 // @Accept json
 // @Produce json
@@ -365,8 +615,8 @@ func GetSubordinateLeaveRequests(c *gin.Context) {
 // @Success 200 {object} models.LeaveRequestSwagger
 // @Failure 400 {object} map[string]string "Invalid request payload"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden - Not the supervisor"
-// @Failure 404 {object} map[string]string "Leave request or user not found"
+// @Failure 403 {object} map[string]string "Forbidden - Not the current approver"
+// @Failure 404 {object} map[string]string "Leave request not found"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /user/leave/validate/{id} [put]
 // @Security BearerAuth
@@ -381,55 +631,208 @@ func ValidateLeaveRequest(c *gin.Context) {
 		return
 	}
 
-	var leaveRequest models.LeaveRequest
-	if err := db.First(&leaveRequest, leaveRequestId).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Leave request not found"})
+	if req.Status != models.LeaveApproved && req.Status != models.LeaveRejected {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be APPROVED or REJECTED"})
 		return
 	}
 
-	// Check if the user is the supervisor of the leave request owner
-	var user models.User
-	if err := db.First(&user, leaveRequest.UserID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+	// Start transaction for atomic operation
+	tx := db.Begin()
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// The request stays locked until the decision is committed, so concurrent
+	// decisions wait and then see the steps and status left by the first one
+	leaveRequest, ok := lockLeaveRequest(c, tx, leaveRequestId)
+	if !ok {
+		return
+	}
+
+	// Deciding twice would deduct the leave balance twice
+	if leaveRequest.Status != models.LeavePending {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Leave request has already been processed"})
 		return
 	}
 
-	if user.SupervisorID == nil || *user.SupervisorID != supervisorId {
+	steps, err := utils.GetLeaveApprovalSteps(tx, leaveRequest)
+	if err != nil {
+		tx.Rollback()
+		if errors.Is(err, utils.ErrNoApprover) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to validate this leave request"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load approval steps"})
+		return
+	}
+
+	// Only the approver of the current step, their delegate or a holder of leave.approve.any can decide
+	step := utils.CurrentApprovalStep(steps)
+	if step == nil || leaveRequest.UserID == supervisorId {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to validate this leave request"})
+		return
+	}
+	delegation, allowed, err := utils.CanActFor(tx, step.ApproverID, supervisorId)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approval delegation"})
+		return
+	}
+	// Holders of leave.approve.any, e.g. HR staff, may decide the current step of any request
+	if !allowed {
+		if allowed, err = utils.HasPermission(tx, supervisorId, models.PermLeaveApproveAny); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			return
+		}
+	}
+	if !allowed {
+		tx.Rollback()
 		c.JSON(http.StatusForbidden, gin.H{"error": "Not authorized to validate this leave request"})
 		return
 	}
 
+	stepBefore := audit.Snapshot(step)
+	now := time.Now()
+	step.Status = models.ApprovalStepStatus(req.Status)
+	step.ActedByID = &supervisorId
+	if delegation != nil {
+		step.DelegationID = &delegation.ID
+	}
+	step.Notes = req.ApproverNotes
+	step.DecidedAt = &now
+	if err := tx.Save(step).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record approval step"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityLeaveApprovalStep, step.ID, stepBefore, step); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	leaveRequest.ApprovalSteps = steps
+
+	// Approval by an intermediate approver passes the request on to the next level
+	if req.Status == models.LeaveApproved && utils.CurrentApprovalStep(steps) != nil {
+		if err := tx.Commit().Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+		c.JSON(http.StatusOK, leaveRequest)
+		return
+	}
+
+	if req.Status == models.LeaveRejected {
+		if err := utils.SkipPendingApprovalSteps(tx, leaveRequest.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval steps"})
+			return
+		}
+		for i := range steps {
+			if steps[i].Status == models.ApprovalStepPending {
+				steps[i].Status = models.ApprovalStepSkipped
+			}
+		}
+	}
+
+	before := audit.Snapshot(leaveRequest)
 	leaveRequest.Status = req.Status
 	leaveRequest.ApproverID = &supervisorId
 	leaveRequest.ApproverNotes = req.ApproverNotes
 
-	// Start transaction for atomic operation
-	tx := db.Begin()
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
-		return
-	}
-	defer func() {
-		if r := recover(); r != nil {
+	// Only working days of the user's schedule and the company calendar are leave days.
+	// They are recounted on approval in case the calendar changed since submission.
+	var workingDays []time.Time
+	var leaveType models.LeaveTypeDefinition
+	if req.Status == models.LeaveApproved {
+		workingDays, err = utils.GetUserWorkingDays(tx, leaveRequest.UserID, leaveRequest.StartDate, leaveRequest.EndDate)
+		if err != nil {
 			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load working days"})
+			return
 		}
-	}()
+		if !leaveRequest.IsPartialDay() {
+			leaveRequest.Days = float64(len(workingDays))
+		} else if len(workingDays) == 0 {
+			// The day became a holiday after the request was submitted
+			leaveRequest.Days = 0
+		}
+
+		// Inactive types still apply their rules to requests made before deactivation
+		leaveType, err = utils.GetLeaveTypeDefinition(tx, leaveRequest.LeaveType)
+		if err != nil && !errors.Is(err, utils.ErrUnknownLeaveType) {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load leave type"})
+			return
+		}
+
+		if leaveType.CountsAgainstBalance {
+			if err := utils.CheckLeaveBalance(tx, leaveRequest.UserID, leaveRequest.LeaveType,
+				leaveRequest.StartDate, leaveRequest.EndDate, leaveRequest.Days, leaveRequest.ID); err != nil {
+				tx.Rollback()
+				respondLeaveBalanceError(c, err)
+				return
+			}
+		}
+	}
 
-	if err := tx.Save(&leaveRequest).Error; err != nil {
+	if err := tx.Omit("ApprovalSteps").Save(&leaveRequest).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave request"})
 		return
 	}
+	if err := audit.Updated(tx, c, audit.EntityLeaveRequest, leaveRequest.ID, before, leaveRequest); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
-	// If approved, create attendance records for the leave period
+	// If approved, deduct the balance and create attendance records for the leave period
 	if req.Status == models.LeaveApproved {
-		// Create attendance records for each day of leave
-		for d := leaveRequest.StartDate; !d.After(leaveRequest.EndDate); d = d.AddDate(0, 0, 1) {
-			// Skip weekends
-			if d.Weekday() == time.Saturday || d.Weekday() == time.Sunday {
-				continue
+		if leaveType.CountsAgainstBalance {
+			if err := utils.DeductLeaveBalance(tx, leaveRequest, &supervisorId); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to deduct leave balance"})
+				return
+			}
+		}
+
+		// Partial-day leave does not replace the day's attendance. It is linked to the
+		// record of the worked part, now if the user already checked in or at check-in.
+		if leaveRequest.IsPartialDay() {
+			schedule, err := utils.GetUserWorkSchedule(tx, leaveRequest.UserID)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
+				return
+			}
+
+			windowStart, windowEnd := schedule.AttendanceWindow(utils.LocalDate(leaveRequest.StartDate))
+			if err := tx.Model(&models.Attendance{}).
+				Where("user_id = ? AND check_in_time >= ? AND check_in_time < ? AND leave_request_id IS NULL",
+					leaveRequest.UserID, windowStart, windowEnd).
+				Update("leave_request_id", leaveRequest.ID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to link attendance record to leave"})
+				return
 			}
 
+			// No full-day leave records are created below
+			workingDays = nil
+		}
+
+		// Create attendance records for each day of leave
+		for _, d := range workingDays {
 			// Check if attendance record already exists for this day
 			var existingAttendance models.Attendance
 			err := tx.Where("user_id = ? AND DATE(check_in_time) = ?",
@@ -452,6 +855,7 @@ func ValidateLeaveRequest(c *gin.Context) {
 				Status:           models.OnTime,
 				ValidationStatus: models.Leave,
 				ValidatorID:      &supervisorId,
+				LeaveRequestID:   &leaveRequest.ID,
 				Notes:            "Approved leave request: " + leaveRequest.Reason,
 			}
 
@@ -460,6 +864,11 @@ func ValidateLeaveRequest(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create attendance record for leave"})
 				return
 			}
+			if err := audit.Created(tx, c, audit.EntityAttendance, attendance.ID, attendance); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
 		}
 	}
 
@@ -609,11 +1018,13 @@ func ExportMyLeaveRequestsToExcel(c *gin.Context) {
 }
 
 // @Summary Export subordinate leave requests to Excel
-// @Description Export subordinate leave requests to Excel file (supervisor only)
+// @Description Export subordinate leave requests to Excel file (supervisor only). With depth the requests of subordinates further down the reporting tree are included; the Reporting Path column shows the reporting line down to each user.
 // @Tags leave
 // @Security BearerAuth
 // @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
 // @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid depth or no subordinates"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden - Not a supervisor"
 // @Failure 500 {object} map[string]string "Server error"
@@ -622,21 +1033,33 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 	db := c.MustGet("db").(*gorm.DB)
 	supervisorId := c.MustGet("userId").(uint)
 
+	depth, err := utils.ReportingDepthQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get subordinate IDs
-	var subordinateIds []uint
-	if err := db.Model(&models.User{}).Where("supervisor_id = ?", supervisorId).Pluck("id", &subordinateIds).Error; err != nil {
+	// Includes the subordinates of supervisors who delegated their approval rights
+	subordinates, err := utils.GetReportingLines(db, supervisorId, depth)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates"})
 		return
 	}
 
-	if len(subordinateIds) == 0 {
+	if len(subordinates.UserIDs) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No subordinates found"})
 		return
 	}
 
-	// Fetch subordinate leave requests with preloads
+	exportLeaveRequests(c, db, subordinates, "Subordinate Leave Requests", "subordinate_leave_requests")
+}
+
+// exportLeaveRequests writes the leave requests of the users to an Excel download
+func exportLeaveRequests(c *gin.Context, db *gorm.DB, lines utils.ReportingLines, sheetName, filePrefix string) {
+	// Fetch leave requests with preloads
 	var leaveRequests []models.LeaveRequest
-	if err := db.Where("user_id IN ?", subordinateIds).
+	if err := db.Where("user_id IN ?", lines.UserIDs).
 		Preload("User").
 		Preload("Approver").
 		Order("start_date DESC").
@@ -653,7 +1076,6 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 		}
 	}()
 
-	sheetName := "Subordinate Leave Requests"
 	index, err := f.NewSheet(sheetName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Excel sheet"})
@@ -666,6 +1088,9 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 		"Reason", "Attachment URL", "Status", "Approver Name", "Approver Notes",
 		"Created At", "Updated At",
 	}
+	if lines.Paths != nil {
+		headers = append(headers, "Reporting Path")
+	}
 	for i, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, header)
@@ -677,7 +1102,8 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 		Fill: excelize.Fill{Type: "pattern", Color: []string{"#E0E0E0"}, Pattern: 1},
 	})
 	if err == nil {
-		f.SetCellStyle(sheetName, "A1", "N1", headerStyle)
+		lastHeader, _ := excelize.CoordinatesToCellName(len(headers), 1)
+		f.SetCellStyle(sheetName, "A1", lastHeader, headerStyle)
 	}
 
 	// Write leave request data
@@ -743,6 +1169,12 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 		// Updated At
 		cell, _ = excelize.CoordinatesToCellName(14, row)
 		f.SetCellValue(sheetName, cell, leave.UpdatedAt.Format("2006-01-02 15:04:05"))
+
+		// Reporting Path
+		if lines.Paths != nil {
+			cell, _ = excelize.CoordinatesToCellName(15, row)
+			f.SetCellValue(sheetName, cell, utils.FormatReportingPath(lines.Path(leave.UserID)))
+		}
 	}
 
 	// Auto-fit columns
@@ -756,7 +1188,7 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 	f.DeleteSheet("Sheet1")
 
 	// Generate filename with current date
-	filename := fmt.Sprintf("subordinate_leave_requests_%s.xlsx", time.Now().Format("2006-01-02"))
+	filename := fmt.Sprintf("%s_%s.xlsx", filePrefix, time.Now().Format("2006-01-02"))
 
 	// Set headers for file download
 	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
@@ -769,3 +1201,31 @@ func ExportSubordinateLeaveRequestsToExcel(c *gin.Context) {
 		return
 	}
 }
+
+// @Summary Export department leave requests to Excel
+// @Description Export the leave requests of everyone in the departments headed by the current user and in the departments below them to an Excel file (department head only)
+// @Tags leave
+// @Security BearerAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param departmentId query int false "Department ID within the headed departments"
+// @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid department ID or no department members"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Not the head of the department"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/department/export/excel [get]
+func ExportDepartmentLeaveRequestsToExcel(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	memberIds, ok := departmentMemberIds(c, db)
+	if !ok {
+		return
+	}
+
+	if len(memberIds) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No department members found"})
+		return
+	}
+
+	exportLeaveRequests(c, db, utils.ReportingLines{UserIDs: memberIds}, "Department Leave Requests", "department_leave_requests")
+}
@@ -0,0 +1,289 @@
+package leave
+
+import (
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LeaveTypeRequest represents the request payload for creating or updating a leave type
+type LeaveTypeRequest struct {
+	// Code is stored on leave requests and cannot be changed after creation
+	Code                 models.LeaveType `json:"Code" validate:"required,max=20" example:"ANNUAL"`
+	Name                 string           `json:"Name" validate:"required,max=255" example:"Annual Leave"`
+	Description          string           `json:"Description" validate:"max=1000" example:"Paid yearly vacation"`
+	AttachmentRequired   bool             `json:"AttachmentRequired" example:"false"`
+	IsPaid               bool             `json:"IsPaid" example:"true"`
+	MaxConsecutiveDays   uint             `json:"MaxConsecutiveDays" validate:"max=366" example:"14"`
+	MinNoticeDays        uint             `json:"MinNoticeDays" validate:"max=366" example:"3"`
+	CountsAgainstBalance bool             `json:"CountsAgainstBalance" example:"true"`
+	IsActive             bool             `json:"IsActive" example:"true"`
+} //@name LeaveTypeRequest
+
+// applyLeaveTypeRequest copies the editable fields of the request onto the definition
+func applyLeaveTypeRequest(req LeaveTypeRequest, definition *models.LeaveTypeDefinition) {
+	definition.Name = req.Name
+	definition.Description = req.Description
+	definition.AttachmentRequired = req.AttachmentRequired
+	definition.IsPaid = req.IsPaid
+	definition.MaxConsecutiveDays = req.MaxConsecutiveDays
+	definition.MinNoticeDays = req.MinNoticeDays
+	definition.CountsAgainstBalance = req.CountsAgainstBalance
+	definition.IsActive = req.IsActive
+}
+
+// @Summary Get leave types
+// @Description Get the leave types that can currently be requested, with their rules
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.LeaveTypeDefinition
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/leave/types [get]
+// @Security BearerAuth
+func GetActiveLeaveTypes(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var definitions []models.LeaveTypeDefinition
+	if err := db.Where("is_active = ?", true).Order("name ASC").Find(&definitions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, definitions)
+}
+
+// @Summary Get all leave types
+// @Description Get every leave type, including inactive ones
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.LeaveTypeDefinition
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage leave types"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/types [get]
+// @Security BearerAuth
+func GetAllLeaveTypes(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var definitions []models.LeaveTypeDefinition
+	if err := db.Order("name ASC").Find(&definitions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, definitions)
+}
+
+// @Summary Create leave type
+// @Description Define a new leave type and its rules. The code is normalized to upper case.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param leaveType body LeaveTypeRequest true "Leave type data"
+// @Success 201 {object} models.LeaveTypeDefinition
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage leave types"
+// @Failure 409 {object} map[string]string "Leave type code already exists"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/types [post]
+// @Security BearerAuth
+func CreateLeaveType(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	var req LeaveTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	code := models.LeaveType(strings.ToUpper(strings.TrimSpace(string(req.Code))))
+	if strings.ContainsAny(string(code), " ,") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Code cannot contain spaces or commas"})
+		return
+	}
+
+	var count int64
+	db.Unscoped().Model(&models.LeaveTypeDefinition{}).Where("code = ?", code).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "A leave type with this code already exists"})
+		return
+	}
+
+	definition := models.LeaveTypeDefinition{Code: code}
+	applyLeaveTypeRequest(req, &definition)
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&definition).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create leave type"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityLeaveType, definition.ID, definition); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, definition)
+}
+
+// @Summary Update leave type
+// @Description Update the name and rules of a leave type. The code cannot be changed. Deactivate a type to stop new requests while keeping existing ones.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path int true "Leave type ID"
+// @Param leaveType body LeaveTypeRequest true "Leave type data"
+// @Success 200 {object} models.LeaveTypeDefinition
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage leave types"
+// @Failure 404 {object} map[string]string "Leave type not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/types/{id} [put]
+// @Security BearerAuth
+func UpdateLeaveType(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req LeaveTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var definition models.LeaveTypeDefinition
+	if err := db.First(&definition, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Leave type not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave type"})
+		return
+	}
+
+	req.Code = definition.Code
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	before := audit.Snapshot(definition)
+	applyLeaveTypeRequest(req, &definition)
+
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&definition).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave type"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityLeaveType, definition.ID, before, definition); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, definition)
+}
+
+// @Summary Delete leave type
+// @Description Delete a leave type that has never been used. Types with leave requests or entitlements must be deactivated instead.
+// @Tags leave
+// @Accept json
+// @Produce json
+// @Param id path int true "Leave type ID"
+// @Success 200 {object} map[string]string "Leave type deleted successfully"
+// @Failure 400 {object} map[string]string "Leave type is in use"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can manage leave types"
+// @Failure 404 {object} map[string]string "Leave type not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/leave/types/{id} [delete]
+// @Security BearerAuth
+func DeleteLeaveType(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var definition models.LeaveTypeDefinition
+	if err := db.First(&definition, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Leave type not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch leave type"})
+		return
+	}
+
+	var requestCount, entitlementCount int64
+	db.Model(&models.LeaveRequest{}).Where("leave_type = ?", definition.Code).Count(&requestCount)
+	db.Model(&models.LeaveEntitlement{}).Where("leave_type = ?", definition.Code).Count(&entitlementCount)
+	if requestCount > 0 || entitlementCount > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Cannot delete leave type - it is used by leave requests or entitlements. Deactivate it instead",
+		})
+		return
+	}
+
+	// Hard delete so the code can be reused
+	// Save the change and its audit entry together
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Unscoped().Delete(&definition).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete leave type"})
+		return
+	}
+	if err := audit.Deleted(tx, c, audit.EntityLeaveType, definition.ID, definition); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Leave type deleted successfully"})
+}
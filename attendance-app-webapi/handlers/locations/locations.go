@@ -1,31 +1,66 @@
 package locations
 
 import (
+	"encoding/json"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"attendance-app/audit"
 	"attendance-app/models"
+	"attendance-app/utils"
 )
 
 // CreateLocationRequest represents the request payload for creating a location
 type CreateLocationRequest struct {
-	Name      string  `json:"Name" validate:"required,max=255" example:"Head Office"`
-	Address   string  `json:"Address" validate:"max=500" example:"Jl. Sudirman No. 123, Jakarta"`
-	Latitude  float64 `json:"Latitude" validate:"required,latitude" example:"-6.200000"`
-	Longitude float64 `json:"Longitude" validate:"required,longitude" example:"106.816666"`
-	Radius    uint    `json:"Radius" validate:"required,min=10,max=50000" example:"100"`
+	Name    string `json:"Name" validate:"required,max=255" example:"Head Office"`
+	Address string `json:"Address" validate:"max=500" example:"Jl. Sudirman No. 123, Jakarta"`
+	// Latitude and Longitude default to the center of the geometry when both are omitted
+	Latitude  float64 `json:"Latitude" validate:"omitempty,latitude" example:"-6.200000"`
+	Longitude float64 `json:"Longitude" validate:"omitempty,longitude" example:"106.816666"`
+	// Radius in meters, required unless a geometry is given
+	Radius uint `json:"Radius" validate:"omitempty,min=10,max=50000" example:"100"`
+	// Geometry is an optional GeoJSON Polygon or MultiPolygon that replaces the radius check
+	Geometry json.RawMessage `json:"Geometry,omitempty" swaggertype:"object"`
 }
 
 // UpdateLocationRequest represents the request payload for updating a location
 type UpdateLocationRequest struct {
 	Name      string  `json:"Name" validate:"max=255" example:"Head Office"`
 	Address   string  `json:"Address" validate:"max=500" example:"Jl. Sudirman No. 123, Jakarta"`
-	Latitude  float64 `json:"Latitude" validate:"latitude" example:"-6.200000"`
-	Longitude float64 `json:"Longitude" validate:"longitude" example:"106.816666"`
-	Radius    uint    `json:"Radius" validate:"min=10,max=50000" example:"100"`
+	Latitude  float64 `json:"Latitude" validate:"omitempty,latitude" example:"-6.200000"`
+	Longitude float64 `json:"Longitude" validate:"omitempty,longitude" example:"106.816666"`
+	Radius    uint    `json:"Radius" validate:"omitempty,min=10,max=50000" example:"100"`
+	// Geometry replaces the stored geofence, send null to remove it and fall back to the radius
+	Geometry json.RawMessage `json:"Geometry,omitempty" swaggertype:"object"`
+}
+
+// LocationAssignmentRequest represents the users and roles to assign to or unassign from a location
+type LocationAssignmentRequest struct {
+	UserIDs []uint `json:"UserIDs" example:"2,3"`
+	RoleIDs []uint `json:"RoleIDs" example:"1"`
+}
+
+// LocationAssignmentsResponse lists the users and roles assigned to a location
+type LocationAssignmentsResponse struct {
+	Users []LocationAssignedUser `json:"Users"`
+	Roles []LocationAssignedRole `json:"Roles"`
+}
+
+// LocationAssignedUser is a user assigned to a location
+type LocationAssignedUser struct {
+	ID       uint   `json:"ID"`
+	Username string `json:"Username"`
+	Name     string `json:"Name"`
+}
+
+// LocationAssignedRole is a role assigned to a location
+type LocationAssignedRole struct {
+	ID       uint            `json:"ID"`
+	Name     models.RoleName `json:"Name"`
+	Position string          `json:"Position"`
 }
 
 // @Summary Get all locations
@@ -82,7 +117,7 @@ func GetLocationByID(c *gin.Context) {
 }
 
 // @Summary Create new location
-// @Description Create a new location
+// @Description Create a new location. Either a Radius or a GeoJSON Polygon/MultiPolygon Geometry is required; when a Geometry is given check-in is validated with point-in-polygon
 // @Tags locations
 // @Accept json
 // @Produce json
@@ -103,6 +138,11 @@ func CreateLocation(c *gin.Context) {
 		return
 	}
 
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
 	// Validate coordinates range
 	if req.Latitude < -90 || req.Latitude > 90 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Latitude must be between -90 and 90"})
@@ -113,24 +153,68 @@ func CreateLocation(c *gin.Context) {
 		return
 	}
 
+	var geometry json.RawMessage
+	if hasGeometry(req.Geometry) {
+		polygons, err := utils.ParseGeofence(req.Geometry)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid geometry: " + err.Error()})
+			return
+		}
+		geometry = req.Geometry
+
+		// Use the center of the geofence as the reference point when none is given
+		if req.Latitude == 0 && req.Longitude == 0 {
+			req.Latitude, req.Longitude = utils.GeofenceCenter(polygons)
+		}
+	} else {
+		// Without a geometry the location is the circle around the coordinates
+		if req.Radius == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Either a Radius or a Geometry is required"})
+			return
+		}
+		if req.Latitude == 0 && req.Longitude == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Latitude and Longitude are required when no geometry is provided"})
+			return
+		}
+	}
+
 	location := models.Location{
 		Name:      req.Name,
 		Address:   req.Address,
 		Latitude:  req.Latitude,
 		Longitude: req.Longitude,
 		Radius:    req.Radius,
+		Geometry:  geometry,
 	}
 
-	if err := DB.Create(&location).Error; err != nil {
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&location).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create location"})
 		return
 	}
+	if err := audit.Created(tx, c, audit.EntityLocation, location.ID, location); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
 
 	c.JSON(http.StatusCreated, location)
 }
 
 // @Summary Update location
-// @Description Update an existing location
+// @Description Update an existing location. Omit Geometry to keep the stored geofence, send a GeoJSON Polygon/MultiPolygon to replace it, or null to remove it
 // @Tags locations
 // @Accept json
 // @Produce json
@@ -154,6 +238,11 @@ func UpdateLocation(c *gin.Context) {
 		return
 	}
 
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
 	var location models.Location
 	if err := DB.First(&location, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -164,6 +253,8 @@ func UpdateLocation(c *gin.Context) {
 		return
 	}
 
+	before := audit.Snapshot(location)
+
 	// Update fields if provided
 	if req.Name != "" {
 		location.Name = req.Name
@@ -190,11 +281,44 @@ func UpdateLocation(c *gin.Context) {
 	if req.Radius != 0 {
 		location.Radius = req.Radius
 	}
+	if req.Geometry != nil {
+		if hasGeometry(req.Geometry) {
+			if _, err := utils.ParseGeofence(req.Geometry); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid geometry: " + err.Error()})
+				return
+			}
+			location.Geometry = req.Geometry
+		} else {
+			if location.Radius == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Radius is required when removing the geometry"})
+				return
+			}
+			location.Geometry = nil
+		}
+	}
 
-	if err := DB.Save(&location).Error; err != nil {
+	// Save the change and its audit entry together
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Save(&location).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update location"})
 		return
 	}
+	if err := audit.Updated(tx, c, audit.EntityLocation, location.ID, before, location); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
 
 	c.JSON(http.StatusOK, location)
 }
@@ -246,11 +370,292 @@ func DeleteLocation(c *gin.Context) {
 		return
 	}
 
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Remove user and role assignments of the location
+	if err := tx.Exec("DELETE FROM user_locations WHERE location_id = ?", locationID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove user assignments"})
+		return
+	}
+	if err := tx.Exec("DELETE FROM role_locations WHERE location_id = ?", locationID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove role assignments"})
+		return
+	}
+
 	// Soft delete
-	if err := DB.Delete(&location).Error; err != nil {
+	if err := tx.Delete(&location).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete location"})
 		return
 	}
+	if err := audit.Deleted(tx, c, audit.EntityLocation, location.ID, location); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "Location deleted successfully"})
 }
+
+// @Summary Get location assignments
+// @Description List the users and roles assigned to a location
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Param id path int true "Location ID"
+// @Success 200 {object} LocationAssignmentsResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can access locations"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/locations/{id}/assignments [get]
+// @Security BearerAuth
+func GetLocationAssignments(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var location models.Location
+	if err := DB.First(&location, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return
+	}
+
+	var users []models.User
+	if err := DB.Joins("JOIN user_locations ON user_locations.user_id = users.id").
+		Where("user_locations.location_id = ?", location.ID).
+		Order("users.name ASC").
+		Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assigned users"})
+		return
+	}
+
+	var roles []models.Role
+	if err := DB.Joins("JOIN role_locations ON role_locations.role_id = roles.id").
+		Where("role_locations.location_id = ?", location.ID).
+		Order("roles.position_level ASC").
+		Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch assigned roles"})
+		return
+	}
+
+	response := LocationAssignmentsResponse{
+		Users: []LocationAssignedUser{},
+		Roles: []LocationAssignedRole{},
+	}
+	for _, user := range users {
+		response.Users = append(response.Users, LocationAssignedUser{ID: user.ID, Username: user.Username, Name: user.Name})
+	}
+	for _, role := range roles {
+		response.Roles = append(response.Roles, LocationAssignedRole{ID: role.ID, Name: role.Name, Position: role.Position})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Assign location
+// @Description Allow users and/or roles to check in at a location. Users may check in at any location assigned to them or to their role.
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Param id path int true "Location ID"
+// @Param assignment body LocationAssignmentRequest true "Users and roles to assign"
+// @Success 200 {object} map[string]string "Location assigned successfully"
+// @Failure 400 {object} map[string]string "Invalid request or unknown users/roles"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can assign locations"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/locations/{id}/assign [post]
+// @Security BearerAuth
+func AssignLocation(c *gin.Context) {
+	updateLocationAssignments(c, true)
+}
+
+// @Summary Unassign location
+// @Description Remove users and/or roles from a location
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Param id path int true "Location ID"
+// @Param assignment body LocationAssignmentRequest true "Users and roles to unassign"
+// @Success 200 {object} map[string]string "Location unassigned successfully"
+// @Failure 400 {object} map[string]string "Invalid request or unknown users/roles"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can unassign locations"
+// @Failure 404 {object} map[string]string "Location not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/locations/{id}/unassign [post]
+// @Security BearerAuth
+func UnassignLocation(c *gin.Context) {
+	updateLocationAssignments(c, false)
+}
+
+// updateLocationAssignments appends or removes the location on the requested users and roles
+func updateLocationAssignments(c *gin.Context, assign bool) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req LocationAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if len(req.UserIDs) == 0 && len(req.RoleIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one user or role is required"})
+		return
+	}
+
+	var location models.Location
+	if err := DB.First(&location, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Location not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch location"})
+		return
+	}
+
+	var users []models.User
+	if len(req.UserIDs) > 0 {
+		if err := DB.Where("id IN ?", req.UserIDs).Find(&users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+			return
+		}
+		if len(users) != len(uniqueIDs(req.UserIDs)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "One or more users not found"})
+			return
+		}
+	}
+
+	var roles []models.Role
+	if len(req.RoleIDs) > 0 {
+		if err := DB.Where("id IN ?", req.RoleIDs).Find(&roles).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch roles"})
+			return
+		}
+		if len(roles) != len(uniqueIDs(req.RoleIDs)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "One or more roles not found"})
+			return
+		}
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i := range users {
+		association := tx.Model(&users[i]).Association("Locations")
+		var err error
+		if assign {
+			err = association.Append(&location)
+		} else {
+			err = association.Delete(&location)
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user assignments"})
+			return
+		}
+	}
+
+	for i := range roles {
+		association := tx.Model(&roles[i]).Association("Locations")
+		var err error
+		if assign {
+			err = association.Append(&location)
+		} else {
+			err = association.Delete(&location)
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role assignments"})
+			return
+		}
+	}
+
+	// Assignments are recorded as a change of the location's users and roles
+	prefix := "Unassigned"
+	if assign {
+		prefix = "Assigned"
+	}
+	assignment := map[string]interface{}{prefix + "UserIDs": uniqueIDs(req.UserIDs), prefix + "RoleIDs": uniqueIDs(req.RoleIDs)}
+	if err := audit.Updated(tx, c, audit.EntityLocation, location.ID, map[string]interface{}{}, assignment); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	if assign {
+		c.JSON(http.StatusOK, gin.H{"message": "Location assigned successfully"})
+	} else {
+		c.JSON(http.StatusOK, gin.H{"message": "Location unassigned successfully"})
+	}
+}
+
+// uniqueIDs removes duplicate IDs from a list
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool)
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
+
+// @Summary Get my locations
+// @Description Get the locations where the current user may check in (assigned directly, through their role, or the default location)
+// @Tags locations
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.LocationSwagger
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/locations [get]
+// @Security BearerAuth
+func GetMyLocations(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	locations, err := utils.GetUserLocations(DB, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load assigned locations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, locations)
+}
+
+// hasGeometry reports whether a geometry was sent as something other than JSON null
+func hasGeometry(raw json.RawMessage) bool {
+	return len(raw) > 0 && string(raw) != "null"
+}
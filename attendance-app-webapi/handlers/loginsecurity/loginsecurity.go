@@ -0,0 +1,182 @@
+// Package loginsecurity lets admins review failed logins and clear lockouts
+package loginsecurity
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/models"
+	"attendance-app/security"
+	"attendance-app/utils"
+)
+
+// @Summary Get login lockouts
+// @Description List the usernames and IP addresses whose logins are currently delayed or locked out after failed logins. Pass all=true to include every failure counter.
+// @Tags security
+// @Accept json
+// @Produce json
+// @Param kind query string false "USERNAME or IP"
+// @Param all query bool false "Include counters that are not blocked"
+// @Success 200 {array} models.LoginThrottle
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can view lockouts"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/lockouts [get]
+// @Security BearerAuth
+func GetLockouts(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	query := DB.Model(&models.LoginThrottle{})
+	if c.Query("all") != "true" {
+		query = query.Where("blocked_until > ?", time.Now())
+	}
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var throttles []models.LoginThrottle
+	if err := query.Order("last_failure_at DESC").Find(&throttles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockouts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, throttles)
+}
+
+// @Summary Clear login lockout
+// @Description Reset the failed logins of a username or IP address so that it can log in again immediately
+// @Tags security
+// @Accept json
+// @Produce json
+// @Param id path int true "Lockout ID"
+// @Success 200 {object} map[string]string "Lockout cleared successfully"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can clear lockouts"
+// @Failure 404 {object} map[string]string "Lockout not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/lockouts/{id} [delete]
+// @Security BearerAuth
+func ClearLockout(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var throttle models.LoginThrottle
+	if err := DB.First(&throttle, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Lockout not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lockout"})
+		return
+	}
+
+	if err := DB.Delete(&throttle).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear lockout"})
+		return
+	}
+
+	username := ""
+	if throttle.Kind == models.ThrottleUsername {
+		username = throttle.Value
+	}
+	detail := fmt.Sprintf("%s %s cleared by %s", throttle.Kind, throttle.Value, c.GetString("username"))
+	if err := security.Record(DB, c, models.SecurityLockoutCleared, username, nil, detail); err != nil {
+		fmt.Printf("Failed to record security event: %v\n", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared successfully"})
+}
+
+// @Summary Get security events
+// @Description Query failed logins, blocked attempts, lockouts and cleared lockouts, newest first. Filter by type, username, user, IP address and date range (inclusive, YYYY-MM-DD).
+// @Tags security
+// @Accept json
+// @Produce json
+// @Param type query string false "LOGIN_FAILED, LOGIN_BLOCKED, LOCKED_OUT, LOCKOUT_CLEARED or TWO_FACTOR_FAILED"
+// @Param username query string false "Username that was tried"
+// @Param userId query int false "User ID"
+// @Param ip query string false "IP address"
+// @Param from query string false "From date (YYYY-MM-DD)"
+// @Param to query string false "To date (YYYY-MM-DD)"
+// @Param page query int false "Page number"
+// @Param pageSize query int false "Page size (max 100)"
+// @Success 200 {array} models.SecurityEvent
+// @Failure 400 {object} map[string]string "Invalid filter"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can view security events"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/security-events [get]
+// @Security BearerAuth
+func GetSecurityEvents(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	query := DB.Model(&models.SecurityEvent{})
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if userId := c.Query("userId"); userId != "" {
+		query = query.Where("user_id = ?", userId)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip_address = ?", ip)
+	}
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.ParseInLocation(utils.CalendarDateFormat, from, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date format. Use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("created_at >= ?", fromDate)
+	}
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.ParseInLocation(utils.CalendarDateFormat, to, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date format. Use YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("created_at < ?", toDate.AddDate(0, 0, 1))
+	}
+
+	// Get pagination params
+	params := utils.GetPaginationParams(c)
+
+	// Apply search if provided
+	if params.Search != "" {
+		searchPattern := "%" + params.Search + "%"
+		query = query.Where("username LIKE ? OR ip_address LIKE ? OR detail LIKE ?", searchPattern, searchPattern, searchPattern)
+	}
+
+	// Count total rows
+	var totalRows int64
+	if err := query.Count(&totalRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count security events"})
+		return
+	}
+
+	// Validate sortBy field
+	allowedSortFields := map[string]bool{
+		"id": true, "created_at": true, "type": true, "username": true, "ip_address": true,
+	}
+	if !allowedSortFields[params.SortBy] {
+		params.SortBy = "id"
+	}
+
+	var events []models.SecurityEvent
+	query = utils.ApplyPagination(query, params)
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch security events"})
+		return
+	}
+
+	// Build paginated response
+	response := utils.BuildPaginatedResponse(events, totalRows, params)
+	c.JSON(http.StatusOK, response)
+}
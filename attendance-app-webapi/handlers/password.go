@@ -0,0 +1,258 @@
+package handlers
+
+import (
+	"attendance-app/audit"
+	"attendance-app/config"
+	"attendance-app/models"
+	"attendance-app/utils"
+	"attendance-app/utils/email"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Password reset limits. Requests over the account limit are silently ignored
+// so that the response does not reveal whether the account exists.
+const (
+	defaultPasswordResetLifetime = time.Hour
+	passwordResetAccountLimit    = 3
+	passwordResetIPLimit         = 10
+	passwordResetLimitWindow     = time.Hour
+)
+
+type forgotPasswordPayload struct {
+	Email string `json:"email" binding:"required" validate:"email" example:"john@example.com"`
+}
+
+type resetPasswordPayload struct {
+	Token       string `json:"token" binding:"required" example:"3f1c9a..."`
+	NewPassword string `json:"newPassword" binding:"required" validate:"min=8,max=72" example:"newsecretpassword123"`
+}
+
+// passwordResetLifetime returns how long reset links stay valid (PASSWORD_RESET_EXP_TIME)
+func passwordResetLifetime() time.Duration {
+	value := config.Config("PASSWORD_RESET_EXP_TIME")
+	if value == "" {
+		return defaultPasswordResetLifetime
+	}
+	lifetime, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid PASSWORD_RESET_EXP_TIME configuration: %v", err)
+	}
+	return lifetime
+}
+
+// passwordResetLink returns the link of the frontend's reset page (PASSWORD_RESET_URL) for the token
+func passwordResetLink(token string) string {
+	link := config.Config("PASSWORD_RESET_URL")
+	if link == "" {
+		link = "http://localhost:3000/reset-password"
+	}
+	return link + "?token=" + url.QueryEscape(token)
+}
+
+// sendPasswordResetEmail emails the reset link to the user
+func sendPasswordResetEmail(user models.User, token string, expiresAt time.Time) {
+	if err := email.SendPasswordReset(user.Email, user.Name, passwordResetLink(token), expiresAt); err != nil {
+		log.Printf("Failed to send password reset email to user %d: %v", user.ID, err)
+	}
+}
+
+// @Summary Forgot password
+// @Description Email a single-use link to reset the password of the account with this email. The response is the same whether or not the account exists. Requests are rate limited per account and per IP address.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body forgotPasswordPayload true "Account email"
+// @Success 200 {object} map[string]string "Reset link sent if the account exists"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 429 {object} map[string]string "Too many requests"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /password/forgot [post]
+func ForgotPassword(c *gin.Context) {
+	var payload forgotPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	conn := c.MustGet("db").(*gorm.DB)
+	response := gin.H{"message": "if an account exists for this email, a password reset link has been sent"}
+
+	allowed, err := utils.AllowRateLimited(conn, "password_reset:ip:"+c.ClientIP(), passwordResetIPLimit, passwordResetLimitWindow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many password reset requests, try again later"})
+		return
+	}
+
+	var user models.User
+	if err := conn.Where("email = ?", payload.Email).First(&user).Error; err != nil {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	allowed, err = utils.AllowRateLimited(conn, "password_reset:user:"+strconv.FormatUint(uint64(user.ID), 10),
+		passwordResetAccountLimit, passwordResetLimitWindow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+
+	tx := conn.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only the latest link works
+	if err := tx.Unscoped().Where("user_id = ? AND used_at IS NULL", user.ID).
+		Delete(&models.PasswordResetToken{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+
+	resetToken := models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetLifetime()),
+		IPAddress: c.ClientIP(),
+	}
+	if err := tx.Create(&resetToken).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not process request"})
+		return
+	}
+
+	// Sent in the background so the response time does not reveal whether the account exists
+	go sendPasswordResetEmail(user, token, resetToken.ExpiresAt)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Reset password
+// @Description Set a new password with the token from a password reset link. The token can be used once. Every session of the user is logged out.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body resetPasswordPayload true "Reset token and new password"
+// @Success 200 {object} map[string]string "Password reset successfully"
+// @Failure 400 {object} map[string]string "Invalid request payload or invalid or expired token"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /password/reset [post]
+func ResetPassword(c *gin.Context) {
+	var payload resetPasswordPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	if err := utils.Validate.Struct(payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	conn := c.MustGet("db").(*gorm.DB)
+
+	var resetToken models.PasswordResetToken
+	if err := conn.Where("token_hash = ?", utils.HashToken(payload.Token)).First(&resetToken).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+	now := time.Now()
+	if resetToken.UsedAt != nil || !now.Before(resetToken.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+
+	var user models.User
+	if err := conn.First(&user, resetToken.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(payload.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset password"})
+		return
+	}
+
+	tx := conn.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only the first use succeeds, also when two resets race
+	result := tx.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", resetToken.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset password"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+		return
+	}
+
+	before := audit.Snapshot(user)
+	user.Password = hashedPassword
+	if err := tx.Model(&user).Update("password", hashedPassword).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset password"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	// Whoever knew the old password must not stay logged in
+	if err := revokeAllSessions(tx, user.ID, models.SessionPasswordReset); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset password"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset successfully"})
+}
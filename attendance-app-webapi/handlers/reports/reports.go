@@ -0,0 +1,370 @@
+package reports
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// reportMonthFormat is the format of the month query parameter
+const reportMonthFormat = "2006-01"
+
+// MonthlyReportResponse lists the monthly summaries of several users
+type MonthlyReportResponse struct {
+	Year      int                    `json:"year" example:"2025"`
+	Month     int                    `json:"month" example:"10"`
+	Summaries []utils.MonthlySummary `json:"summaries"`
+} //@name MonthlyReportResponse
+
+// reportMonth parses the month query parameter (YYYY-MM), the current month
+// when omitted. It responds with the error and returns false when it is invalid.
+func reportMonth(c *gin.Context) (int, time.Month, bool) {
+	value := c.Query("month")
+	if value == "" {
+		now := time.Now()
+		return now.Year(), now.Month(), true
+	}
+	month, err := time.ParseInLocation(reportMonthFormat, value, time.Local)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid month format. Use YYYY-MM"})
+		return 0, 0, false
+	}
+	return month.Year(), month.Month(), true
+}
+
+// respondMonthlySummaries responds with the summaries of the users for the requested month
+func respondMonthlySummaries(c *gin.Context, db *gorm.DB, lines utils.ReportingLines) {
+	year, month, ok := reportMonth(c)
+	if !ok {
+		return
+	}
+
+	summaries, err := utils.GetMonthlySummaries(db, lines.UserIDs, year, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute monthly summaries"})
+		return
+	}
+	for i := range summaries {
+		summaries[i].ReportingPath = lines.Path(summaries[i].UserID)
+	}
+
+	c.JSON(http.StatusOK, MonthlyReportResponse{
+		Year:      year,
+		Month:     int(month),
+		Summaries: summaries,
+	})
+}
+
+// exportMonthlySummaries writes the summaries of the users for the requested
+// month to an Excel file, with a column per leave type that occurs
+func exportMonthlySummaries(c *gin.Context, db *gorm.DB, lines utils.ReportingLines, filePrefix string) {
+	year, month, ok := reportMonth(c)
+	if !ok {
+		return
+	}
+
+	summaries, err := utils.GetMonthlySummaries(db, lines.UserIDs, year, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute monthly summaries"})
+		return
+	}
+
+	// Create new Excel file
+	f := excelize.NewFile()
+	defer func() {
+		if err := f.Close(); err != nil {
+			return
+		}
+	}()
+
+	sheetName := "Monthly Summary"
+	index, err := f.NewSheet(sheetName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create Excel sheet"})
+		return
+	}
+
+	// Set headers
+	leaveTypes := utils.SummaryLeaveTypes(summaries)
+	headers := []string{
+		"User ID", "Username", "Name", "Month", "Working Days", "Present Days", "On Time Days",
+		"Late Days", "Late Minutes", "Absences", "Didn't Checkout", "Hours Worked", "Leave Days",
+	}
+	for _, leaveType := range leaveTypes {
+		headers = append(headers, fmt.Sprintf("%s Leave Days", leaveType))
+	}
+	if lines.Paths != nil {
+		headers = append(headers, "Reporting Path")
+	}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
+		f.SetCellValue(sheetName, cell, header)
+	}
+
+	// Style for headers
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"#E0E0E0"}, Pattern: 1},
+	})
+	if err == nil {
+		lastHeader, _ := excelize.CoordinatesToCellName(len(headers), 1)
+		f.SetCellStyle(sheetName, "A1", lastHeader, headerStyle)
+	}
+
+	// Write summary data
+	monthLabel := fmt.Sprintf("%04d-%02d", year, month)
+	for i, summary := range summaries {
+		values := []interface{}{
+			summary.UserID, summary.Username, summary.Name, monthLabel, summary.WorkingDays,
+			summary.PresentDays, summary.OnTimeDays, summary.LateDays, summary.LateMinutes,
+			summary.Absences, summary.DidntCheckout, summary.HoursWorked, summary.LeaveDays,
+		}
+		for _, leaveType := range leaveTypes {
+			values = append(values, summary.LeaveDaysByType[leaveType])
+		}
+		if lines.Paths != nil {
+			values = append(values, utils.FormatReportingPath(lines.Path(summary.UserID)))
+		}
+
+		for j, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(j+1, i+2)
+			f.SetCellValue(sheetName, cell, value)
+		}
+	}
+
+	// Auto-fit columns
+	for i := 1; i <= len(headers); i++ {
+		col, _ := excelize.ColumnNumberToName(i)
+		f.SetColWidth(sheetName, col, col, 18)
+	}
+
+	// Set active sheet
+	f.SetActiveSheet(index)
+	f.DeleteSheet("Sheet1")
+
+	// Generate filename with the month of the report
+	filename := fmt.Sprintf("%s_%s.xlsx", filePrefix, monthLabel)
+
+	// Set headers for file download
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Header("Content-Transfer-Encoding", "binary")
+
+	// Write to response
+	if err := f.Write(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write Excel file"})
+		return
+	}
+}
+
+// subordinateLines returns the reporting lines below the current user down to
+// the requested depth. It responds with the error and returns false on failure.
+func subordinateLines(c *gin.Context, db *gorm.DB) (utils.ReportingLines, bool) {
+	supervisorId := c.MustGet("userId").(uint)
+
+	depth, err := utils.ReportingDepthQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return utils.ReportingLines{}, false
+	}
+
+	// Includes the subordinates of supervisors who delegated their approval rights
+	lines, err := utils.GetReportingLines(db, supervisorId, depth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates"})
+		return utils.ReportingLines{}, false
+	}
+	return lines, true
+}
+
+// companyUsers returns every user, or a single user or the users of a
+// department (and its sub-departments with subtree=true) when asked for. It
+// responds with the error and returns false when a filter is invalid.
+func companyUsers(c *gin.Context, db *gorm.DB) (utils.ReportingLines, bool) {
+	query := db.Model(&models.User{})
+
+	if value := c.Query("userId"); value != "" {
+		userId, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || userId == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return utils.ReportingLines{}, false
+		}
+		query = query.Where("id = ?", userId)
+	}
+
+	departmentID, err := utils.DepartmentQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return utils.ReportingLines{}, false
+	}
+	if departmentID != nil {
+		departmentIDs := []uint{*departmentID}
+		if c.Query("subtree") == "true" {
+			if departmentIDs, err = utils.GetDepartmentSubtreeIDs(db, *departmentID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-departments"})
+				return utils.ReportingLines{}, false
+			}
+		}
+		query = query.Where("department_id IN ?", departmentIDs)
+	}
+
+	var userIds []uint
+	if err := query.Pluck("id", &userIds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
+		return utils.ReportingLines{}, false
+	}
+	return utils.ReportingLines{UserIDs: userIds}, true
+}
+
+// @Summary Get my monthly summary
+// @Description Summarize the current user's attendance and leave in a month: working days, on-time and late days, late minutes, absences, leave days by type, didn't checkout count and hours worked
+// @Tags reports
+// @Security BearerAuth
+// @Produce json
+// @Param month query string false "Month (YYYY-MM, default: current month)" example(2025-10)
+// @Success 200 {object} utils.MonthlySummary
+// @Failure 400 {object} map[string]string "Invalid month"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/reports/monthly [get]
+func GetMyMonthlySummary(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	year, month, ok := reportMonth(c)
+	if !ok {
+		return
+	}
+
+	summaries, err := utils.GetMonthlySummaries(db, []uint{userId}, year, month)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute monthly summary"})
+		return
+	}
+	if len(summaries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, summaries[0])
+}
+
+// @Summary Export my monthly summary to Excel
+// @Description Export the current user's monthly attendance and leave summary to an Excel file
+// @Tags reports
+// @Security BearerAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param month query string false "Month (YYYY-MM, default: current month)" example(2025-10)
+// @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid month"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/reports/monthly/export/excel [get]
+func ExportMyMonthlySummaryToExcel(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	exportMonthlySummaries(c, db, utils.ReportingLines{UserIDs: []uint{userId}}, "my_monthly_summary")
+}
+
+// @Summary Get subordinate monthly summaries
+// @Description Summarize the attendance and leave of the current user's subordinates in a month (supervisor only). With depth subordinates further down the reporting tree are included; every summary carries the reporting path from the supervisor down to its user.
+// @Tags reports
+// @Security BearerAuth
+// @Produce json
+// @Param month query string false "Month (YYYY-MM, default: current month)" example(2025-10)
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
+// @Success 200 {object} MonthlyReportResponse
+// @Failure 400 {object} map[string]string "Invalid month or depth"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/reports/monthly/subordinates [get]
+func GetSubordinateMonthlySummaries(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	lines, ok := subordinateLines(c, db)
+	if !ok {
+		return
+	}
+	respondMonthlySummaries(c, db, lines)
+}
+
+// @Summary Export subordinate monthly summaries to Excel
+// @Description Export the monthly attendance and leave summaries of the current user's subordinates to an Excel file (supervisor only). With depth subordinates further down the reporting tree are included, and the Reporting Path column shows the reporting line down to each user.
+// @Tags reports
+// @Security BearerAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param month query string false "Month (YYYY-MM, default: current month)" example(2025-10)
+// @Param depth query string false "Reporting levels to include: a number, or all for the whole reporting tree (default: 1, direct reports)"
+// @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid month or depth"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/reports/monthly/subordinates/export/excel [get]
+func ExportSubordinateMonthlySummariesToExcel(c *gin.Context) {
+	db := c.MustGet("db").(*gorm.DB)
+
+	lines, ok := subordinateLines(c, db)
+	if !ok {
+		return
+	}
+	exportMonthlySummaries(c, db, lines, "subordinate_monthly_summary")
+}
+
+// @Summary Get company monthly summaries
+// @Description Summarize the attendance and leave of every user in a month, optionally of a single user or of a department
+// @Tags reports
+// @Security BearerAuth
+// @Produce json
+// @Param month query string false "Month (YYYY-MM, default: current month)" example(2025-10)
+// @Param userId query int false "Only this user"
+// @Param departmentId query int false "Only the users of this department"
+// @Param subtree query bool false "Include the users of sub-departments of departmentId"
+// @Success 200 {object} MonthlyReportResponse
+// @Failure 400 {object} map[string]string "Invalid month, user ID or department ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires reports.read"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/reports/monthly [get]
+func GetMonthlySummaries(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	users, ok := companyUsers(c, DB)
+	if !ok {
+		return
+	}
+	respondMonthlySummaries(c, DB, users)
+}
+
+// @Summary Export company monthly summaries to Excel
+// @Description Export the monthly attendance and leave summaries of every user to an Excel file, optionally of a single user or of a department
+// @Tags reports
+// @Security BearerAuth
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param month query string false "Month (YYYY-MM, default: current month)" example(2025-10)
+// @Param userId query int false "Only this user"
+// @Param departmentId query int false "Only the users of this department"
+// @Param subtree query bool false "Include the users of sub-departments of departmentId"
+// @Success 200 {file} binary "Excel file download"
+// @Failure 400 {object} map[string]string "Invalid month, user ID or department ID"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires reports.export"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/reports/monthly/export/excel [get]
+func ExportMonthlySummariesToExcel(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	users, ok := companyUsers(c, DB)
+	if !ok {
+		return
+	}
+	exportMonthlySummaries(c, DB, users, "monthly_summary")
+}
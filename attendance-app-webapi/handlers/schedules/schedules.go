@@ -0,0 +1,600 @@
+package schedules
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// WorkScheduleRequest represents the request payload for creating or updating a work schedule
+type WorkScheduleRequest struct {
+	Name               string `json:"Name" validate:"required,max=255" example:"Morning Shift"`
+	StartTime          string `json:"StartTime" validate:"required" example:"07:30"`
+	EndTime            string `json:"EndTime" validate:"required" example:"16:00"`
+	BreakStart         string `json:"BreakStart" example:"12:00"`
+	BreakEnd           string `json:"BreakEnd" example:"13:00"`
+	GracePeriodMinutes uint   `json:"GracePeriodMinutes" validate:"max=720" example:"15"`
+	WorkingDays        string `json:"WorkingDays" validate:"required" example:"1,2,3,4,5"`
+	IsDefault          bool   `json:"IsDefault" example:"false"`
+}
+
+// ScheduleAssignmentRequest represents the users and roles to assign or unassign a schedule
+type ScheduleAssignmentRequest struct {
+	UserIDs []uint `json:"UserIDs" example:"2,3"`
+	RoleIDs []uint `json:"RoleIDs" example:"1"`
+}
+
+// validateScheduleRequest checks the time and weekday formats of a schedule request
+func validateScheduleRequest(req WorkScheduleRequest) error {
+	if _, _, err := models.ParseClock(req.StartTime); err != nil {
+		return err
+	}
+	if _, _, err := models.ParseClock(req.EndTime); err != nil {
+		return err
+	}
+	if req.StartTime == req.EndTime {
+		return errors.New("start time and end time cannot be the same")
+	}
+	if (req.BreakStart == "") != (req.BreakEnd == "") {
+		return errors.New("break start and break end must be given together")
+	}
+	if req.BreakStart != "" {
+		if _, _, err := models.ParseClock(req.BreakStart); err != nil {
+			return err
+		}
+		if _, _, err := models.ParseClock(req.BreakEnd); err != nil {
+			return err
+		}
+		if req.BreakStart == req.BreakEnd {
+			return errors.New("break start and break end cannot be the same")
+		}
+		schedule := models.WorkSchedule{StartTime: req.StartTime, EndTime: req.EndTime, BreakStart: req.BreakStart, BreakEnd: req.BreakEnd}
+		day := time.Date(2000, 1, 3, 0, 0, 0, 0, time.Local)
+		shiftStart, shiftEnd := schedule.ShiftWindow(day)
+		breakStart, breakEnd := schedule.BreakWindow(day)
+		if !breakStart.After(shiftStart) || !breakEnd.Before(shiftEnd) {
+			return errors.New("the break must fall within the shift")
+		}
+	}
+	days, err := models.ParseWorkingDays(req.WorkingDays)
+	if err != nil {
+		return err
+	}
+	if len(days) == 0 {
+		return errors.New("at least one working day is required")
+	}
+	return nil
+}
+
+// normalizeWorkingDays returns the weekday list sorted and without duplicates
+func normalizeWorkingDays(value string) string {
+	days, _ := models.ParseWorkingDays(value)
+	parts := make([]string, 0, len(days))
+	for day := 0; day <= 6; day++ {
+		if days[time.Weekday(day)] {
+			parts = append(parts, strconv.Itoa(day))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// @Summary Get all work schedules
+// @Description Retrieve all work schedules
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.WorkSchedule
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can access schedules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules [get]
+// @Security BearerAuth
+func GetAllSchedules(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var schedules []models.WorkSchedule
+	if err := DB.Order("name ASC").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedules)
+}
+
+// @Summary Get work schedule by ID
+// @Description Retrieve a single work schedule by its ID
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} models.WorkSchedule
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can access schedules"
+// @Failure 404 {object} map[string]string "Work schedule not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules/{id} [get]
+// @Security BearerAuth
+func GetScheduleByID(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var schedule models.WorkSchedule
+	if err := DB.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Work schedule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// @Summary Create work schedule
+// @Description Create a new work schedule (shift). Times use HH:MM, an end time earlier than the start time means the shift ends the next day. Working days are weekday numbers where 0 is Sunday. A split shift is entered with BreakStart and BreakEnd, which must fall within the shift: the break is not working time for the grace period, lateness, leave hours and worked hours, and there is still one check-in per day.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param schedule body WorkScheduleRequest true "Work schedule data"
+// @Success 201 {object} models.WorkSchedule
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can create schedules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules [post]
+// @Security BearerAuth
+func CreateSchedule(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var req WorkScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	if err := validateScheduleRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule := models.WorkSchedule{
+		Name:               req.Name,
+		StartTime:          req.StartTime,
+		EndTime:            req.EndTime,
+		BreakStart:         req.BreakStart,
+		BreakEnd:           req.BreakEnd,
+		GracePeriodMinutes: req.GracePeriodMinutes,
+		WorkingDays:        normalizeWorkingDays(req.WorkingDays),
+		IsDefault:          req.IsDefault,
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only one schedule can be the default
+	if schedule.IsDefault {
+		if err := tx.Model(&models.WorkSchedule{}).Where("is_default = ?", true).
+			Update("is_default", false).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update default work schedule"})
+			return
+		}
+	}
+
+	if err := tx.Create(&schedule).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create work schedule"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntitySchedule, schedule.ID, schedule); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// @Summary Update work schedule
+// @Description Update an existing work schedule
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param schedule body WorkScheduleRequest true "Work schedule data"
+// @Success 200 {object} models.WorkSchedule
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can update schedules"
+// @Failure 404 {object} map[string]string "Work schedule not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules/{id} [put]
+// @Security BearerAuth
+func UpdateSchedule(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req WorkScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	if err := validateScheduleRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var schedule models.WorkSchedule
+	if err := DB.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Work schedule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work schedule"})
+		return
+	}
+
+	before := audit.Snapshot(schedule)
+
+	schedule.Name = req.Name
+	schedule.StartTime = req.StartTime
+	schedule.EndTime = req.EndTime
+	schedule.BreakStart = req.BreakStart
+	schedule.BreakEnd = req.BreakEnd
+	schedule.GracePeriodMinutes = req.GracePeriodMinutes
+	schedule.WorkingDays = normalizeWorkingDays(req.WorkingDays)
+	schedule.IsDefault = req.IsDefault
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Only one schedule can be the default
+	if schedule.IsDefault {
+		if err := tx.Model(&models.WorkSchedule{}).Where("is_default = ? AND id != ?", true, schedule.ID).
+			Update("is_default", false).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update default work schedule"})
+			return
+		}
+	}
+
+	if err := tx.Save(&schedule).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update work schedule"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntitySchedule, schedule.ID, before, schedule); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// @Summary Delete work schedule
+// @Description Soft delete a work schedule. Users and roles assigned to it fall back to the default schedule.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]string "Work schedule deleted successfully"
+// @Failure 400 {object} map[string]string "Cannot delete the default schedule"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can delete schedules"
+// @Failure 404 {object} map[string]string "Work schedule not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules/{id} [delete]
+// @Security BearerAuth
+func DeleteSchedule(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var schedule models.WorkSchedule
+	if err := DB.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Work schedule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work schedule"})
+		return
+	}
+
+	if schedule.IsDefault {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot delete the default work schedule"})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Unassign the schedule so users and roles fall back to the default
+	if err := tx.Model(&models.User{}).Where("work_schedule_id = ?", schedule.ID).
+		Update("work_schedule_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign users"})
+		return
+	}
+	if err := tx.Model(&models.Role{}).Where("work_schedule_id = ?", schedule.ID).
+		Update("work_schedule_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign roles"})
+		return
+	}
+
+	// Soft delete
+	if err := tx.Delete(&schedule).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete work schedule"})
+		return
+	}
+	if err := audit.Deleted(tx, c, audit.EntitySchedule, schedule.ID, schedule); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Work schedule deleted successfully"})
+}
+
+// @Summary Assign work schedule
+// @Description Assign a work schedule to users and/or roles. A schedule assigned to a user takes precedence over the role's schedule.
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "Schedule ID"
+// @Param assignment body ScheduleAssignmentRequest true "Users and roles to assign"
+// @Success 200 {object} map[string]interface{} "Schedule assigned successfully"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can assign schedules"
+// @Failure 404 {object} map[string]string "Work schedule not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules/{id}/assign [post]
+// @Security BearerAuth
+func AssignSchedule(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req ScheduleAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if len(req.UserIDs) == 0 && len(req.RoleIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one user or role is required"})
+		return
+	}
+
+	var schedule models.WorkSchedule
+	if err := DB.First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Work schedule not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch work schedule"})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var usersUpdated, rolesUpdated int64
+	if len(req.UserIDs) > 0 {
+		if err := auditScheduleAssignment(tx, c, audit.EntityUser, &models.User{}, req.UserIDs, &schedule.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		result := tx.Model(&models.User{}).Where("id IN ?", req.UserIDs).Update("work_schedule_id", schedule.ID)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign work schedule to users"})
+			return
+		}
+		usersUpdated = result.RowsAffected
+	}
+	if len(req.RoleIDs) > 0 {
+		if err := auditScheduleAssignment(tx, c, audit.EntityRole, &models.Role{}, req.RoleIDs, &schedule.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		result := tx.Model(&models.Role{}).Where("id IN ?", req.RoleIDs).Update("work_schedule_id", schedule.ID)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to assign work schedule to roles"})
+			return
+		}
+		rolesUpdated = result.RowsAffected
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Work schedule assigned successfully",
+		"usersUpdated": usersUpdated,
+		"rolesUpdated": rolesUpdated,
+	})
+}
+
+// @Summary Unassign work schedule
+// @Description Remove the directly assigned work schedule from users and/or roles so they fall back to the role or default schedule
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param assignment body ScheduleAssignmentRequest true "Users and roles to unassign"
+// @Success 200 {object} map[string]interface{} "Schedule unassigned successfully"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can unassign schedules"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/schedules/unassign [post]
+// @Security BearerAuth
+func UnassignSchedule(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var req ScheduleAssignmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if len(req.UserIDs) == 0 && len(req.RoleIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one user or role is required"})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var usersUpdated, rolesUpdated int64
+	if len(req.UserIDs) > 0 {
+		if err := auditScheduleAssignment(tx, c, audit.EntityUser, &models.User{}, req.UserIDs, nil); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		result := tx.Model(&models.User{}).Where("id IN ?", req.UserIDs).Update("work_schedule_id", nil)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign work schedule from users"})
+			return
+		}
+		usersUpdated = result.RowsAffected
+	}
+	if len(req.RoleIDs) > 0 {
+		if err := auditScheduleAssignment(tx, c, audit.EntityRole, &models.Role{}, req.RoleIDs, nil); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		result := tx.Model(&models.Role{}).Where("id IN ?", req.RoleIDs).Update("work_schedule_id", nil)
+		if result.Error != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign work schedule from roles"})
+			return
+		}
+		rolesUpdated = result.RowsAffected
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "Work schedule unassigned successfully",
+		"usersUpdated": usersUpdated,
+		"rolesUpdated": rolesUpdated,
+	})
+}
+
+// auditScheduleAssignment records the change of the directly assigned schedule of the given users or roles.
+// Call it before updating them so the previous schedule is captured.
+func auditScheduleAssignment(tx *gorm.DB, c *gin.Context, entityType string, model interface{}, ids []uint, scheduleID *uint) error {
+	var rows []struct {
+		ID             uint
+		WorkScheduleID *uint
+	}
+	if err := tx.Model(model).Where("id IN ?", ids).Select("id, work_schedule_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	for _, row := range rows {
+		before := map[string]interface{}{"WorkScheduleID": row.WorkScheduleID}
+		after := map[string]interface{}{"WorkScheduleID": scheduleID}
+		if err := audit.Updated(tx, c, entityType, row.ID, before, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// @Summary Get my work schedule
+// @Description Get the work schedule that applies to the current user
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.WorkSchedule
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/schedule [get]
+// @Security BearerAuth
+func GetMySchedule(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	schedule, err := utils.GetUserWorkSchedule(DB, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load work schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"attendance-app/models"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SessionResponse is an active session of the current user
+type SessionResponse struct {
+	models.UserSession
+	// Current is true for the session of the token used for the request
+	Current bool `json:"Current"`
+} //@name SessionResponse
+
+// @Summary Get my sessions
+// @Description List the active sessions of the current user with their device, IP address and last use
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} SessionResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/sessions [get]
+func GetMySessions(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+	currentSessionId := c.GetUint("sessionId")
+
+	var sessions []models.UserSession
+	if err := DB.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userId, time.Now()).
+		Order("last_used_at DESC").Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	response := make([]SessionResponse, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, SessionResponse{
+			UserSession: session,
+			Current:     session.ID == currentSessionId,
+		})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Revoke my session
+// @Description End one of the current user's sessions, e.g. on a lost device. Its refresh token stops working and its latest access token is revoked.
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Param id path int true "Session ID"
+// @Success 200 {object} map[string]string "Session revoked successfully"
+// @Failure 400 {object} map[string]string "Session already revoked"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 404 {object} map[string]string "Session not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/sessions/{id} [delete]
+func RevokeMySession(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+	id := c.Param("id")
+
+	var session models.UserSession
+	if err := DB.Where("user_id = ?", userId).First(&session, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch session"})
+		return
+	}
+
+	if session.RevokedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Session has already been revoked"})
+		return
+	}
+
+	if err := revokeSession(DB, &session, models.SessionRevoked); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
+	"attendance-app/audit"
 	"attendance-app/models"
 )
 
@@ -95,7 +96,7 @@ func UpdateSettings(c *gin.Context) {
 	}
 
 	// Get current user ID for audit trail
-	userID, exists := c.Get("user_id")
+	userID, exists := c.Get("userId")
 	var updatedBy *uint
 	if exists {
 		if uid, ok := userID.(uint); ok {
@@ -129,6 +130,11 @@ func UpdateSettings(c *gin.Context) {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create setting: " + key})
 					return
 				}
+				if err := audit.Created(tx, c, audit.EntitySetting, setting.ID, setting); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+					return
+				}
 			} else {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch setting: " + key})
@@ -136,6 +142,7 @@ func UpdateSettings(c *gin.Context) {
 			}
 		} else {
 			// Update existing setting
+			before := audit.Snapshot(setting)
 			setting.Value = value
 			setting.UpdatedBy = updatedBy
 			if err := tx.Save(&setting).Error; err != nil {
@@ -143,6 +150,11 @@ func UpdateSettings(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update setting: " + key})
 				return
 			}
+			if err := audit.Updated(tx, c, audit.EntitySetting, setting.ID, before, setting); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
 		}
 	}
 
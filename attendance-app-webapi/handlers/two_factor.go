@@ -0,0 +1,628 @@
+package handlers
+
+import (
+	"attendance-app/audit"
+	"attendance-app/config"
+	"attendance-app/models"
+	"attendance-app/security"
+	"attendance-app/utils"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Two-factor login limits
+const (
+	loginChallengeLifetime    = 5 * time.Minute
+	maxLoginChallengeAttempts = 5
+	recoveryCodeCount         = 10
+)
+
+type loginTwoFactorPayload struct {
+	ChallengeToken string `json:"challengeToken" binding:"required" example:"3f1c9a..."`
+	// TOTP code from the authenticator app or a recovery code
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type twoFactorSetupPayload struct {
+	Password string `json:"password" binding:"required" example:"secretpassword123"`
+}
+
+type twoFactorCodePayload struct {
+	Code string `json:"code" binding:"required" example:"123456"`
+}
+
+type twoFactorDisablePayload struct {
+	Password string `json:"password" binding:"required" example:"secretpassword123"`
+	Code     string `json:"code" binding:"required" example:"123456"`
+}
+
+// TwoFactorChallengeResponse is returned on login instead of the tokens when
+// the account has two-factor authentication enabled
+type TwoFactorChallengeResponse struct {
+	TwoFactorRequired bool `json:"twoFactorRequired"`
+	// Exchanged together with a code at /login/2fa
+	ChallengeToken string    `json:"challengeToken"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+} //@name TwoFactorChallengeResponse
+
+// TwoFactorStatusResponse is the two-factor authentication state of the current user
+type TwoFactorStatusResponse struct {
+	Enabled bool `json:"enabled"`
+	// Required is true when the account must enable two-factor authentication
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recoveryCodesRemaining"`
+} //@name TwoFactorStatusResponse
+
+// TwoFactorSetupResponse holds the secret to add to an authenticator app
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret" example:"JBSWY3DPEHPK3PXP"`
+	// otpauth:// URI, usually shown as a QR code
+	URI string `json:"uri"`
+} //@name TwoFactorSetupResponse
+
+// RecoveryCodesResponse holds new recovery codes, which are only shown once
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+} //@name RecoveryCodesResponse
+
+// TwoFactorRequired reports whether users of the role must enable two-factor
+// authentication (TOTP_REQUIRED_FOR_ADMIN for admins)
+func TwoFactorRequired(role *models.Role) bool {
+	return role != nil && role.Name == models.RoleAdmin && config.Config("TOTP_REQUIRED_FOR_ADMIN") == "true"
+}
+
+// totpIssuer returns the name shown in authenticator apps (TOTP_ISSUER)
+func totpIssuer() string {
+	if issuer := config.Config("TOTP_ISSUER"); issuer != "" {
+		return issuer
+	}
+	return "Attendance App"
+}
+
+// normalizeRecoveryCode ignores case, spaces and dashes in recovery codes
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// startLoginChallenge issues the challenge token for the second login step
+func startLoginChallenge(db *gorm.DB, user models.User, deviceName string) (TwoFactorChallengeResponse, error) {
+	token, err := utils.RandomToken(32)
+	if err != nil {
+		return TwoFactorChallengeResponse{}, err
+	}
+
+	challenge := models.LoginChallenge{
+		UserID:     user.ID,
+		TokenHash:  utils.HashToken(token),
+		DeviceName: truncate(deviceName, 100),
+		ExpiresAt:  time.Now().Add(loginChallengeLifetime),
+	}
+	if err := db.Create(&challenge).Error; err != nil {
+		return TwoFactorChallengeResponse{}, err
+	}
+
+	return TwoFactorChallengeResponse{
+		TwoFactorRequired: true,
+		ChallengeToken:    token,
+		ExpiresAt:         challenge.ExpiresAt,
+	}, nil
+}
+
+// verifySecondFactor checks a TOTP code, or else a recovery code, of the user.
+// A code is accepted once: the TOTP period is recorded and recovery codes are
+// marked used.
+func verifySecondFactor(db *gorm.DB, user *models.User, code string) (bool, error) {
+	if user.TOTPSecret == "" {
+		return false, nil
+	}
+
+	if counter, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now(), user.TOTPLastCounter); ok {
+		// The condition makes concurrent requests with the same code fail
+		result := db.Model(&models.User{}).
+			Where("id = ? AND totp_last_counter < ?", user.ID, counter).
+			Update("totp_last_counter", counter)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TOTPLastCounter = counter
+		return result.RowsAffected == 1, nil
+	}
+
+	result := db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, utils.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// generateRecoveryCodes replaces the user's recovery codes with new ones
+func generateRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	if err := db.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		value, err := utils.RandomToken(5)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, value[:5]+"-"+value[5:])
+		records = append(records, models.RecoveryCode{UserID: userID, CodeHash: utils.HashToken(value)})
+	}
+	if err := db.Create(&records).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// @Summary Complete two-factor login
+// @Description Exchange the challenge token returned by /login, together with a TOTP code or a recovery code, for the access and refresh tokens. A challenge expires after 5 minutes or 5 wrong codes.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param login body loginTwoFactorPayload true "Challenge token and code"
+// @Success 200 {object} TokenResponse "Returns the access and refresh tokens"
+// @Failure 400 {object} map[string]string "Invalid request payload"
+// @Failure 401 {object} map[string]string "Invalid or expired challenge, or invalid code"
+// @Failure 403 {object} map[string]string "Account suspended or terminated"
+// @Failure 429 {object} map[string]string "Too many failed login attempts"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /login/2fa [post]
+func LoginTwoFactor(c *gin.Context) {
+	var payload loginTwoFactorPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	conn := c.MustGet("db").(*gorm.DB)
+
+	var challenge models.LoginChallenge
+	if err := conn.Where("token_hash = ?", utils.HashToken(payload.ChallengeToken)).First(&challenge).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login challenge"})
+		return
+	}
+	if challenge.UsedAt != nil || !time.Now().Before(challenge.ExpiresAt) || challenge.Attempts >= maxLoginChallengeAttempts {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login challenge"})
+		return
+	}
+
+	var user models.User
+	if err := conn.First(&user, challenge.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login challenge"})
+		return
+	}
+
+	if !checkLoginThrottle(conn, c, user.Username) {
+		return
+	}
+	if !checkUserActive(conn, c, user) {
+		return
+	}
+
+	ok, err := verifySecondFactor(conn, &user, payload.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify code"})
+		return
+	}
+	if !ok {
+		if err := conn.Model(&challenge).UpdateColumn("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify code"})
+			return
+		}
+		loginFailed(conn, c, models.SecurityTwoFactorFailed, user.Username, &user.ID, "Invalid two-factor code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid two-factor code"})
+		return
+	}
+
+	// Only the first use succeeds, also when two requests race
+	result := conn.Model(&models.LoginChallenge{}).
+		Where("id = ? AND used_at IS NULL", challenge.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not verify code"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired login challenge"})
+		return
+	}
+
+	response, err := startSession(conn, c, user, challenge.DeviceName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "could not generate token"})
+		return
+	}
+
+	if err := security.LoginSucceeded(conn, user.Username); err != nil {
+		log.Printf("Failed to clear failed logins of %s: %v", user.Username, err)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// @Summary Get my two-factor status
+// @Description Whether two-factor authentication is enabled or required for the current user, and how many recovery codes are left
+// @Tags auth
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} TwoFactorStatusResponse
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/2fa [get]
+func GetMyTwoFactor(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var user models.User
+	if err := DB.Preload("Role").First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	var remaining int64
+	if user.TOTPEnabled {
+		if err := DB.Model(&models.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", user.ID).
+			Count(&remaining).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch recovery codes"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, TwoFactorStatusResponse{
+		Enabled:                user.TOTPEnabled,
+		Required:               TwoFactorRequired(user.Role),
+		RecoveryCodesRemaining: remaining,
+	})
+}
+
+// @Summary Set up two-factor authentication
+// @Description Generate a new TOTP secret for the current user to add to an authenticator app. Two-factor authentication is only enabled once a code is confirmed at /user/2fa/enable.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body twoFactorSetupPayload true "Current password"
+// @Success 200 {object} TwoFactorSetupResponse
+// @Failure 400 {object} map[string]string "Incorrect password or already enabled"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/2fa/setup [post]
+func SetupMyTwoFactor(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var payload twoFactorSetupPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var user models.User
+	if err := DB.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if !utils.CheckPasswordHash(payload.Password, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+
+	if err := DB.Model(&user).Updates(map[string]interface{}{
+		"totp_secret":       secret,
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set up two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, TwoFactorSetupResponse{
+		Secret: secret,
+		URI:    utils.TOTPURI(totpIssuer(), user.Username, secret),
+	})
+}
+
+// @Summary Enable two-factor authentication
+// @Description Confirm the secret from /user/2fa/setup with a code from the authenticator app. Logins then require a code. Returns recovery codes, which are only shown once.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body twoFactorCodePayload true "TOTP code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string "Invalid code, not set up or already enabled"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/2fa/enable [post]
+func EnableMyTwoFactor(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var payload twoFactorCodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var user models.User
+	if err := DB.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication has not been set up"})
+		return
+	}
+
+	counter, ok := utils.ValidateTOTP(user.TOTPSecret, payload.Code, time.Now(), user.TOTPLastCounter)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	before := audit.Snapshot(user)
+	user.TOTPEnabled = true
+	user.TOTPLastCounter = counter
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"totp_enabled":      true,
+		"totp_last_counter": counter,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	codes, err := generateRecoveryCodes(tx, user.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Disable two-factor authentication
+// @Description Turn off two-factor authentication for the current user. Requires the password and a TOTP or recovery code. Not allowed when two-factor authentication is required for the account.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body twoFactorDisablePayload true "Current password and code"
+// @Success 200 {object} map[string]string "Two-factor authentication disabled"
+// @Failure 400 {object} map[string]string "Incorrect password or code, not enabled, or required"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/2fa/disable [post]
+func DisableMyTwoFactor(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var payload twoFactorDisablePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var user models.User
+	if err := DB.Preload("Role").First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+	if TwoFactorRequired(user.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is required for this account"})
+		return
+	}
+	if !utils.CheckPasswordHash(payload.Password, user.Password) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password is incorrect"})
+		return
+	}
+
+	ok, err := verifySecondFactor(DB, &user, payload.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	if err := clearTwoFactor(DB, c, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// @Summary Regenerate recovery codes
+// @Description Replace the current user's recovery codes with new ones. Requires a TOTP or recovery code. The new codes are only shown once.
+// @Tags auth
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param request body twoFactorCodePayload true "TOTP or recovery code"
+// @Success 200 {object} RecoveryCodesResponse
+// @Failure 400 {object} map[string]string "Invalid code or not enabled"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /user/2fa/recovery-codes [post]
+func RegenerateMyRecoveryCodes(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	var payload twoFactorCodePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	var user models.User
+	if err := DB.First(&user, userId).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	ok, err := verifySecondFactor(DB, &user, payload.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify code"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid two-factor code"})
+		return
+	}
+
+	codes, err := generateRecoveryCodes(DB, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate recovery codes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// @Summary Reset user's two-factor authentication
+// @Description Turn off two-factor authentication for a user who lost their authenticator app and recovery codes. The user can set it up again after logging in with their password.
+// @Tags users
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} map[string]string "Two-factor authentication reset"
+// @Failure 400 {object} map[string]string "Not enabled"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can reset two-factor authentication, and only of users whose permissions they hold"
+// @Failure 404 {object} map[string]string "User not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/users/{id}/2fa [delete]
+// @Security BearerAuth
+func ResetUserTwoFactor(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var user models.User
+	if err := DB.First(&user, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		return
+	}
+
+	// Resetting the second factor of a user with more permissions would open
+	// their account to anyone who learns the password
+	permissions, err := utils.RolePermissions(DB, user.RoleID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		return
+	}
+	allowed, err := utils.HoldsPermissions(DB, c.MustGet("userId").(uint), permissions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot reset two-factor authentication of a user with permissions you do not hold"})
+		return
+	}
+
+	if !user.TOTPEnabled && user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled"})
+		return
+	}
+
+	if err := clearTwoFactor(DB, c, &user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+}
+
+// clearTwoFactor turns off two-factor authentication and removes the secret and recovery codes
+func clearTwoFactor(db *gorm.DB, c *gin.Context, user *models.User) error {
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	before := audit.Snapshot(user)
+	user.TOTPEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastCounter = 0
+	if err := tx.Model(user).Updates(map[string]interface{}{
+		"totp_enabled":      false,
+		"totp_secret":       "",
+		"totp_last_counter": 0,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
@@ -0,0 +1,320 @@
+package UserManagement
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/utils"
+)
+
+// EmploymentStatusRequest changes the employment status of a user
+type EmploymentStatusRequest struct {
+	Status models.EmploymentStatus `json:"Status" validate:"required,oneof=active suspended terminated" example:"terminated"`
+	// First day of a suspension or termination (YYYY-MM-DD), today when omitted
+	EffectiveFrom string `json:"EffectiveFrom" example:"2026-11-01"`
+	// Last day of a suspension (YYYY-MM-DD), open ended when omitted
+	EffectiveUntil string `json:"EffectiveUntil" example:""`
+	// Supervisor who takes over the user's subordinates, pending leave
+	// approvals and the delegations the user granted. Required to terminate a
+	// user who has subordinates, and to suspend or terminate a user with
+	// pending leave approvals.
+	NewSupervisorID *uint `json:"NewSupervisorID,omitempty" example:"3"`
+}
+
+// EmploymentStatusResponse is the user after the change with the subordinates moved to the new supervisor
+type EmploymentStatusResponse struct {
+	User                   models.User `json:"User"`
+	ReassignedSubordinates []uint      `json:"ReassignedSubordinates"`
+	// ReassignedApprovalSteps are the pending leave approval steps moved to the new supervisor
+	ReassignedApprovalSteps []uint `json:"ReassignedApprovalSteps"`
+	// ReassignedDelegations are the delegations granted by the user that now delegate the new supervisor's rights
+	ReassignedDelegations []uint `json:"ReassignedDelegations"`
+} //@name EmploymentStatusResponse
+
+// @Summary Change a user's employment status
+// @Description Suspend, terminate or reactivate a user. A suspension or termination applies from EffectiveFrom (today by default) and a suspension may end after EffectiveUntil. While it applies the user cannot log in and is left out of absence marking and reminders. Offboarding moves the user's direct subordinates, pending leave approval steps and active delegations to NewSupervisorID at once. NewSupervisorID is required when terminating a user who has subordinates and when suspending or terminating a user who has pending leave approvals. Reactivating clears the dates.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param status body EmploymentStatusRequest true "New employment status"
+// @Success 200 {object} EmploymentStatusResponse
+// @Failure 400 {object} map[string]string "Invalid request, dates or new supervisor"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires users.manage, or changing your own status"
+// @Failure 404 {object} map[string]string "User or new supervisor not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/users/{id}/employment-status [put]
+// @Security BearerAuth
+func UpdateEmploymentStatus(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+	id := c.Param("id")
+
+	var req EmploymentStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	now := time.Now()
+	var from, until *time.Time
+	if req.Status == models.EmploymentActive {
+		if req.EffectiveFrom != "" || req.EffectiveUntil != "" || req.NewSupervisorID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Dates and a new supervisor only apply to a suspension or termination"})
+			return
+		}
+	} else {
+		start := utils.LocalDate(now)
+		if req.EffectiveFrom != "" {
+			firstDay, err := time.ParseInLocation(utils.CalendarDateFormat, req.EffectiveFrom, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effective from date format. Use YYYY-MM-DD"})
+				return
+			}
+			start = firstDay
+		}
+		from = &start
+
+		if req.EffectiveUntil != "" {
+			if req.Status == models.EmploymentTerminated {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A termination cannot have an end date"})
+				return
+			}
+			lastDay, err := time.ParseInLocation(utils.CalendarDateFormat, req.EffectiveUntil, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid effective until date format. Use YYYY-MM-DD"})
+				return
+			}
+			// The status lasts through the whole last day
+			end := lastDay.AddDate(0, 0, 1)
+			if !end.After(start) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Effective until date cannot be before the effective from date"})
+				return
+			}
+			if !end.After(now) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Effective until date cannot be in the past"})
+				return
+			}
+			until = &end
+		}
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var user models.User
+	if err := tx.Preload("Role").First(&user, id).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch user"})
+		}
+		return
+	}
+
+	// An admin locking themselves out cannot undo it
+	if user.ID == userId {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot change your own employment status"})
+		return
+	}
+
+	var subordinates []models.User
+	if err := tx.Preload("Role").Where("supervisor_id = ?", user.ID).Find(&subordinates).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch subordinates"})
+		return
+	}
+
+	if req.Status == models.EmploymentTerminated && len(subordinates) > 0 && req.NewSupervisorID == nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A new supervisor is required for the user's subordinates"})
+		return
+	}
+
+	// Leave requests waiting for the user would otherwise be stuck while they are away
+	var pendingSteps []models.LeaveApprovalStep
+	var delegations []models.ApprovalDelegation
+	if req.Status != models.EmploymentActive {
+		if err := tx.Preload("LeaveRequest").
+			Where("approver_id = ? AND status = ?", user.ID, models.ApprovalStepPending).
+			Find(&pendingSteps).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pending approval steps"})
+			return
+		}
+		if len(pendingSteps) > 0 && req.NewSupervisorID == nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A new supervisor is required for the user's pending leave approvals"})
+			return
+		}
+		if err := tx.Where("delegator_id = ? AND revoked_at IS NULL AND end_date >= ?",
+			user.ID, utils.LocalDate(now).Format(utils.CalendarDateFormat)).
+			Find(&delegations).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch delegations"})
+			return
+		}
+	}
+
+	// Validate the new supervisor against every subordinate it takes over
+	if req.NewSupervisorID != nil {
+		var supervisor models.User
+		if err := tx.Preload("Role").First(&supervisor, *req.NewSupervisorID).Error; err != nil {
+			tx.Rollback()
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "New supervisor not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch new supervisor"})
+			}
+			return
+		}
+
+		if supervisor.ID == user.ID {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New supervisor must be another user"})
+			return
+		}
+		if supervisor.Role.Name == models.RoleAdmin {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Admin users cannot be supervisors"})
+			return
+		}
+		if !supervisor.IsActive(now) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "New supervisor is not active"})
+			return
+		}
+		for _, subordinate := range subordinates {
+			if subordinate.Role.PositionLevel <= supervisor.Role.PositionLevel {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "New supervisor must have higher position level than " + subordinate.Name})
+				return
+			}
+		}
+		// Nobody can approve their own leave
+		for _, step := range pendingSteps {
+			if step.LeaveRequest != nil && step.LeaveRequest.UserID == supervisor.ID {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("New supervisor cannot approve their own leave request #%d", step.LeaveRequestID)})
+				return
+			}
+		}
+	}
+
+	before := audit.Snapshot(user)
+	user.EmploymentStatus = req.Status
+	user.EmploymentStatusFrom = from
+	user.EmploymentStatusUntil = until
+	if err := tx.Model(&user).Updates(map[string]interface{}{
+		"employment_status":       user.EmploymentStatus,
+		"employment_status_from":  user.EmploymentStatusFrom,
+		"employment_status_until": user.EmploymentStatusUntil,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update employment status"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	reassigned := []uint{}
+	if req.NewSupervisorID != nil {
+		for _, subordinate := range subordinates {
+			if err := tx.Model(&subordinate).Update("supervisor_id", *req.NewSupervisorID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update subordinates"})
+				return
+			}
+			if err := audit.Updated(tx, c, audit.EntityUser, subordinate.ID,
+				map[string]interface{}{"SupervisorID": user.ID},
+				map[string]interface{}{"SupervisorID": *req.NewSupervisorID}); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
+			reassigned = append(reassigned, subordinate.ID)
+		}
+	}
+
+	reassignedSteps := []uint{}
+	for _, step := range pendingSteps {
+		if err := tx.Model(&step).Update("approver_id", *req.NewSupervisorID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update approval steps"})
+			return
+		}
+		if err := audit.Updated(tx, c, audit.EntityLeaveApprovalStep, step.ID,
+			map[string]interface{}{"ApproverID": user.ID},
+			map[string]interface{}{"ApproverID": *req.NewSupervisorID}); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+		reassignedSteps = append(reassignedSteps, step.ID)
+	}
+
+	// The delegates keep acting for the steps they were covering; a delegation
+	// to the new supervisor themselves is no longer needed and is revoked
+	reassignedDelegations := []uint{}
+	if req.NewSupervisorID != nil {
+		for _, delegation := range delegations {
+			before := audit.Snapshot(delegation)
+			changes := map[string]interface{}{"delegator_id": *req.NewSupervisorID}
+			delegation.DelegatorID = *req.NewSupervisorID
+			if delegation.DelegateID == *req.NewSupervisorID {
+				changes = map[string]interface{}{"revoked_at": now}
+				delegation.DelegatorID = user.ID
+				delegation.RevokedAt = &now
+			}
+			if err := tx.Model(&delegation).Updates(changes).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update delegations"})
+				return
+			}
+			if err := audit.Updated(tx, c, audit.EntityDelegation, delegation.ID, before, delegation); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
+			if delegation.RevokedAt == nil {
+				reassignedDelegations = append(reassignedDelegations, delegation.ID)
+			}
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	// Clear sensitive data before sending response
+	user.Password = ""
+	c.JSON(http.StatusOK, EmploymentStatusResponse{
+		User:                    user,
+		ReassignedSubordinates:  reassigned,
+		ReassignedApprovalSteps: reassignedSteps,
+		ReassignedDelegations:   reassignedDelegations,
+	})
+}
@@ -0,0 +1,583 @@
+package UserManagement
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"attendance-app/audit"
+	"attendance-app/models"
+	"attendance-app/storage"
+	"attendance-app/utils"
+)
+
+// maxImportRows limits the size of an import. Every password is hashed with
+// bcrypt, so large files would keep the request open for minutes.
+const maxImportRows = 200
+
+// Import modes
+const (
+	importModeDryRun = "dry-run"
+	importModeCommit = "commit"
+)
+
+// importColumns maps the accepted header names, compared without case,
+// spaces, dashes and underscores, to the column they fill
+var importColumns = map[string]string{
+	"username":           "username",
+	"email":              "email",
+	"name":               "name",
+	"role":               "role",
+	"position":           "position",
+	"positionlevel":      "position_level",
+	"supervisor":         "supervisor_username",
+	"supervisorusername": "supervisor_username",
+	"password":           "password",
+	"department":         "department_code",
+	"departmentcode":     "department_code",
+}
+
+// requiredImportColumns must be present in the header row
+var requiredImportColumns = []string{"username", "email", "name", "role", "position"}
+
+// UserImportRowError lists the problems found in one row of an import file
+type UserImportRowError struct {
+	Row      int      `json:"Row" example:"3"`
+	Username string   `json:"Username" example:"john_doe"`
+	Errors   []string `json:"Errors"`
+} //@name UserImportRowError
+
+// UserImportResponse reports the outcome of a user import
+type UserImportResponse struct {
+	DryRun bool `json:"DryRun" example:"true"`
+	// Total is the number of data rows in the file
+	Total   int `json:"Total" example:"25"`
+	Valid   int `json:"Valid" example:"24"`
+	Created int `json:"Created" example:"0"`
+	// Errors lists the rows that cannot be imported
+	Errors []UserImportRowError `json:"Errors"`
+	// Users are the created users, only in commit mode
+	Users []models.User `json:"Users,omitempty"`
+} //@name UserImportResponse
+
+// importRow is a data row of an import file with what it resolved to
+type importRow struct {
+	Row    int
+	Values map[string]string
+	Errors []string
+
+	Role     *models.Role
+	Level    uint
+	HasLevel bool
+
+	// Supervisor is an existing user, SupervisorRow a row of the same file
+	Supervisor    *models.User
+	SupervisorRow *importRow
+	DepartmentID  *uint
+
+	User *models.User
+}
+
+func (r *importRow) fail(format string, args ...interface{}) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// normalizeImportHeader lowercases a header and drops spaces, dashes and underscores
+func normalizeImportHeader(header string) string {
+	return strings.NewReplacer(" ", "", "-", "", "_", "").Replace(strings.ToLower(header))
+}
+
+// parseImportRows maps the data rows of the table to their columns. It
+// returns an error when the header row lacks a required column.
+func parseImportRows(table [][]string) ([]*importRow, error) {
+	if len(table) == 0 {
+		return nil, fmt.Errorf("the file is empty")
+	}
+
+	columns := make(map[int]string)
+	present := make(map[string]bool)
+	for i, header := range table[0] {
+		if column, ok := importColumns[normalizeImportHeader(header)]; ok {
+			columns[i] = column
+			present[column] = true
+		}
+	}
+	var missing []string
+	for _, column := range requiredImportColumns {
+		if !present[column] {
+			missing = append(missing, column)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing columns: %s", strings.Join(missing, ", "))
+	}
+
+	var rows []*importRow
+	for i, cells := range table[1:] {
+		row := &importRow{Row: i + 2, Values: make(map[string]string)}
+		empty := true
+		for j, cell := range cells {
+			if column, ok := columns[j]; ok && cell != "" {
+				row.Values[column] = cell
+				empty = false
+			}
+		}
+		if !empty {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// validateImportRows checks every row against the file and the database and
+// resolves roles, supervisors and departments for the importing user. Problems
+// are recorded on the rows; the error is only set when the database could not
+// be queried.
+func validateImportRows(db *gorm.DB, userID uint, rows []*importRow) error {
+	byUsername := make(map[string]*importRow)
+	byEmail := make(map[string]*importRow)
+	var usernames, emails, positions, supervisorNames, departmentCodes []string
+
+	for _, row := range rows {
+		v := row.Values
+		if err := utils.Validate.Var(v["username"], "required,min=3,max=32"); err != nil {
+			row.fail("Username is required and must be 3 to 32 characters")
+		}
+		if err := utils.Validate.Var(v["email"], "required,email"); err != nil {
+			row.fail("Email is missing or invalid")
+		}
+		if v["name"] == "" {
+			row.fail("Name is required")
+		}
+		v["role"] = strings.ToLower(v["role"])
+		if models.RoleName(v["role"]) != models.RoleAdmin && models.RoleName(v["role"]) != models.RoleUser {
+			row.fail("Role must be 'admin' or 'user'")
+		}
+		if v["position"] == "" {
+			row.fail("Position is required")
+		}
+		if level, ok := v["position_level"]; ok {
+			parsed, err := strconv.ParseUint(level, 10, 32)
+			if err != nil {
+				row.fail("Position level must be a whole number")
+			} else {
+				row.Level = uint(parsed)
+				row.HasLevel = true
+			}
+		}
+		if password, ok := v["password"]; ok {
+			if err := utils.Validate.Var(password, "min=8,max=72"); err != nil {
+				row.fail("Password must be 8 to 72 characters")
+			}
+		}
+
+		// Usernames and emails must also be unique within the file
+		if username := v["username"]; username != "" {
+			if other, ok := byUsername[username]; ok {
+				row.fail("Username is also used in row %d", other.Row)
+			} else {
+				byUsername[username] = row
+				usernames = append(usernames, username)
+			}
+		}
+		if email := v["email"]; email != "" {
+			if other, ok := byEmail[email]; ok {
+				row.fail("Email is also used in row %d", other.Row)
+			} else {
+				byEmail[email] = row
+				emails = append(emails, email)
+			}
+		}
+
+		if v["position"] != "" {
+			positions = append(positions, v["position"])
+		}
+		if supervisor := v["supervisor_username"]; supervisor != "" {
+			supervisorNames = append(supervisorNames, supervisor)
+		}
+		if code := v["department_code"]; code != "" {
+			departmentCodes = append(departmentCodes, code)
+		}
+	}
+
+	// Existing users with the same username or email
+	if len(usernames) > 0 || len(emails) > 0 {
+		var existing []models.User
+		if err := db.Select("id", "username", "email").
+			Where("username IN ? OR email IN ?", append(usernames, ""), append(emails, "")).
+			Find(&existing).Error; err != nil {
+			return err
+		}
+		for _, user := range existing {
+			if row, ok := byUsername[user.Username]; ok {
+				row.fail("Username already exists")
+			}
+			if row, ok := byEmail[user.Email]; ok {
+				row.fail("Email already exists")
+			}
+		}
+	}
+
+	// Positions are unique, so an existing position decides the role and level
+	rolesByPosition := make(map[string]*models.Role)
+	if len(positions) > 0 {
+		var roles []models.Role
+		if err := db.Where("position IN ?", positions).Find(&roles).Error; err != nil {
+			return err
+		}
+		for i := range roles {
+			rolesByPosition[roles[i].Position] = &roles[i]
+		}
+	}
+	newRoleRows := make(map[string]*importRow)
+	for _, row := range rows {
+		position := row.Values["position"]
+		if position == "" {
+			continue
+		}
+		roleName := models.RoleName(row.Values["role"])
+
+		if role, ok := rolesByPosition[position]; ok {
+			if role.Name != roleName {
+				row.fail("Position %s belongs to the %s role", position, role.Name)
+			}
+			if row.HasLevel && row.Level != role.PositionLevel {
+				row.fail("Position %s has position level %d", position, role.PositionLevel)
+			}
+			row.Role = role
+			row.Level = role.PositionLevel
+			row.HasLevel = true
+			continue
+		}
+
+		// A new position is created once, the first row defines it
+		if first, ok := newRoleRows[position]; ok {
+			if first.Role.Name != roleName || (row.HasLevel && row.Level != first.Role.PositionLevel) {
+				row.fail("Position %s is defined differently in row %d", position, first.Row)
+			}
+			row.Role = first.Role
+			row.Level = first.Role.PositionLevel
+			row.HasLevel = true
+			continue
+		}
+		if !row.HasLevel {
+			row.fail("Position level is required for the new position %s", position)
+			continue
+		}
+		row.Role = &models.Role{Name: roleName, Position: position, PositionLevel: row.Level}
+		newRoleRows[position] = row
+	}
+
+	// Users cannot hand out permissions they do not hold themselves
+	assignable := make(map[*models.Role]bool)
+	for _, row := range rows {
+		if row.Role == nil {
+			continue
+		}
+		allowed, checked := assignable[row.Role]
+		if !checked {
+			var err error
+			permissions := make(map[string]bool)
+			if row.Role.ID != 0 {
+				if permissions, err = utils.RolePermissions(db, row.Role.ID); err != nil {
+					return err
+				}
+			} else if row.Role.Name == models.RoleAdmin {
+				// New admin roles are granted every permission
+				for _, permission := range models.Permissions {
+					permissions[permission.Name] = true
+				}
+			}
+			if allowed, err = utils.HoldsPermissions(db, userID, permissions); err != nil {
+				return err
+			}
+			assignable[row.Role] = allowed
+		}
+		if !allowed {
+			row.fail("Position %s has permissions you do not hold", row.Role.Position)
+		}
+	}
+	if len(departmentCodes) > 0 {
+		var departments []models.Department
+		if err := db.Select("id", "code").Where("code IN ?", departmentCodes).Find(&departments).Error; err != nil {
+			return err
+		}
+		departmentsByCode := make(map[string]uint)
+		for _, department := range departments {
+			departmentsByCode[department.Code] = department.ID
+		}
+		for _, row := range rows {
+			if code := row.Values["department_code"]; code != "" {
+				if id, ok := departmentsByCode[code]; ok {
+					row.DepartmentID = &id
+				} else {
+					row.fail("Department %s not found", code)
+				}
+			}
+		}
+	}
+
+	// Supervisors are existing users or rows of the same file
+	existingSupervisors := make(map[string]*models.User)
+	if len(supervisorNames) > 0 {
+		var supervisors []models.User
+		if err := db.Preload("Role").Where("username IN ?", supervisorNames).Find(&supervisors).Error; err != nil {
+			return err
+		}
+		for i := range supervisors {
+			existingSupervisors[supervisors[i].Username] = &supervisors[i]
+		}
+	}
+
+	// Supervisors have a lower position level than their subordinates, so in
+	// level order a supervisor in the file is checked before its subordinates
+	ordered := orderImportRows(rows)
+	for _, row := range ordered {
+		name := row.Values["supervisor_username"]
+		if name == "" {
+			continue
+		}
+		if name == row.Values["username"] {
+			row.fail("A user cannot be their own supervisor")
+			continue
+		}
+
+		var supervisorRole *models.Role
+		if supervisorRow, ok := byUsername[name]; ok {
+			if supervisorRow.Role == nil {
+				row.fail("Supervisor in row %d is invalid", supervisorRow.Row)
+				continue
+			}
+			row.SupervisorRow = supervisorRow
+			supervisorRole = supervisorRow.Role
+		} else if supervisor, ok := existingSupervisors[name]; ok {
+			row.Supervisor = supervisor
+			supervisorRole = supervisor.Role
+		} else {
+			row.fail("Supervisor %s not found", name)
+			continue
+		}
+
+		if row.Role == nil || supervisorRole == nil {
+			continue
+		}
+		if row.Role.Name == models.RoleAdmin {
+			row.fail("Admin users cannot have a supervisor")
+		}
+		if supervisorRole.Name == models.RoleAdmin {
+			row.fail("Admin users cannot be supervisors")
+		}
+		if row.Level <= supervisorRole.PositionLevel {
+			row.fail("Supervisor must have higher position level")
+		}
+		if row.Supervisor != nil && !row.Supervisor.IsActive(time.Now()) {
+			row.fail("Supervisor is not active")
+		}
+		if row.SupervisorRow != nil && len(row.SupervisorRow.Errors) > 0 {
+			row.fail("Supervisor in row %d is invalid", row.SupervisorRow.Row)
+		}
+	}
+	return nil
+}
+
+// orderImportRows sorts the rows by position level, keeping the file order within a level
+func orderImportRows(rows []*importRow) []*importRow {
+	ordered := make([]*importRow, len(rows))
+	copy(ordered, rows)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].Level < ordered[j].Level
+	})
+	return ordered
+}
+
+// @Summary Import users
+// @Description Create users in bulk from a CSV or XLSX file (first sheet). The header row names the columns: username, email, name, role (admin or user) and position are required; position level, supervisor username, department code and password are optional. Positions that do not exist yet are created and need a position level. Supervisors may be existing users or users in the same file. Roles with permissions the importing user does not hold, such as admin roles, cannot be assigned. Users without a password get a random one and set their own through the forgot password flow. In dry-run mode (the default) nothing is saved and every row is validated; in commit mode all users are created in one transaction, or none when any row is invalid.
+// @Tags users
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "CSV or XLSX file (max 5MB, 200 rows)"
+// @Param mode formData string false "dry-run or commit" default(dry-run)
+// @Success 200 {object} UserImportResponse "Dry-run result"
+// @Success 201 {object} UserImportResponse "Users created"
+// @Failure 400 {object} UserImportResponse "Invalid file, or invalid rows in commit mode"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires users.manage"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/users/import [post]
+// @Security BearerAuth
+func ImportUsers(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	mode := c.DefaultPostForm("mode", importModeDryRun)
+	if mode != importModeDryRun && mode != importModeCommit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be dry-run or commit"})
+		return
+	}
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Import file is required"})
+		return
+	}
+
+	fileStorage := storage.NewLocalStorage(storage.Current.BasePath)
+	if err := fileStorage.ValidateFileType(file.Filename, storage.Current.AllowedTypes["import"]); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := fileStorage.ValidateFileSize(file.Size, storage.Current.MaxFileSize); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read import file"})
+		return
+	}
+	defer src.Close()
+
+	table, err := utils.ReadTable(file.Filename, src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: " + err.Error()})
+		return
+	}
+	rows, err := parseImportRows(table)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import file: " + err.Error()})
+		return
+	}
+	if len(rows) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The import file has no users"})
+		return
+	}
+	if len(rows) > maxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("An import may contain at most %d users", maxImportRows)})
+		return
+	}
+
+	if err := validateImportRows(DB, userId, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate users"})
+		return
+	}
+
+	response := UserImportResponse{
+		DryRun: mode == importModeDryRun,
+		Total:  len(rows),
+		Errors: []UserImportRowError{},
+	}
+	for _, row := range rows {
+		if len(row.Errors) > 0 {
+			response.Errors = append(response.Errors, UserImportRowError{
+				Row:      row.Row,
+				Username: row.Values["username"],
+				Errors:   row.Errors,
+			})
+		}
+	}
+	response.Valid = response.Total - len(response.Errors)
+
+	if response.DryRun {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	if len(response.Errors) > 0 {
+		c.JSON(http.StatusBadRequest, response)
+		return
+	}
+
+	// Hash before the transaction starts, bcrypt is slow
+	ordered := orderImportRows(rows)
+	for _, row := range ordered {
+		password := row.Values["password"]
+		if password == "" {
+			if password, err = utils.RandomToken(24); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate password"})
+				return
+			}
+		}
+		hashedPassword, err := utils.HashPassword(password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+			return
+		}
+		row.User = &models.User{
+			Username:         row.Values["username"],
+			Password:         hashedPassword,
+			Email:            row.Values["email"],
+			Name:             row.Values["name"],
+			EmploymentStatus: models.EmploymentActive,
+			DepartmentID:     row.DepartmentID,
+		}
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, row := range ordered {
+		if row.Role.ID == 0 {
+			if err := tx.Create(row.Role).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
+				return
+			}
+			if err := grantAdminPermissions(tx, row.Role); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role permissions"})
+				return
+			}
+			if err := audit.Created(tx, c, audit.EntityRole, row.Role.ID, row.Role); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
+		}
+
+		// Supervisors in the file were created earlier, they have a lower level
+		user := row.User
+		user.RoleID = row.Role.ID
+		if row.Supervisor != nil {
+			user.SupervisorID = &row.Supervisor.ID
+		} else if row.SupervisorRow != nil {
+			user.SupervisorID = &row.SupervisorRow.User.ID
+		}
+
+		if err := tx.Create(user).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to create user in row %d", row.Row)})
+			return
+		}
+		if err := audit.Created(tx, c, audit.EntityUser, user.ID, user); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
+	// Clear sensitive data before sending response, in file order
+	for _, row := range rows {
+		user := *row.User
+		user.Password = ""
+		user.Role = row.Role
+		response.Users = append(response.Users, user)
+	}
+	response.Created = len(response.Users)
+	c.JSON(http.StatusCreated, response)
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/xuri/excelize/v2"
 	"gorm.io/gorm"
 
+	"attendance-app/audit"
 	"attendance-app/models"
 	"attendance-app/utils"
 )
@@ -21,6 +22,7 @@ type CreateUserRequest struct {
 	Email        string `json:"Email" validate:"required,email" example:"john@example.com"`
 	Name         string `json:"Name" validate:"required" example:"John Doe"`
 	SupervisorID *uint  `json:"SupervisorID,omitempty" example:"1"`
+	DepartmentID *uint  `json:"DepartmentID,omitempty" example:"1"`
 	Role         struct {
 		Name          models.RoleName `json:"Name" validate:"required" example:"user"`
 		Position      string          `json:"Position" validate:"required" example:"Manager"`
@@ -37,7 +39,7 @@ type CreateUserRequest struct {
 // @Success 201 {object} models.UserSwagger
 // @Failure 400 {object} map[string]string "Validation error or duplicate user"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden - Only admins can create users"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can create users, or the role holds permissions the current user does not"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /admin/users [post]
 // @Security BearerAuth
@@ -109,6 +111,16 @@ func CreateUser(c *gin.Context) {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
 				return
 			}
+			if err := grantAdminPermissions(tx, &role); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role permissions"})
+				return
+			}
+			if err := audit.Created(tx, c, audit.EntityRole, role.ID, role); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+				return
+			}
 		} else {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
@@ -116,14 +128,38 @@ func CreateUser(c *gin.Context) {
 		}
 	}
 
+	// Users cannot hand out permissions they do not hold themselves
+	allowed, err := canAssignRole(tx, c, role.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		return
+	}
+	if !allowed {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot assign a role with permissions you do not hold"})
+		return
+	}
+
 	// 6. Create user object
 	user := models.User{
-		Username:     req.Username,
-		Password:     hashedPassword,
-		Email:        req.Email,
-		Name:         req.Name,
-		Role:         &role,
-		SupervisorID: req.SupervisorID,
+		Username:         req.Username,
+		Password:         hashedPassword,
+		Email:            req.Email,
+		Name:             req.Name,
+		Role:             &role,
+		EmploymentStatus: models.EmploymentActive,
+		SupervisorID:     req.SupervisorID,
+		DepartmentID:     req.DepartmentID,
+	}
+
+	// Validate department if present
+	if user.DepartmentID != nil {
+		if err := tx.First(&models.Department{}, *user.DepartmentID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Department not found"})
+			return
+		}
 	}
 
 	// 7. Validate supervisor if present
@@ -155,6 +191,13 @@ func CreateUser(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Admin users cannot be supervisors"})
 			return
 		}
+
+		// Suspended and terminated users cannot take on subordinates
+		if !supervisor.IsActive(time.Now()) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Supervisor is not active"})
+			return
+		}
 	}
 
 	// 8. Create user
@@ -163,6 +206,11 @@ func CreateUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user"})
 		return
 	}
+	if err := audit.Created(tx, c, audit.EntityUser, user.ID, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
 	// 9. Commit transaction
 	if err := tx.Commit().Error; err != nil {
@@ -196,6 +244,7 @@ func GetUser(c *gin.Context) {
 
 	// Load user with all related data
 	if err := db.Preload("Role").
+		Preload("Department").
 		Preload("Supervisor").
 		Preload("Supervisor.Role").
 		First(&user, id).Error; err != nil {
@@ -224,6 +273,8 @@ type UpdateUserRequest struct {
 	Email        string  `json:"Email,omitempty" validate:"omitempty,email" example:"john.updated@example.com"`
 	Name         string  `json:"Name,omitempty" validate:"omitempty" example:"John Doe Updated"`
 	SupervisorID *uint   `json:"SupervisorID,omitempty" example:"2"`
+	// DepartmentID moves the user to another department, 0 removes them from their department
+	DepartmentID *uint `json:"DepartmentID,omitempty" example:"1"`
 	Role         struct {
 		Name          models.RoleName `json:"Name,omitempty" validate:"omitempty" example:"user"`
 		Position      string          `json:"Position,omitempty" validate:"omitempty" example:"Senior Manager"`
@@ -241,7 +292,7 @@ type UpdateUserRequest struct {
 // @Success 200 {object} models.UserSwagger
 // @Failure 400 {object} map[string]string "Validation error"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden - Only admins can update users"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can update users, or the user or the new role holds permissions the current user does not"
 // @Failure 404 {object} map[string]string "User not found"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /admin/users/{id} [put]
@@ -279,6 +330,20 @@ func UpdateUser(c *gin.Context) {
 		return
 	}
 
+	// Changing the credentials of a user with more permissions would hand them to the current user
+	allowed, err := canAssignRole(tx, c, user.RoleID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		return
+	}
+	if !allowed {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot update a user with permissions you do not hold"})
+		return
+	}
+	before := audit.Snapshot(user)
+
 	// Check unique constraints if updating username or email
 	if req.Username != "" && req.Username != user.Username {
 		var exists bool
@@ -362,12 +427,35 @@ func UpdateUser(c *gin.Context) {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
 					return
 				}
+				if err := grantAdminPermissions(tx, &role); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role permissions"})
+					return
+				}
+				if err := audit.Created(tx, c, audit.EntityRole, role.ID, role); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+					return
+				}
 			} else {
 				tx.Rollback()
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
 				return
 			}
 		}
+		if role.ID != user.RoleID {
+			allowed, err := canAssignRole(tx, c, role.ID)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+				return
+			}
+			if !allowed {
+				tx.Rollback()
+				c.JSON(http.StatusForbidden, gin.H{"error": "Cannot assign a role with permissions you do not hold"})
+				return
+			}
+		}
 		user.Role = &role
 	}
 
@@ -394,6 +482,13 @@ func UpdateUser(c *gin.Context) {
 			return
 		}
 
+		// Suspended and terminated users cannot take on subordinates
+		if !supervisor.IsActive(time.Now()) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Supervisor is not active"})
+			return
+		}
+
 		// Supervisor must have higher position level
 		if user.Role.PositionLevel <= supervisor.Role.PositionLevel {
 			tx.Rollback()
@@ -404,6 +499,21 @@ func UpdateUser(c *gin.Context) {
 		user.SupervisorID = req.SupervisorID
 	}
 
+	// Update department if provided, 0 removes the user from their department
+	if req.DepartmentID != nil {
+		if *req.DepartmentID == 0 {
+			user.DepartmentID = nil
+		} else {
+			if err := tx.First(&models.Department{}, *req.DepartmentID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Department not found"})
+				return
+			}
+			user.DepartmentID = req.DepartmentID
+		}
+		user.Department = nil
+	}
+
 	// Update user details if provided
 	if req.Name != "" {
 		user.Name = req.Name
@@ -415,6 +525,11 @@ func UpdateUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user"})
 		return
 	}
+	if err := audit.Updated(tx, c, audit.EntityUser, user.ID, before, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
@@ -422,7 +537,7 @@ func UpdateUser(c *gin.Context) {
 	}
 
 	// Clear sensitive data and reload user with fresh data
-	if err := DB.Preload("Role").Preload("Supervisor").
+	if err := DB.Preload("Role").Preload("Department").Preload("Supervisor").
 		Preload("Supervisor.Role").First(&user, user.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reload user data"})
 		return
@@ -487,12 +602,25 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	// Departments headed by the user are left without a head
+	if err := tx.Model(&models.Department{}).Where("head_id = ?", user.ID).
+		Update("head_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update departments"})
+		return
+	}
+
 	// Delete the user
 	if err := tx.Delete(&user).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user"})
 		return
 	}
+	if err := audit.Deleted(tx, c, audit.EntityUser, user.ID, user); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
@@ -502,6 +630,31 @@ func DeleteUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 }
 
+// departmentScope returns the department given by the departmentId query
+// parameter, together with the departments below it when subtree=true. It is
+// nil when no department was asked for, and responds with the error and
+// returns false when the department is invalid.
+func departmentScope(c *gin.Context, db *gorm.DB) ([]uint, bool) {
+	departmentID, err := utils.DepartmentQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid department ID"})
+		return nil, false
+	}
+	if departmentID == nil {
+		return nil, true
+	}
+
+	if c.Query("subtree") != "true" {
+		return []uint{*departmentID}, true
+	}
+	departmentIDs, err := utils.GetDepartmentSubtreeIDs(db, *departmentID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sub-departments"})
+		return nil, false
+	}
+	return departmentIDs, true
+}
+
 // @Summary Get all users with optional role filtering
 // @Description Get a paginated list of users filtered by role (admin, user, or all)
 // @Tags users
@@ -513,7 +666,11 @@ func DeleteUser(c *gin.Context) {
 // @Param sortBy query string false "Sort by field (id, name, email, username, created_at, role, position_level)" example(name)
 // @Param sortOrder query string false "Sort order (asc, desc)" example(asc)
 // @Param role query string false "Filter by role: admin, user, or all (default: all)" example(all)
+// @Param status query string false "Filter by employment status today: active, inactive, or all (default: all)" example(active)
+// @Param departmentId query int false "Filter by department"
+// @Param subtree query bool false "Include the users of sub-departments of departmentId"
 // @Success 200 {object} utils.PaginatedResponse{data=[]GetAllNonAdminUsersResponse}
+// @Failure 400 {object} map[string]string "Invalid department ID or status"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden - Only admins can access"
 // @Failure 500 {object} map[string]string "Server error"
@@ -533,11 +690,28 @@ func GetAllUsers(c *gin.Context) {
 	// Get role filter param (default: all)
 	roleFilter := c.DefaultQuery("role", "all")
 
+	// Get employment status filter param (default: all)
+	statusFilter := c.DefaultQuery("status", "all")
+	if statusFilter != "active" && statusFilter != "inactive" && statusFilter != "all" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status. Must be 'active', 'inactive' or 'all'"})
+		return
+	}
+
+	departmentIds, ok := departmentScope(c, DB)
+	if !ok {
+		return
+	}
+
 	// Build base query
 	query := DB.Model(&models.User{}).
 		Joins("Role").
+		Preload("Department").
 		Preload("Supervisor")
 
+	if departmentIds != nil {
+		query = query.Where("users.department_id IN ?", departmentIds)
+	}
+
 	// Apply role filter
 	if roleFilter == "admin" {
 		query = query.Where("Role.name = ?", "admin")
@@ -546,6 +720,14 @@ func GetAllUsers(c *gin.Context) {
 	}
 	// If roleFilter == "all", no additional WHERE clause needed
 
+	// Apply employment status filter
+	now := time.Now()
+	if statusFilter == "active" {
+		query = utils.WhereUserActive(query, now)
+	} else if statusFilter == "inactive" {
+		query = utils.WhereUserInactive(query, now)
+	}
+
 	// Apply search if provided (search by email, role name, or position)
 	if params.Search != "" {
 		searchPattern := "%" + params.Search + "%"
@@ -589,13 +771,19 @@ func GetAllUsers(c *gin.Context) {
 	var userResponses []GetAllNonAdminUsersResponse
 	for _, user := range users {
 		response := GetAllNonAdminUsersResponse{
-			ID:            user.ID,
-			Username:      user.Username,
-			Name:          user.Name,
-			Email:         user.Email,
-			Role:          user.Role.Name,
-			Position:      user.Role.Position,
-			PositionLevel: user.Role.PositionLevel,
+			ID:               user.ID,
+			Username:         user.Username,
+			Name:             user.Name,
+			Email:            user.Email,
+			Role:             user.Role.Name,
+			Position:         user.Role.Position,
+			PositionLevel:    user.Role.PositionLevel,
+			DepartmentID:     user.DepartmentID,
+			EmploymentStatus: user.EmploymentStatus,
+			Active:           user.IsActive(now),
+		}
+		if user.Department != nil {
+			response.DepartmentName = user.Department.Name
 		}
 
 		// Safely add supervisor info if it exists
@@ -617,14 +805,19 @@ func GetAllUsers(c *gin.Context) {
 }
 
 type GetAllNonAdminUsersResponse struct {
-	ID            uint            `json:"ID"`
-	Username      string          `json:"Username"`
-	Name          string          `json:"Name"`
-	Email         string          `json:"Email"`
-	Role          models.RoleName `json:"Role"`
-	Position      string          `json:"Position"`
-	PositionLevel uint            `json:"PositionLevel"`
-	Supervisor    *struct {       // Nested struct for supervisor info
+	ID             uint            `json:"ID"`
+	Username       string          `json:"Username"`
+	Name           string          `json:"Name"`
+	Email          string          `json:"Email"`
+	Role           models.RoleName `json:"Role"`
+	Position       string          `json:"Position"`
+	PositionLevel  uint            `json:"PositionLevel"`
+	DepartmentID   *uint           `json:"DepartmentID"`
+	DepartmentName string          `json:"DepartmentName"`
+	// Employment status, and whether it leaves the user active today
+	EmploymentStatus models.EmploymentStatus `json:"EmploymentStatus"`
+	Active           bool                    `json:"Active"`
+	Supervisor       *struct {               // Nested struct for supervisor info
 		SupervisorID   uint   `json:"SupervisorID"`
 		SupervisorName string `json:"SupervisorName"`
 	} `json:"Supervisor"`
@@ -648,7 +841,7 @@ func GetRoles(c *gin.Context) {
 	params := utils.GetPaginationParams(c)
 
 	// Build base query
-	query := DB.Model(&models.Role{})
+	query := DB.Model(&models.Role{}).Preload("Permissions")
 
 	// Apply search if provided
 	if params.Search != "" {
@@ -792,12 +985,40 @@ func CreateRole(c *gin.Context) {
 		return
 	}
 
+	// Admin roles are granted every permission
+	if role.Name == models.RoleAdmin {
+		allowed, err := holdsAllPermissions(tx, c)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			return
+		}
+		if !allowed {
+			tx.Rollback()
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only users holding every permission can create admin roles"})
+			return
+		}
+	}
+
+	// Permissions are granted through /admin/users/roles/{id}/permissions
+	role.Permissions = nil
+
 	// Create the role
 	if err := tx.Create(&role).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role"})
 		return
 	}
+	if err := grantAdminPermissions(tx, &role); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role permissions"})
+		return
+	}
+	if err := audit.Created(tx, c, audit.EntityRole, role.ID, role); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
@@ -817,7 +1038,7 @@ func CreateRole(c *gin.Context) {
 // @Success 200 {object} models.RoleSwagger
 // @Failure 400 {object} map[string]string "Validation error"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden - Only admins can update roles"
+// @Failure 403 {object} map[string]string "Forbidden - Only admins can update roles, and only users holding every permission can rename a role to or from admin"
 // @Failure 404 {object} map[string]string "Role not found"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /admin/users/roles/{id} [put]
@@ -886,6 +1107,21 @@ func UpdateRole(c *gin.Context) {
 		return
 	}
 
+	// Renaming to admin grants every permission and renaming from admin keeps them
+	if (role.Name == models.RoleAdmin) != (existingRole.Name == models.RoleAdmin) {
+		allowed, err := holdsAllPermissions(tx, c)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+			return
+		}
+		if !allowed {
+			tx.Rollback()
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only users holding every permission can rename a role to or from admin"})
+			return
+		}
+	}
+
 	// Check if role is in use and position level is being lowered
 	if role.PositionLevel < existingRole.PositionLevel {
 		var usersCount int64
@@ -902,6 +1138,8 @@ func UpdateRole(c *gin.Context) {
 		// }
 	}
 
+	before := audit.Snapshot(existingRole)
+
 	// Update the role
 	if err := tx.Model(&existingRole).Updates(map[string]interface{}{
 		"name":           role.Name,
@@ -912,6 +1150,16 @@ func UpdateRole(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
 		return
 	}
+	if err := grantAdminPermissions(tx, &existingRole); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to grant role permissions"})
+		return
+	}
+	if err := audit.Updated(tx, c, audit.EntityRole, existingRole.ID, before, existingRole); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
@@ -980,6 +1228,11 @@ func DeleteRole(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
 		return
 	}
+	if err := audit.Deleted(tx, c, audit.EntityRole, role.ID, role); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
 
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
@@ -989,12 +1242,185 @@ func DeleteRole(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
 }
 
+// RolePermissionsRequest represents the request payload for setting a role's permissions
+type RolePermissionsRequest struct {
+	Permissions []string `json:"Permissions" validate:"required" example:"leave.read,leave.manage,leave.approve.any,reports.export"`
+}
+
+// grantAdminPermissions gives admin roles every permission
+func grantAdminPermissions(db *gorm.DB, role *models.Role) error {
+	if role.Name != models.RoleAdmin {
+		return nil
+	}
+	return utils.GrantAllPermissions(db, role)
+}
+
+// canAssignRole reports whether the current user may give users the role:
+// only roles whose permissions they hold themselves, so admin roles are left
+// to admins
+func canAssignRole(db *gorm.DB, c *gin.Context, roleID uint) (bool, error) {
+	userId := c.MustGet("userId").(uint)
+	permissions, err := utils.RolePermissions(db, roleID)
+	if err != nil {
+		return false, err
+	}
+	return utils.HoldsPermissions(db, userId, permissions)
+}
+
+// holdsAllPermissions reports whether the current user holds every permission,
+// as needed to create admin roles, which are granted all of them
+func holdsAllPermissions(db *gorm.DB, c *gin.Context) (bool, error) {
+	permissions := make(map[string]bool, len(models.Permissions))
+	for _, permission := range models.Permissions {
+		permissions[permission.Name] = true
+	}
+	return utils.HoldsPermissions(db, c.MustGet("userId").(uint), permissions)
+}
+
+// permissionNames returns the names of the permissions for the audit log
+func permissionNames(permissions []models.Permission) map[string]interface{} {
+	names := make([]string, 0, len(permissions))
+	for _, permission := range permissions {
+		names = append(names, permission.Name)
+	}
+	return map[string]interface{}{"Permissions": names}
+}
+
+// @Summary Get permissions
+// @Description List every permission that can be granted to roles
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Permission
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires roles.read"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/permissions [get]
+// @Security BearerAuth
+func GetPermissions(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+
+	var permissions []models.Permission
+	if err := DB.Order("name ASC").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// @Summary Set role permissions
+// @Description Replace the permissions of a role. Admin roles always hold every permission and cannot be changed. Only permissions the current user holds can be granted.
+// @Tags roles
+// @Accept json
+// @Produce json
+// @Param id path string true "Role ID"
+// @Param permissions body RolePermissionsRequest true "Permission names"
+// @Success 200 {object} models.RoleSwagger
+// @Failure 400 {object} map[string]string "Validation error, unknown permission or admin role"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Forbidden - Requires roles.manage and every granted permission"
+// @Failure 404 {object} map[string]string "Role not found"
+// @Failure 500 {object} map[string]string "Server error"
+// @Router /admin/users/roles/{id}/permissions [put]
+// @Security BearerAuth
+func UpdateRolePermissions(c *gin.Context) {
+	DB := c.MustGet("db").(*gorm.DB)
+	id := c.Param("id")
+
+	var req RolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+
+	if err := utils.Validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errors": utils.FormatValidationErrors(err)})
+		return
+	}
+
+	var role models.Role
+	if err := DB.Preload("Permissions").First(&role, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch role"})
+		return
+	}
+
+	if role.Name == models.RoleAdmin {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Admin roles always hold every permission"})
+		return
+	}
+
+	var permissions []models.Permission
+	if len(req.Permissions) > 0 {
+		if err := DB.Where("name IN ?", req.Permissions).Find(&permissions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch permissions"})
+			return
+		}
+	}
+	known := make(map[string]bool, len(permissions))
+	for _, permission := range permissions {
+		known[permission.Name] = true
+	}
+	for _, name := range req.Permissions {
+		if !known[name] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown permission: %s", name)})
+			return
+		}
+	}
+
+	// roles.manage does not let its holders give anyone, themselves included, more than they hold
+	allowed, err := utils.HoldsPermissions(DB, c.MustGet("userId").(uint), known)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Cannot grant permissions you do not hold"})
+		return
+	}
+
+	// Start transaction
+	tx := DB.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	before := permissionNames(role.Permissions)
+	if err := tx.Model(&role).Association("Permissions").Replace(permissions); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role permissions"})
+		return
+	}
+	role.Permissions = permissions
+	if err := audit.Updated(tx, c, audit.EntityRole, role.ID, before, permissionNames(permissions)); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record audit log"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit changes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
 // @Summary Get user's subordinates
-// @Description Get a list of all users who report to the current user (accessible via both /admin/users/subordinates and /user/subordinates)
+// @Description Get a list of all users who report to the current user (accessible via both /admin/users/subordinates and /user/subordinates), optionally limited to a department and its sub-departments
 // @Tags users
 // @Accept json
 // @Produce json
+// @Param departmentId query int false "Filter by department"
+// @Param subtree query bool false "Include the subordinates in sub-departments of departmentId"
 // @Success 200 {array} models.UserSwagger "List of subordinate users"
+// @Failure 400 {object} map[string]string "Invalid department ID"
 // @Failure 401 {object} map[string]string "Unauthorized or invalid token"
 // @Failure 500 {object} map[string]string "Server error"
 // @Router /user/subordinates [get]
@@ -1028,10 +1454,21 @@ func GetUserSubordinates(c *gin.Context) {
 		return
 	}
 
+	departmentIds, ok := departmentScope(c, DB)
+	if !ok {
+		return
+	}
+
 	// Find all users who have this user as their supervisor
+	query := DB.Where("supervisor_id = ?", uid)
+	if departmentIds != nil {
+		query = query.Where("department_id IN ?", departmentIds)
+	}
+
 	var subordinates []models.User
-	if err := DB.Where("supervisor_id = ?", uid).
+	if err := query.
 		Preload("Role").
+		Preload("Department").
 		Preload("Supervisor").
 		Preload("Supervisor.Role").
 		Find(&subordinates).Error; err != nil {
@@ -1076,6 +1513,7 @@ func GetMyProfile(c *gin.Context) {
 	// Load user with all related data
 	var user models.User
 	if err := DB.Preload("Role").
+		Preload("Department").
 		Preload("Supervisor").
 		Preload("Supervisor.Role").
 		First(&user, uid).Error; err != nil {
@@ -1097,11 +1535,14 @@ func GetMyProfile(c *gin.Context) {
 }
 
 // @Summary Export all users to Excel
-// @Description Export all users (including soft-deleted) with complete details to Excel file
+// @Description Export all users (including soft-deleted) with complete details to Excel file, optionally limited to a department and its sub-departments
 // @Tags users
 // @Accept json
 // @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Param departmentId query int false "Filter by department"
+// @Param subtree query bool false "Include the users of sub-departments of departmentId"
 // @Success 200 {file} file "Excel file with all users data"
+// @Failure 400 {object} map[string]string "Invalid department ID"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden - Only admins can export users"
 // @Failure 500 {object} map[string]string "Server error"
@@ -1110,14 +1551,25 @@ func GetMyProfile(c *gin.Context) {
 func ExportUsersToExcel(c *gin.Context) {
 	DB := c.MustGet("db").(*gorm.DB)
 
+	departmentIds, ok := departmentScope(c, DB)
+	if !ok {
+		return
+	}
+
 	// Fetch all users including soft-deleted with relationships
-	var users []models.User
-	if err := DB.Unscoped().
+	query := DB.Unscoped().
 		Preload("Role").
+		Preload("Department", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped()
+		}).
 		Preload("Supervisor").
-		Preload("Supervisor.Role").
-		Order("id ASC").
-		Find(&users).Error; err != nil {
+		Preload("Supervisor.Role")
+	if departmentIds != nil {
+		query = query.Where("department_id IN ?", departmentIds)
+	}
+
+	var users []models.User
+	if err := query.Order("id ASC").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch users"})
 		return
 	}
@@ -1138,7 +1590,7 @@ func ExportUsersToExcel(c *gin.Context) {
 	}
 
 	// Set headers
-	headers := []string{"ID", "Username", "Name", "Email", "Role Name", "Position", "Position Level", "Supervisor ID", "Supervisor Name", "Created At", "Updated At", "Deleted At"}
+	headers := []string{"ID", "Username", "Name", "Email", "Role Name", "Position", "Position Level", "Supervisor ID", "Supervisor Name", "Created At", "Updated At", "Deleted At", "Department Code", "Department Name"}
 	for i, header := range headers {
 		cell, _ := excelize.CoordinatesToCellName(i+1, 1)
 		f.SetCellValue(sheetName, cell, header)
@@ -1150,7 +1602,7 @@ func ExportUsersToExcel(c *gin.Context) {
 		Fill: excelize.Fill{Type: "pattern", Color: []string{"#E0E0E0"}, Pattern: 1},
 	})
 	if err == nil {
-		f.SetCellStyle(sheetName, "A1", "L1", headerStyle)
+		f.SetCellStyle(sheetName, "A1", "N1", headerStyle)
 	}
 
 	// Write user data
@@ -1228,6 +1680,17 @@ func ExportUsersToExcel(c *gin.Context) {
 		} else {
 			f.SetCellValue(sheetName, cell, "")
 		}
+
+		// Department Code and Name
+		codeCell, _ := excelize.CoordinatesToCellName(13, row)
+		nameCell, _ := excelize.CoordinatesToCellName(14, row)
+		if user.Department != nil {
+			f.SetCellValue(sheetName, codeCell, user.Department.Code)
+			f.SetCellValue(sheetName, nameCell, user.Department.Name)
+		} else {
+			f.SetCellValue(sheetName, codeCell, "")
+			f.SetCellValue(sheetName, nameCell, "")
+		}
 	}
 
 	// Auto-fit columns
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"attendance-app/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// PermissionMiddleware loads the permissions of the user's role for
+// RequirePermission and rejects users whose role holds none
+func PermissionMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, ok := loadPermissions(c)
+		if !ok {
+			return
+		}
+		if len(permissions) == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequirePermission allows the request if the user's role holds any of the permissions
+func RequirePermission(permissions ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		granted, ok := loadPermissions(c)
+		if !ok {
+			return
+		}
+		for _, permission := range permissions {
+			if granted[permission] {
+				c.Next()
+				return
+			}
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
+		c.Abort()
+	}
+}
+
+// RequireAccess requires the read or the write permission for GET requests
+// and the write permission for every other request
+func RequireAccess(read, write string) gin.HandlerFunc {
+	readAccess := RequirePermission(read, write)
+	writeAccess := RequirePermission(write)
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			readAccess(c)
+			return
+		}
+		writeAccess(c)
+	}
+}
+
+// loadPermissions returns the user's permissions, loading them once per request
+func loadPermissions(c *gin.Context) (map[string]bool, bool) {
+	if value, exists := c.Get("permissions"); exists {
+		return value.(map[string]bool), true
+	}
+
+	db := c.MustGet("db").(*gorm.DB)
+	userId := c.MustGet("userId").(uint)
+
+	permissions, err := utils.UserPermissions(db, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load permissions"})
+		c.Abort()
+		return nil, false
+	}
+	c.Set("permissions", permissions)
+	return permissions, true
+}
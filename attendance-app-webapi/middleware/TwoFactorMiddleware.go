@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"attendance-app/handlers"
+	"attendance-app/models"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TwoFactorMiddleware rejects users who must enable two-factor authentication
+// but have not yet, see handlers.TwoFactorRequired. They can still set it up
+// under /user/2fa. API keys are not interactive and are let through.
+func TwoFactorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("apiKeyId"); ok {
+			c.Next()
+			return
+		}
+
+		db := c.MustGet("db").(*gorm.DB)
+		userId := c.MustGet("userId").(uint)
+
+		var user models.User
+		if err := db.Preload("Role").First(&user, userId).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+			c.Abort()
+			return
+		}
+
+		if handlers.TwoFactorRequired(user.Role) && !user.TOTPEnabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication must be enabled for this account. Set it up under /user/2fa"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,81 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// API key scope access levels. Write access includes read access.
+const (
+	ScopeRead  = "read"
+	ScopeWrite = "write"
+)
+
+// ScopeAllAreas matches every area of the API in a scope
+const ScopeAllAreas = "*"
+
+// APIKey authenticates a service, e.g. a reporting job, through the X-API-Key
+// header. The key acts as its user, limited to its scopes. Only a hash of the
+// key is stored; the key itself is shown once on creation.
+type APIKey struct {
+	gorm.Model
+	Name string `json:"Name" gorm:"type:varchar(100);not null"`
+	// Prefix is the start of the key, to recognize it without storing it
+	Prefix  string `json:"Prefix" gorm:"type:varchar(16);index"`
+	KeyHash string `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	// Scopes is a comma separated list of "area:access" entries, where area is
+	// the first path segment after /admin or /user (e.g. attendance, leave,
+	// users) or * for every area, and access is read or write.
+	Scopes string `json:"Scopes" gorm:"type:varchar(500);not null"`
+	// UserID is the user the key acts as; it never has more rights than them
+	UserID      uint       `json:"UserID" gorm:"not null;index"`
+	User        *User      `json:"User,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CreatedByID *uint      `json:"CreatedByID,omitempty"`
+	CreatedBy   *User      `json:"-" gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ExpiresAt   *time.Time `json:"ExpiresAt,omitempty"`
+	LastUsedAt  *time.Time `json:"LastUsedAt,omitempty"`
+	RevokedAt   *time.Time `json:"RevokedAt,omitempty"`
+}
+
+// IsActive reports whether the key can be used
+func (k APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// Allows reports whether the key's scopes grant access to the area
+func (k APIKey) Allows(area string, write bool) bool {
+	scopes, err := ParseScopes(k.Scopes)
+	if err != nil {
+		return false
+	}
+	for _, scope := range scopes {
+		scopeArea, access, _ := strings.Cut(scope, ":")
+		if scopeArea != ScopeAllAreas && scopeArea != area {
+			continue
+		}
+		if access == ScopeWrite || !write {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseScopes parses and checks a comma separated scope list
+func ParseScopes(value string) ([]string, error) {
+	var scopes []string
+	for _, part := range strings.Split(value, ",") {
+		scope := strings.ToLower(strings.TrimSpace(part))
+		if scope == "" {
+			continue
+		}
+		area, access, found := strings.Cut(scope, ":")
+		if !found || area == "" || strings.ContainsAny(area, " /") || (access != ScopeRead && access != ScopeWrite) {
+			return nil, errors.New("invalid scope " + part + ". Use area:read or area:write, e.g. attendance:read or *:read")
+		}
+		scopes = append(scopes, scope)
+	}
+	return scopes, nil
+}
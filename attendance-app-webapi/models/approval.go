@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ApprovalStepStatus string
+
+const (
+	ApprovalStepPending  ApprovalStepStatus = "PENDING"
+	ApprovalStepApproved ApprovalStepStatus = "APPROVED"
+	ApprovalStepRejected ApprovalStepStatus = "REJECTED"
+	// ApprovalStepSkipped is set on steps left undecided when the request was rejected or withdrawn
+	ApprovalStepSkipped ApprovalStepStatus = "SKIPPED"
+)
+
+// ApprovalChainRule sets how many supervisors up the hierarchy must approve a
+// leave request. A request needs the highest Levels of all matching rules and
+// a single approval from the direct supervisor when no rule matches.
+type ApprovalChainRule struct {
+	gorm.Model
+	// LeaveType limits the rule to one leave type; empty applies to every type
+	LeaveType LeaveType `json:"LeaveType" gorm:"type:varchar(20);index"`
+	// MinDays is the number of leave days from which the rule applies
+	MinDays float64 `json:"MinDays" gorm:"type:decimal(6,2);not null;default:0"`
+	// Levels is the number of approvers: 1 is the supervisor, 2 adds the supervisor's supervisor, etc.
+	Levels      uint   `json:"Levels" gorm:"not null"`
+	Description string `json:"Description" gorm:"type:text"`
+}
+
+// LeaveApprovalStep is one approver's decision in the approval chain of a
+// leave request. Steps are decided in order of Level.
+type LeaveApprovalStep struct {
+	gorm.Model
+	LeaveRequestID uint               `json:"LeaveRequestID" gorm:"not null;uniqueIndex:idx_approval_step_request_level"`
+	LeaveRequest   *LeaveRequest      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Level          uint               `json:"Level" gorm:"not null;uniqueIndex:idx_approval_step_request_level"`
+	ApproverID     uint               `json:"ApproverID" gorm:"not null;index"`
+	Approver       *User              `json:"Approver,omitempty" gorm:"foreignKey:ApproverID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	Status         ApprovalStepStatus `json:"Status" gorm:"type:varchar(20);not null;default:'PENDING'"`
+	// ActedByID is the user who made the decision
+	ActedByID *uint      `json:"ActedByID,omitempty"`
+	ActedBy   *User      `json:"-" gorm:"foreignKey:ActedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Notes     string     `json:"Notes" gorm:"type:text"`
+	DecidedAt *time.Time `json:"DecidedAt,omitempty"`
+	// DelegationID is set when the decision was made by a delegate of the approver
+	DelegationID *uint               `json:"DelegationID,omitempty"`
+	Delegation   *ApprovalDelegation `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
@@ -30,8 +31,25 @@ const (
 	LeavePending  LeaveRequestStatus = "PENDING"
 	LeaveApproved LeaveRequestStatus = "APPROVED"
 	LeaveRejected LeaveRequestStatus = "REJECTED"
+	// LeaveCancelled is final: withdrawn while pending or cancellation approved
+	LeaveCancelled LeaveRequestStatus = "CANCELLED"
+	// LeaveCancellationRequested is an approved leave awaiting the supervisor's
+	// decision on cancelling it. It stays in effect until then.
+	LeaveCancellationRequested LeaveRequestStatus = "CANCELLATION_REQUESTED"
 )
 
+type LeaveDayPortion string
+
+const (
+	LeaveFullDay   LeaveDayPortion = "FULL_DAY"
+	LeaveMorning   LeaveDayPortion = "MORNING"
+	LeaveAfternoon LeaveDayPortion = "AFTERNOON"
+	// LeaveHours covers an explicit StartTime to EndTime span within the shift
+	LeaveHours LeaveDayPortion = "HOURS"
+)
+
+// LeaveType is the code of a LeaveTypeDefinition. The constants below are the
+// built-in types that are seeded on startup; admins can define more.
 type LeaveType string
 
 const (
@@ -40,14 +58,17 @@ const (
 )
 
 // Location defines a valid geographical area for attendance.
+// When Geometry holds a GeoJSON Polygon or MultiPolygon it is used as the
+// geofence instead of the circle described by Latitude, Longitude and Radius.
 type Location struct {
 	gorm.Model
-	Name        string       `json:"Name" gorm:"not null"`
-	Address     string       `json:"Address"`
-	Latitude    float64      `json:"Latitude" gorm:"not null"`
-	Longitude   float64      `json:"Longitude" gorm:"not null"`
-	Radius      uint         `json:"Radius" gorm:"not null;comment:Radius in meters"`
-	Attendances []Attendance `json:"Attendances,omitempty" gorm:"foreignKey:LocationID"`
+	Name        string          `json:"Name" gorm:"not null"`
+	Address     string          `json:"Address"`
+	Latitude    float64         `json:"Latitude" gorm:"not null"`
+	Longitude   float64         `json:"Longitude" gorm:"not null"`
+	Radius      uint            `json:"Radius" gorm:"not null;comment:Radius in meters"`
+	Geometry    json.RawMessage `json:"Geometry,omitempty" gorm:"type:json;comment:GeoJSON Polygon or MultiPolygon geofence"`
+	Attendances []Attendance    `json:"Attendances,omitempty" gorm:"foreignKey:LocationID"`
 }
 
 // Attendance stores a single attendance record for a user.
@@ -72,20 +93,69 @@ type Attendance struct {
 	ValidatorID      *uint            `json:"ValidatorID"`
 	Validator        *User            `json:"Validator" gorm:"foreignKey:ValidatorID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	Notes            string           `json:"Notes" gorm:"type:text"`
+	// ValidationDelegationID is set when the validator acted as a delegate of the supervisor
+	ValidationDelegationID *uint               `json:"ValidationDelegationID,omitempty"`
+	ValidationDelegation   *ApprovalDelegation `json:"-" gorm:"foreignKey:ValidationDelegationID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// LeaveRequestID links the record to approved leave for the day. Partial-day
+	// leave is linked to the record of the worked part of the shift.
+	LeaveRequestID *uint         `json:"LeaveRequestID" gorm:"index"`
+	LeaveRequest   *LeaveRequest `json:"LeaveRequest,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// ReportingPath is filled in for supervisors viewing their subordinates' records
+	ReportingPath []ReportingPathEntry `json:"ReportingPath,omitempty" gorm:"-"`
 }
 
 // LeaveRequest stores a user's request for leave.
 type LeaveRequest struct {
 	gorm.Model
-	UserID        uint               `json:"UserID" gorm:"not null;index"`
-	User          User               `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
-	LeaveType     LeaveType          `json:"LeaveType" gorm:"type:varchar(20);not null"`
-	StartDate     time.Time          `json:"StartDate" gorm:"not null"`
-	EndDate       time.Time          `json:"EndDate" gorm:"not null"`
+	UserID    uint      `json:"UserID" gorm:"not null;index"`
+	User      User      `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	LeaveType LeaveType `json:"LeaveType" gorm:"type:varchar(20);not null"`
+	StartDate time.Time `json:"StartDate" gorm:"not null"`
+	EndDate   time.Time `json:"EndDate" gorm:"not null"`
+	// DayPortion is FULL_DAY for whole days; partial portions cover part of a single day
+	DayPortion LeaveDayPortion `json:"DayPortion" gorm:"type:varchar(20);not null;default:'FULL_DAY'"`
+	// StartTime and EndTime ("HH:MM") are the leave span of partial-day requests
+	StartTime string `json:"StartTime,omitempty" gorm:"type:varchar(5)"`
+	EndTime   string `json:"EndTime,omitempty" gorm:"type:varchar(5)"`
+	// Days is the number of working days the request covers, fractional for partial days
+	Days          float64            `json:"Days" gorm:"type:decimal(6,2);not null;default:0"`
 	Reason        string             `json:"Reason" gorm:"type:text;not null"`
 	AttachmentURL string             `json:"AttachmentURL"`
-	Status        LeaveRequestStatus `json:"Status" gorm:"type:varchar(20);default:'PENDING';index"`
+	Status        LeaveRequestStatus `json:"Status" gorm:"type:varchar(30);default:'PENDING';index"`
 	ApproverID    *uint              `json:"ApproverID"`
 	Approver      *User              `json:"Approver" gorm:"foreignKey:ApproverID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 	ApproverNotes string             `json:"ApproverNotes" gorm:"type:text"`
+	// CancellationReason is given by the employee when cancelling an approved leave
+	CancellationReason string `json:"CancellationReason,omitempty" gorm:"type:text"`
+	// CancellationNotes are the supervisor's notes on the cancellation request
+	CancellationNotes string     `json:"CancellationNotes,omitempty" gorm:"type:text"`
+	CancelledAt       *time.Time `json:"CancelledAt,omitempty"`
+	CancelledByID     *uint      `json:"CancelledByID,omitempty"`
+	CancelledBy       *User      `json:"-" gorm:"foreignKey:CancelledByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// CancellationDelegationID is set when the cancellation was approved by a delegate of the supervisor
+	CancellationDelegationID *uint               `json:"CancellationDelegationID,omitempty"`
+	CancellationDelegation   *ApprovalDelegation `json:"-" gorm:"foreignKey:CancellationDelegationID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// ApprovalSteps are the decisions required before the request is APPROVED
+	ApprovalSteps []LeaveApprovalStep `json:"ApprovalSteps,omitempty" gorm:"foreignKey:LeaveRequestID"`
+
+	// ReportingPath is filled in for supervisors viewing their subordinates' requests
+	ReportingPath []ReportingPathEntry `json:"ReportingPath,omitempty" gorm:"-"`
+}
+
+// LeaveInEffectStatuses are the statuses of approved leave that has not been cancelled
+var LeaveInEffectStatuses = []LeaveRequestStatus{LeaveApproved, LeaveCancellationRequested}
+
+// IsPartialDay reports whether the request covers only part of a single day.
+func (l LeaveRequest) IsPartialDay() bool {
+	return l.DayPortion != "" && l.DayPortion != LeaveFullDay
+}
+
+// IsValidLeaveDayPortion checks if the given portion is supported
+func IsValidLeaveDayPortion(portion LeaveDayPortion) bool {
+	switch portion {
+	case LeaveFullDay, LeaveMorning, LeaveAfternoon, LeaveHours:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+)
+
+type AuditAction string
+
+const (
+	AuditCreate AuditAction = "CREATE"
+	AuditUpdate AuditAction = "UPDATE"
+	AuditDelete AuditAction = "DELETE"
+)
+
+// AuditLog records a single create, update or delete of an entity made
+// through the API. Entries are only ever appended.
+type AuditLog struct {
+	gorm.Model
+	// ActorID is empty for changes made without an authenticated user
+	ActorID   *uint  `json:"ActorID" gorm:"index"`
+	Actor     *User  `json:"-" gorm:"foreignKey:ActorID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ActorName string `json:"ActorName" gorm:"type:varchar(255)"`
+	// APIKeyID is set when the actor authenticated with an API key
+	APIKeyID   *uint       `json:"APIKeyID,omitempty" gorm:"index"`
+	Action     AuditAction `json:"Action" gorm:"type:varchar(10);not null"`
+	EntityType string      `json:"EntityType" gorm:"type:varchar(50);not null;index:idx_audit_entity"`
+	EntityID   uint        `json:"EntityID" gorm:"not null;index:idx_audit_entity"`
+	// Before and After are JSON snapshots of the entity; Before is empty for
+	// creates and After for deletes. Changes maps each changed field to its
+	// "from" and "to" values.
+	Before    json.RawMessage `json:"Before,omitempty" gorm:"type:json"`
+	After     json.RawMessage `json:"After,omitempty" gorm:"type:json"`
+	Changes   json.RawMessage `json:"Changes,omitempty" gorm:"type:json"`
+	IPAddress string          `json:"IPAddress" gorm:"type:varchar(45)"`
+}
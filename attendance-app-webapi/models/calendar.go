@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CalendarDayType string
+
+const (
+	PublicHoliday  CalendarDayType = "PUBLIC_HOLIDAY"
+	CompanyHoliday CalendarDayType = "COMPANY_HOLIDAY"
+	// WorkingDayOverride marks a day that is worked even though the work
+	// schedule would normally treat it as a day off (e.g. a substitute workday)
+	WorkingDayOverride CalendarDayType = "WORKING_DAY"
+)
+
+type CalendarDaySource string
+
+const (
+	CalendarSourceManual CalendarDaySource = "MANUAL"
+	CalendarSourceICS    CalendarDaySource = "ICS"
+)
+
+// CalendarDay is an entry in the shared company calendar. Holidays are days
+// off for everyone regardless of their work schedule, while working day
+// overrides turn a scheduled day off into a working day.
+type CalendarDay struct {
+	gorm.Model
+	Date        time.Time         `json:"Date" gorm:"type:date;not null;uniqueIndex"`
+	Name        string            `json:"Name" gorm:"type:varchar(255);not null"`
+	Type        CalendarDayType   `json:"Type" gorm:"type:varchar(20);not null;default:'PUBLIC_HOLIDAY'"`
+	Description string            `json:"Description" gorm:"type:text"`
+	Source      CalendarDaySource `json:"Source" gorm:"type:varchar(20);not null;default:'MANUAL'"`
+	// ExternalUID is the UID of the iCalendar event the day was imported from
+	ExternalUID string `json:"ExternalUID,omitempty" gorm:"type:varchar(255)"`
+}
+
+// IsWorkingDay reports whether the calendar entry makes the day a working day.
+func (d CalendarDay) IsWorkingDay() bool {
+	return d.Type == WorkingDayOverride
+}
+
+// IsValidCalendarDayType checks if the given type is a supported calendar day type
+func IsValidCalendarDayType(t CalendarDayType) bool {
+	switch t {
+	case PublicHoliday, CompanyHoliday, WorkingDayOverride:
+		return true
+	}
+	return false
+}
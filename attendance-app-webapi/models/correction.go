@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CorrectionStatus string
+
+const (
+	CorrectionPending  CorrectionStatus = "PENDING"
+	CorrectionApproved CorrectionStatus = "APPROVED"
+	CorrectionRejected CorrectionStatus = "REJECTED"
+)
+
+// AttendanceCorrectionRequest is an employee's proposal to correct the check-in
+// or check-out time of an attendance record, or to add a missing record when
+// AttendanceID is empty. On approval the original values are kept here.
+type AttendanceCorrectionRequest struct {
+	gorm.Model
+	UserID       uint        `json:"UserID" gorm:"not null;index"`
+	User         User        `gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	AttendanceID *uint       `json:"AttendanceID" gorm:"index"`
+	Attendance   *Attendance `json:"Attendance,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// Proposed times; an empty time keeps the current value
+	CheckInTime  *time.Time `json:"CheckInTime"`
+	CheckOutTime *time.Time `json:"CheckOutTime"`
+	Reason       string     `json:"Reason" gorm:"type:text;not null"`
+	EvidenceURL  string     `json:"EvidenceURL"`
+
+	Status        CorrectionStatus `json:"Status" gorm:"type:varchar(20);default:'PENDING';index"`
+	ReviewerID    *uint            `json:"ReviewerID"`
+	Reviewer      *User            `json:"Reviewer" gorm:"foreignKey:ReviewerID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	ReviewerNotes string           `json:"ReviewerNotes" gorm:"type:text"`
+	ReviewedAt    *time.Time       `json:"ReviewedAt"`
+	// DelegationID is set when the reviewer acted as a delegate of the supervisor
+	DelegationID *uint               `json:"DelegationID,omitempty"`
+	Delegation   *ApprovalDelegation `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// Values of the attendance record before the correction was applied
+	OriginalCheckInTime      *time.Time       `json:"OriginalCheckInTime,omitempty"`
+	OriginalCheckOutTime     *time.Time       `json:"OriginalCheckOutTime,omitempty"`
+	OriginalStatus           AttendanceStatus `json:"OriginalStatus,omitempty" gorm:"type:varchar(20)"`
+	OriginalValidationStatus ValidationStatus `json:"OriginalValidationStatus,omitempty" gorm:"type:varchar(20)"`
+
+	// ReportingPath is filled in for supervisors viewing their subordinates' requests
+	ReportingPath []ReportingPathEntry `json:"ReportingPath,omitempty" gorm:"-"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ApprovalDelegation grants the delegate the delegator's approval rights over
+// the delegator's subordinates from StartDate to EndDate, both inclusive.
+type ApprovalDelegation struct {
+	gorm.Model
+	DelegatorID uint      `json:"DelegatorID" gorm:"not null;index"`
+	Delegator   *User     `json:"Delegator,omitempty" gorm:"foreignKey:DelegatorID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	DelegateID  uint      `json:"DelegateID" gorm:"not null;index"`
+	Delegate    *User     `json:"Delegate,omitempty" gorm:"foreignKey:DelegateID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	StartDate   time.Time `json:"StartDate" gorm:"type:date;not null"`
+	EndDate     time.Time `json:"EndDate" gorm:"type:date;not null"`
+	Reason      string    `json:"Reason" gorm:"type:text"`
+	// CreatedByID is the delegator or the admin who granted the delegation
+	CreatedByID *uint `json:"CreatedByID,omitempty"`
+	CreatedBy   *User `json:"-" gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	// RevokedAt ends the delegation before its end date
+	RevokedAt *time.Time `json:"RevokedAt,omitempty"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// Department is a unit of the organisation. Departments form a tree through
+// ParentID and are independent of the supervisor links between users; the
+// head of a department sees the records of everyone in it and below it.
+type Department struct {
+	gorm.Model
+	Name string `json:"Name" gorm:"type:varchar(255);not null"`
+	// Code is a short unique identifier, e.g. "FIN" or "IT-OPS"
+	Code       string `json:"Code" gorm:"type:varchar(50);uniqueIndex;not null"`
+	CostCentre string `json:"CostCentre" gorm:"type:varchar(50)"`
+
+	ParentID *uint        `json:"ParentID,omitempty" gorm:"index"`
+	Parent   *Department  `json:"-" gorm:"foreignKey:ParentID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT;"`
+	Children []Department `json:"Children,omitempty" gorm:"foreignKey:ParentID"`
+
+	// No foreign key constraint: users reference departments, and the tables
+	// could not be created in either order on a fresh database
+	HeadID *uint `json:"HeadID,omitempty" gorm:"index"`
+	Head   *User `json:"Head,omitempty" gorm:"foreignKey:HeadID;constraint:-"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerificationToken is a single-use token emailed to the new address when
+// a user changes their email. The email is only changed once the link is
+// followed. Only its hash is stored.
+type EmailVerificationToken struct {
+	gorm.Model
+	UserID    uint       `json:"UserID" gorm:"not null;index"`
+	User      *User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	NewEmail  string     `json:"NewEmail" gorm:"type:varchar(255);not null"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"ExpiresAt" gorm:"index"`
+	UsedAt    *time.Time `json:"UsedAt,omitempty"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+type AccrualMethod string
+
+const (
+	// AccrueYearly credits the whole annual entitlement at the start of the year
+	AccrueYearly AccrualMethod = "YEARLY"
+	// AccrueMonthly credits a twelfth of the annual entitlement at the start of each month
+	AccrueMonthly AccrualMethod = "MONTHLY"
+)
+
+type LeaveLedgerEntryType string
+
+const (
+	LedgerAccrual    LeaveLedgerEntryType = "ACCRUAL"
+	LedgerCarryOver  LeaveLedgerEntryType = "CARRY_OVER"
+	LedgerDeduction  LeaveLedgerEntryType = "DEDUCTION"
+	LedgerReversal   LeaveLedgerEntryType = "REVERSAL"
+	LedgerAdjustment LeaveLedgerEntryType = "ADJUSTMENT"
+)
+
+// LeaveEntitlement is a user's yearly quota for a leave type that counts
+// against a balance (see LeaveTypeDefinition.CountsAgainstBalance).
+type LeaveEntitlement struct {
+	gorm.Model
+	UserID        uint          `json:"UserID" gorm:"not null;uniqueIndex:idx_entitlement_user_type_year"`
+	User          User          `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	LeaveType     LeaveType     `json:"LeaveType" gorm:"type:varchar(20);not null;uniqueIndex:idx_entitlement_user_type_year"`
+	Year          int           `json:"Year" gorm:"not null;uniqueIndex:idx_entitlement_user_type_year"`
+	AnnualDays    float64       `json:"AnnualDays" gorm:"type:decimal(6,2);not null"`
+	AccrualMethod AccrualMethod `json:"AccrualMethod" gorm:"type:varchar(20);not null;default:'YEARLY'"`
+	// CarryOverLimit is the maximum unused balance moved into the next year
+	CarryOverLimit float64 `json:"CarryOverLimit" gorm:"type:decimal(6,2);not null;default:0"`
+}
+
+// LeaveLedgerEntry is a single change to a leave balance. The balance of a
+// user for a leave type and year is the sum of its entries.
+type LeaveLedgerEntry struct {
+	gorm.Model
+	UserID    uint                 `json:"UserID" gorm:"not null;index:idx_ledger_user_type_year"`
+	User      User                 `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	LeaveType LeaveType            `json:"LeaveType" gorm:"type:varchar(20);not null;index:idx_ledger_user_type_year"`
+	Year      int                  `json:"Year" gorm:"not null;index:idx_ledger_user_type_year"`
+	EntryType LeaveLedgerEntryType `json:"EntryType" gorm:"type:varchar(20);not null"`
+	// Days is positive for credits and negative for debits
+	Days float64 `json:"Days" gorm:"type:decimal(6,2);not null"`
+	// Period identifies accruals and carry-overs ("2025" or "2025-03") so they are posted only once
+	Period         string        `json:"Period,omitempty" gorm:"type:varchar(10)"`
+	LeaveRequestID *uint         `json:"LeaveRequestID,omitempty" gorm:"index"`
+	LeaveRequest   *LeaveRequest `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+	Description    string        `json:"Description" gorm:"type:text"`
+	CreatedByID    *uint         `json:"CreatedByID,omitempty"`
+	CreatedBy      *User         `json:"-" gorm:"foreignKey:CreatedByID;constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+}
+
+// IsValidAccrualMethod checks if the given accrual method is supported
+func IsValidAccrualMethod(method AccrualMethod) bool {
+	return method == AccrueYearly || method == AccrueMonthly
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// LeaveTypeDefinition describes a leave type and the rules applied when a
+// request of that type is submitted. LeaveRequest.LeaveType and
+// LeaveEntitlement.LeaveType refer to its Code.
+type LeaveTypeDefinition struct {
+	gorm.Model
+	Code               LeaveType `json:"Code" gorm:"type:varchar(20);not null;uniqueIndex"`
+	Name               string    `json:"Name" gorm:"type:varchar(255);not null"`
+	Description        string    `json:"Description" gorm:"type:text"`
+	AttachmentRequired bool      `json:"AttachmentRequired" gorm:"not null;default:false"`
+	IsPaid             bool      `json:"IsPaid" gorm:"not null"`
+	// MaxConsecutiveDays limits the calendar days of a single request (0 = no limit)
+	MaxConsecutiveDays uint `json:"MaxConsecutiveDays" gorm:"not null;default:0"`
+	// MinNoticeDays is how many days before the start date a request must be submitted
+	MinNoticeDays        uint `json:"MinNoticeDays" gorm:"not null;default:0"`
+	CountsAgainstBalance bool `json:"CountsAgainstBalance" gorm:"not null;default:false"`
+	// Inactive types are kept for existing requests but cannot be requested
+	IsActive bool `json:"IsActive" gorm:"not null"`
+}
+
+// DefaultLeaveTypes are the leave types that existed before they became configurable
+var DefaultLeaveTypes = []LeaveTypeDefinition{
+	{
+		Code:               Sick,
+		Name:               "Sick Leave",
+		AttachmentRequired: true,
+		IsPaid:             true,
+		IsActive:           true,
+	},
+	{
+		Code:               Permit,
+		Name:               "Permit",
+		AttachmentRequired: true,
+		IsPaid:             true,
+		IsActive:           true,
+	},
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their
+// password. Only its hash is stored.
+type PasswordResetToken struct {
+	gorm.Model
+	UserID    uint       `json:"UserID" gorm:"not null;index"`
+	User      *User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	TokenHash string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time  `json:"ExpiresAt" gorm:"index"`
+	UsedAt    *time.Time `json:"UsedAt,omitempty"`
+	// IP address the reset was requested from
+	IPAddress string `json:"IPAddress" gorm:"type:varchar(45)"`
+}
@@ -0,0 +1,69 @@
+package models
+
+// Permission keys, named area.action. Roles hold permissions and admin routes
+// require them, so that e.g. HR staff can manage leave without editing
+// locations or settings.
+const (
+	PermUsersRead         = "users.read"
+	PermUsersManage       = "users.manage"
+	PermDepartmentsRead   = "departments.read"
+	PermDepartmentsManage = "departments.manage"
+	PermRolesRead         = "roles.read"
+	PermRolesManage       = "roles.manage"
+	PermReportsRead       = "reports.read"
+	PermReportsExport     = "reports.export"
+	PermSettingsManage    = "settings.manage"
+	PermLocationsRead     = "locations.read"
+	PermLocationsManage   = "locations.manage"
+	PermSchedulesRead     = "schedules.read"
+	PermSchedulesManage   = "schedules.manage"
+	PermCalendarRead      = "calendar.read"
+	PermCalendarManage    = "calendar.manage"
+	PermLeaveRead         = "leave.read"
+	PermLeaveManage       = "leave.manage"
+	PermLeaveApproveAny   = "leave.approve.any"
+	PermDelegationsRead   = "delegations.read"
+	PermDelegationsManage = "delegations.manage"
+	PermAuditRead         = "audit.read"
+	PermSecurityRead      = "security.read"
+	PermSecurityManage    = "security.manage"
+	PermAPIKeysManage     = "apikeys.manage"
+	PermEmailSend         = "email.send"
+)
+
+// Permission is a right that can be granted to roles
+type Permission struct {
+	ID          uint   `json:"ID" gorm:"primarykey"`
+	Name        string `json:"Name" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Description string `json:"Description" gorm:"type:varchar(255)"`
+}
+
+// Permissions lists every permission with its description. They are created
+// on startup and every admin role holds all of them.
+var Permissions = []Permission{
+	{Name: PermUsersRead, Description: "View users"},
+	{Name: PermUsersManage, Description: "Create, update and delete users"},
+	{Name: PermDepartmentsRead, Description: "View departments and their members"},
+	{Name: PermDepartmentsManage, Description: "Create, update and delete departments and assign users and heads"},
+	{Name: PermRolesRead, Description: "View roles and permissions"},
+	{Name: PermRolesManage, Description: "Create, update and delete roles and grant permissions"},
+	{Name: PermReportsRead, Description: "View attendance summary reports"},
+	{Name: PermReportsExport, Description: "Export reports and user lists"},
+	{Name: PermSettingsManage, Description: "Change application settings"},
+	{Name: PermLocationsRead, Description: "View office locations and assignments"},
+	{Name: PermLocationsManage, Description: "Create, update, delete and assign office locations"},
+	{Name: PermSchedulesRead, Description: "View work schedules"},
+	{Name: PermSchedulesManage, Description: "Create, update, delete and assign work schedules"},
+	{Name: PermCalendarRead, Description: "View the company calendar"},
+	{Name: PermCalendarManage, Description: "Change and import the company calendar"},
+	{Name: PermLeaveRead, Description: "View leave types, entitlements, balances and approval rules"},
+	{Name: PermLeaveManage, Description: "Change leave types, entitlements, balances and approval rules"},
+	{Name: PermLeaveApproveAny, Description: "Approve or reject any leave request and cancellation"},
+	{Name: PermDelegationsRead, Description: "View approval delegations"},
+	{Name: PermDelegationsManage, Description: "Create and revoke approval delegations for anyone"},
+	{Name: PermAuditRead, Description: "View the audit log"},
+	{Name: PermSecurityRead, Description: "View security events and login lockouts"},
+	{Name: PermSecurityManage, Description: "Clear login lockouts and reset two-factor authentication"},
+	{Name: PermAPIKeysManage, Description: "Create and revoke API keys"},
+	{Name: PermEmailSend, Description: "Send test emails and reminders"},
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// RateLimitEvent is one occurrence of a rate limited action, e.g. a password
+// reset request for an account or from an IP address. Keeping them in the
+// database shares the limits between every instance of the API.
+type RateLimitEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	Key       string    `gorm:"type:varchar(150);not null;index:idx_rate_limit_key_created"`
+	CreatedAt time.Time `gorm:"index:idx_rate_limit_key_created"`
+}
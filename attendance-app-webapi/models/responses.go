@@ -30,7 +30,12 @@ type AttendanceSwagger struct {
 	Status            AttendanceStatus `json:"Status"`
 	ValidationStatus  ValidationStatus `json:"ValidationStatus"`
 	ValidatorID       *uint            `json:"ValidatorID"`
-	Notes             string           `json:"Notes"`
+	// Set when the validator acted as a delegate of the supervisor
+	ValidationDelegationID *uint  `json:"ValidationDelegationID,omitempty"`
+	Notes                  string `json:"Notes"`
+	LeaveRequestID         *uint  `json:"LeaveRequestID"`
+	// Reporting line from the viewing supervisor down to the user, on subordinate listings
+	ReportingPath []ReportingPathEntry `json:"ReportingPath,omitempty"`
 }
 
 // UserSwagger represents user for Swagger (without gorm.Model)
@@ -42,6 +47,12 @@ type UserSwagger struct {
 	Email     string       `json:"Email"`
 	RoleID    uint         `json:"RoleID"`
 	Role      *RoleSwagger `json:"Role"`
+	// Employment status and the period it applies to
+	EmploymentStatus      EmploymentStatus `json:"EmploymentStatus" example:"active"`
+	EmploymentStatusFrom  *time.Time       `json:"EmploymentStatusFrom,omitempty"`
+	EmploymentStatusUntil *time.Time       `json:"EmploymentStatusUntil,omitempty"`
+	// Department the user belongs to
+	DepartmentID *uint `json:"DepartmentID,omitempty"`
 }
 
 // RoleSwagger represents role for Swagger (without gorm.DeletedAt)
@@ -52,6 +63,8 @@ type RoleSwagger struct {
 	Name          RoleName  `json:"Name"`
 	Position      string    `json:"Position"`
 	PositionLevel uint      `json:"PositionLevel"`
+	// Permissions granted to the role
+	Permissions []Permission `json:"Permissions,omitempty"`
 }
 
 // LeaveRequestSwagger represents leave request for Swagger (without gorm.Model)
@@ -63,11 +76,64 @@ type LeaveRequestSwagger struct {
 	LeaveType     LeaveType          `json:"LeaveType"`
 	StartDate     time.Time          `json:"StartDate"`
 	EndDate       time.Time          `json:"EndDate"`
+	DayPortion    LeaveDayPortion    `json:"DayPortion"`
+	StartTime     string             `json:"StartTime,omitempty"`
+	EndTime       string             `json:"EndTime,omitempty"`
+	Days          float64            `json:"Days"`
 	Reason        string             `json:"Reason"`
 	AttachmentURL string             `json:"AttachmentURL"`
 	Status        LeaveRequestStatus `json:"Status"`
 	ApproverID    *uint              `json:"ApproverID"`
 	ApproverNotes string             `json:"ApproverNotes"`
+	// Set when the leave was cancelled or its cancellation was requested
+	CancellationReason string     `json:"CancellationReason,omitempty"`
+	CancellationNotes  string     `json:"CancellationNotes,omitempty"`
+	CancelledAt        *time.Time `json:"CancelledAt,omitempty"`
+	CancelledByID      *uint      `json:"CancelledByID,omitempty"`
+	// Set when the cancellation was approved by a delegate of the supervisor
+	CancellationDelegationID *uint `json:"CancellationDelegationID,omitempty"`
+	// Decisions of the approval chain, in order
+	ApprovalSteps []LeaveApprovalStepSwagger `json:"ApprovalSteps,omitempty"`
+	// Reporting line from the viewing supervisor down to the user, on subordinate listings
+	ReportingPath []ReportingPathEntry `json:"ReportingPath,omitempty"`
+}
+
+// LeaveApprovalStepSwagger represents a leave approval step for Swagger (without gorm.Model)
+type LeaveApprovalStepSwagger struct {
+	ID             uint               `json:"ID"`
+	LeaveRequestID uint               `json:"LeaveRequestID"`
+	Level          uint               `json:"Level"`
+	ApproverID     uint               `json:"ApproverID"`
+	Status         ApprovalStepStatus `json:"Status"`
+	ActedByID      *uint              `json:"ActedByID,omitempty"`
+	Notes          string             `json:"Notes"`
+	DecidedAt      *time.Time         `json:"DecidedAt,omitempty"`
+	// Set when the decision was made by a delegate of the approver
+	DelegationID *uint `json:"DelegationID,omitempty"`
+}
+
+// AttendanceCorrectionRequestSwagger represents attendance correction request for Swagger (without gorm.Model)
+type AttendanceCorrectionRequestSwagger struct {
+	ID                       uint             `json:"ID"`
+	CreatedAt                time.Time        `json:"CreatedAt"`
+	UpdatedAt                time.Time        `json:"UpdatedAt"`
+	UserID                   uint             `json:"UserID"`
+	AttendanceID             *uint            `json:"AttendanceID"`
+	CheckInTime              *time.Time       `json:"CheckInTime"`
+	CheckOutTime             *time.Time       `json:"CheckOutTime"`
+	Reason                   string           `json:"Reason"`
+	EvidenceURL              string           `json:"EvidenceURL"`
+	Status                   CorrectionStatus `json:"Status"`
+	ReviewerID               *uint            `json:"ReviewerID"`
+	ReviewerNotes            string           `json:"ReviewerNotes"`
+	ReviewedAt               *time.Time       `json:"ReviewedAt"`
+	DelegationID             *uint            `json:"DelegationID,omitempty"`
+	OriginalCheckInTime      *time.Time       `json:"OriginalCheckInTime,omitempty"`
+	OriginalCheckOutTime     *time.Time       `json:"OriginalCheckOutTime,omitempty"`
+	OriginalStatus           AttendanceStatus `json:"OriginalStatus,omitempty"`
+	OriginalValidationStatus ValidationStatus `json:"OriginalValidationStatus,omitempty"`
+	// Reporting line from the viewing supervisor down to the user, on subordinate listings
+	ReportingPath []ReportingPathEntry `json:"ReportingPath,omitempty"`
 }
 
 // LocationSwagger represents location for Swagger (without gorm.Model)
@@ -80,6 +146,22 @@ type LocationSwagger struct {
 	Latitude  float64   `json:"Latitude"`
 	Longitude float64   `json:"Longitude"`
 	Radius    uint      `json:"Radius"`
+	// GeoJSON Polygon or MultiPolygon geofence, omitted for circular locations
+	Geometry interface{} `json:"Geometry,omitempty"`
+}
+
+// DepartmentSwagger represents department for Swagger (without gorm.Model)
+type DepartmentSwagger struct {
+	ID         uint                `json:"ID"`
+	CreatedAt  time.Time           `json:"CreatedAt"`
+	UpdatedAt  time.Time           `json:"UpdatedAt"`
+	Name       string              `json:"Name"`
+	Code       string              `json:"Code"`
+	CostCentre string              `json:"CostCentre"`
+	ParentID   *uint               `json:"ParentID,omitempty"`
+	HeadID     *uint               `json:"HeadID,omitempty"`
+	Head       *UserSwagger        `json:"Head,omitempty"`
+	Children   []DepartmentSwagger `json:"Children,omitempty"`
 }
 
 // SettingSwagger represents setting for Swagger (without gorm.Model)
@@ -91,3 +173,22 @@ type SettingSwagger struct {
 	Value     string    `json:"Value"`
 	UpdatedBy *uint     `json:"UpdatedBy,omitempty"`
 }
+
+// AuditLogSwagger represents audit log entry for Swagger (without gorm.Model)
+type AuditLogSwagger struct {
+	ID         uint        `json:"ID"`
+	CreatedAt  time.Time   `json:"CreatedAt"`
+	ActorID    *uint       `json:"ActorID"`
+	ActorName  string      `json:"ActorName"`
+	APIKeyID   *uint       `json:"APIKeyID,omitempty"`
+	Action     AuditAction `json:"Action"`
+	EntityType string      `json:"EntityType"`
+	EntityID   uint        `json:"EntityID"`
+	// Snapshot of the entity before the change, omitted for creates
+	Before interface{} `json:"Before,omitempty"`
+	// Snapshot of the entity after the change, omitted for deletes
+	After interface{} `json:"After,omitempty"`
+	// Changed fields with their "from" and "to" values
+	Changes   interface{} `json:"Changes,omitempty"`
+	IPAddress string      `json:"IPAddress"`
+}
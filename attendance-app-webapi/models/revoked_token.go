@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RevokedToken is a JWT revoked before its expiry, e.g. on logout.
+// Entries are purged once the token has expired anyway.
+type RevokedToken struct {
+	// JWT ID (jti claim) of the revoked token
+	TokenID   string    `json:"TokenID" gorm:"primaryKey;type:varchar(64)"`
+	UserID    uint      `json:"UserID" gorm:"index"`
+	ExpiresAt time.Time `json:"ExpiresAt" gorm:"index"`
+	CreatedAt time.Time `json:"CreatedAt"`
+}
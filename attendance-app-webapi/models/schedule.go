@@ -0,0 +1,206 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkSchedule defines a shift pattern that can be assigned to users or roles.
+// StartTime and EndTime use the 24-hour "HH:MM" format. When EndTime is earlier
+// than StartTime the shift crosses midnight and ends on the following day.
+//
+// A split shift is a schedule with a break between its two segments, from
+// BreakStart to BreakEnd. The break is not working time: the grace period, the
+// lateness threshold after partial-day leave, leave hours and worked hours all
+// skip it. There is still one check-in and one check-out per shift day, so a
+// check-in during the break or the second segment counts for the same shift.
+type WorkSchedule struct {
+	// Manually define fields from gorm.Model to add tags
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index;uniqueIndex:idx_work_schedule_name_deleted"`
+
+	Name      string `json:"Name" gorm:"type:varchar(255);not null;uniqueIndex:idx_work_schedule_name_deleted"`
+	StartTime string `json:"StartTime" gorm:"type:varchar(5);not null"`
+	EndTime   string `json:"EndTime" gorm:"type:varchar(5);not null"`
+	// BreakStart and BreakEnd bound an unpaid break within the shift, both empty when there is none
+	BreakStart         string `json:"BreakStart" gorm:"type:varchar(5);not null;default:''"`
+	BreakEnd           string `json:"BreakEnd" gorm:"type:varchar(5);not null;default:''"`
+	GracePeriodMinutes uint   `json:"GracePeriodMinutes" gorm:"not null;default:0"`
+	// WorkingDays is a comma separated list of weekdays (0 = Sunday ... 6 = Saturday)
+	WorkingDays string `json:"WorkingDays" gorm:"type:varchar(20);not null;default:'1,2,3,4,5'"`
+	IsDefault   bool   `json:"IsDefault" gorm:"not null;default:false"`
+}
+
+// DefaultWorkSchedule mirrors the original office hours and is used when no
+// schedule has been configured at all.
+var DefaultWorkSchedule = WorkSchedule{
+	Name:               "Regular Office Hours",
+	StartTime:          "07:30",
+	EndTime:            "17:00",
+	GracePeriodMinutes: 0,
+	WorkingDays:        "1,2,3,4,5",
+	IsDefault:          true,
+}
+
+// ParseClock parses a "HH:MM" string into hours and minutes.
+func ParseClock(value string) (int, int, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return parsed.Hour(), parsed.Minute(), nil
+}
+
+// ParseWorkingDays parses a comma separated weekday list into a set.
+func ParseWorkingDays(value string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		day, err := strconv.Atoi(part)
+		if err != nil || day < 0 || day > 6 {
+			return nil, fmt.Errorf("invalid weekday %q, expected 0 (Sunday) to 6 (Saturday)", part)
+		}
+		days[time.Weekday(day)] = true
+	}
+	return days, nil
+}
+
+// IsOvernight reports whether the shift ends on the day after it starts.
+func (s WorkSchedule) IsOvernight() bool {
+	return s.EndTime <= s.StartTime
+}
+
+// IsWorkingDay reports whether the given day is one of the schedule's weekdays.
+func (s WorkSchedule) IsWorkingDay(day time.Time) bool {
+	days, err := ParseWorkingDays(s.WorkingDays)
+	if err != nil {
+		return false
+	}
+	return days[day.Weekday()]
+}
+
+// ShiftWindow returns the start and end of the shift that begins on the given day.
+func (s WorkSchedule) ShiftWindow(day time.Time) (time.Time, time.Time) {
+	startHour, startMinute, _ := ParseClock(s.StartTime)
+	endHour, endMinute, _ := ParseClock(s.EndTime)
+
+	start := time.Date(day.Year(), day.Month(), day.Day(), startHour, startMinute, 0, 0, day.Location())
+	end := time.Date(day.Year(), day.Month(), day.Day(), endHour, endMinute, 0, 0, day.Location())
+	if s.IsOvernight() {
+		end = end.AddDate(0, 0, 1)
+	}
+	return start, end
+}
+
+// HasBreak reports whether the shift is split by a break.
+func (s WorkSchedule) HasBreak() bool {
+	return s.BreakStart != "" && s.BreakEnd != ""
+}
+
+// BreakWindow returns the start and end of the break in the shift that begins
+// on the given day. Without a break both are the end of the shift, so the
+// break never overlaps any working time.
+func (s WorkSchedule) BreakWindow(day time.Time) (time.Time, time.Time) {
+	start, end := s.ShiftWindow(day)
+	if !s.HasBreak() {
+		return end, end
+	}
+	breakStartHour, breakStartMinute, _ := ParseClock(s.BreakStart)
+	breakEndHour, breakEndMinute, _ := ParseClock(s.BreakEnd)
+
+	from := time.Date(start.Year(), start.Month(), start.Day(), breakStartHour, breakStartMinute, 0, 0, start.Location())
+	if from.Before(start) {
+		from = from.AddDate(0, 0, 1)
+	}
+	to := time.Date(from.Year(), from.Month(), from.Day(), breakEndHour, breakEndMinute, 0, 0, from.Location())
+	if to.Before(from) {
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to
+}
+
+// BreakOverlap returns how much of the span from from to to falls within the
+// break of the shift that begins on the given day.
+func (s WorkSchedule) BreakOverlap(day, from, to time.Time) time.Duration {
+	breakStart, breakEnd := s.BreakWindow(day)
+	if from.Before(breakStart) {
+		from = breakStart
+	}
+	if to.After(breakEnd) {
+		to = breakEnd
+	}
+	if !to.After(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// WorkingDuration returns the working time of the shift that begins on the
+// given day, without its break.
+func (s WorkSchedule) WorkingDuration(day time.Time) time.Duration {
+	start, end := s.ShiftWindow(day)
+	return end.Sub(start) - s.BreakOverlap(day, start, end)
+}
+
+// AddWorkingTime returns the moment d of working time after t in the shift
+// that begins on the given day. Time from a moment within the break counts
+// from its end, and a span reaching the break start is extended by the break.
+func (s WorkSchedule) AddWorkingTime(day, t time.Time, d time.Duration) time.Time {
+	breakStart, breakEnd := s.BreakWindow(day)
+	if !t.Before(breakStart) && t.Before(breakEnd) {
+		return breakEnd.Add(d)
+	}
+	end := t.Add(d)
+	if t.Before(breakStart) && !end.Before(breakStart) {
+		end = end.Add(breakEnd.Sub(breakStart))
+	}
+	return end
+}
+
+// LateAfter returns the moment after which a check-in for the shift starting
+// on the given day is considered late. A grace period reaching the break runs
+// on after it.
+func (s WorkSchedule) LateAfter(day time.Time) time.Time {
+	start, _ := s.ShiftWindow(day)
+	return s.AddWorkingTime(day, start, time.Duration(s.GracePeriodMinutes)*time.Minute)
+}
+
+// ShiftDate returns the calendar day of the shift that the given moment belongs
+// to. For overnight shifts, the early hours before the previous shift's end
+// still belong to the previous day.
+func (s WorkSchedule) ShiftDate(t time.Time) time.Time {
+	today := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if s.IsOvernight() {
+		yesterday := today.AddDate(0, 0, -1)
+		if _, end := s.ShiftWindow(yesterday); t.Before(end) {
+			return yesterday
+		}
+	}
+	return today
+}
+
+// AttendanceWindow returns the range of check-in times that count as
+// attendance for the shift starting on the given day. Consecutive windows
+// never overlap, which keeps it consistent with ShiftDate. The window covers
+// the whole shift including its break, so a user who only works the second
+// segment of a split shift is not marked absent.
+func (s WorkSchedule) AttendanceWindow(day time.Time) (time.Time, time.Time) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	to := from.AddDate(0, 0, 1)
+	if _, previousEnd := s.ShiftWindow(from.AddDate(0, 0, -1)); previousEnd.After(from) {
+		from = previousEnd
+	}
+	if _, end := s.ShiftWindow(day); end.After(to) {
+		to = end
+	}
+	return from, to
+}
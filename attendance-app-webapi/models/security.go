@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Kinds of login throttles
+const (
+	ThrottleUsername = "USERNAME"
+	ThrottleIP       = "IP"
+)
+
+// LoginThrottle counts the failed logins of a username or an IP address.
+// After a few failures further attempts are delayed with exponential backoff,
+// and after too many the username or IP address is locked out for a while.
+type LoginThrottle struct {
+	ID            uint       `json:"ID" gorm:"primarykey"`
+	Kind          string     `json:"Kind" gorm:"type:varchar(20);not null;uniqueIndex:idx_login_throttle_key"`
+	Value         string     `json:"Value" gorm:"type:varchar(100);not null;uniqueIndex:idx_login_throttle_key"`
+	Failures      int        `json:"Failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"LastFailureAt" gorm:"index"`
+	BlockedUntil  *time.Time `json:"BlockedUntil,omitempty" gorm:"index"`
+	// Locked is true once the failures reached the lockout limit
+	Locked    bool      `json:"Locked" gorm:"not null;default:false"`
+	CreatedAt time.Time `json:"CreatedAt"`
+	UpdatedAt time.Time `json:"UpdatedAt"`
+}
+
+// Types of security events
+const (
+	SecurityLoginFailed     = "LOGIN_FAILED"
+	SecurityLoginBlocked    = "LOGIN_BLOCKED"
+	SecurityLockedOut       = "LOCKED_OUT"
+	SecurityLockoutCleared  = "LOCKOUT_CLEARED"
+	SecurityTwoFactorFailed = "TWO_FACTOR_FAILED"
+	SecurityLoginInactive   = "LOGIN_INACTIVE"
+)
+
+// SecurityEvent records a security relevant event such as a failed login
+type SecurityEvent struct {
+	ID   uint   `json:"ID" gorm:"primarykey"`
+	Type string `json:"Type" gorm:"type:varchar(50);not null;index"`
+	// Username that was tried, which may not exist
+	Username  string    `json:"Username" gorm:"type:varchar(100);index"`
+	UserID    *uint     `json:"UserID,omitempty" gorm:"index"`
+	IPAddress string    `json:"IPAddress" gorm:"type:varchar(45);index"`
+	UserAgent string    `json:"UserAgent" gorm:"type:varchar(255)"`
+	Detail    string    `json:"Detail" gorm:"type:varchar(255)"`
+	CreatedAt time.Time `json:"CreatedAt" gorm:"index"`
+}
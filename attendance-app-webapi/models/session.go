@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserSession is a login on one device. It lives as long as its refresh
+// tokens keep being rotated and ends when the user logs out or revokes it.
+type UserSession struct {
+	gorm.Model
+	UserID     uint      `json:"UserID" gorm:"not null;index"`
+	User       *User     `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	DeviceName string    `json:"DeviceName" gorm:"type:varchar(100)"`
+	UserAgent  string    `json:"UserAgent" gorm:"type:varchar(255)"`
+	IPAddress  string    `json:"IPAddress" gorm:"type:varchar(45)"`
+	LastUsedAt time.Time `json:"LastUsedAt"`
+	// ExpiresAt is the expiry of the current refresh token
+	ExpiresAt time.Time `json:"ExpiresAt" gorm:"index"`
+	// Latest access token issued for the session, revoked with the session
+	AccessTokenID        string     `json:"-" gorm:"type:varchar(64)"`
+	AccessTokenExpiresAt time.Time  `json:"-"`
+	RevokedAt            *time.Time `json:"RevokedAt,omitempty"`
+	RevokedReason        string     `json:"RevokedReason,omitempty" gorm:"type:varchar(50)"`
+}
+
+// Reasons for revoking a session
+const (
+	SessionLogout          = "LOGOUT"
+	SessionLogoutAll       = "LOGOUT_ALL"
+	SessionRevoked         = "REVOKED"
+	SessionTokenReused     = "TOKEN_REUSED"
+	SessionPasswordReset   = "PASSWORD_RESET"
+	SessionPasswordChanged = "PASSWORD_CHANGED"
+)
+
+// IsActive reports whether the session can still be refreshed
+func (s UserSession) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// RefreshToken is a single-use token that renews a session's access token.
+// Only its hash is stored. Using it rotates it for a new one; using it twice
+// means it leaked, and the whole session is revoked.
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint         `json:"SessionID" gorm:"not null;index"`
+	Session   *UserSession `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	TokenHash string       `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	ExpiresAt time.Time    `json:"ExpiresAt"`
+	// UsedAt is set when the token is exchanged for its replacement
+	UsedAt       *time.Time `json:"UsedAt,omitempty"`
+	ReplacedByID *uint      `json:"ReplacedByID,omitempty"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a single-use code to log in when the authenticator app is
+// unavailable. Only its hash is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"ID" gorm:"primarykey"`
+	UserID    uint       `json:"UserID" gorm:"not null;index"`
+	User      *User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	CodeHash  string     `json:"-" gorm:"type:varchar(64);not null;index"`
+	UsedAt    *time.Time `json:"UsedAt,omitempty"`
+	CreatedAt time.Time  `json:"CreatedAt"`
+}
+
+// LoginChallenge is the intermediate step of a login with two-factor
+// authentication: the password was correct and the challenge token is
+// exchanged together with a TOTP or recovery code for the session tokens.
+type LoginChallenge struct {
+	gorm.Model
+	UserID     uint       `json:"UserID" gorm:"not null;index"`
+	User       *User      `json:"-" gorm:"constraint:OnUpdate:CASCADE,OnDelete:CASCADE;"`
+	TokenHash  string     `json:"-" gorm:"type:varchar(64);uniqueIndex;not null"`
+	DeviceName string     `json:"DeviceName" gorm:"type:varchar(100)"`
+	ExpiresAt  time.Time  `json:"ExpiresAt" gorm:"index"`
+	UsedAt     *time.Time `json:"UsedAt,omitempty"`
+	// Failed codes entered for the challenge
+	Attempts int `json:"Attempts" gorm:"not null;default:0"`
+}
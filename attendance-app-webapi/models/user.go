@@ -16,6 +16,33 @@ type User struct {
 	RoleID   uint
 	Role     *Role `json:"Role" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
 
+	// Incremented to invalidate every token issued to the user, e.g. to log out all sessions
+	TokenVersion uint `json:"-" gorm:"not null;default:0"`
+
+	// Two-factor authentication (TOTP). The secret is set on setup and only
+	// used for login once enabled; TOTPLastCounter rejects reused codes.
+	TOTPEnabled     bool   `json:"TOTPEnabled" gorm:"not null;default:false"`
+	TOTPSecret      string `json:"-" gorm:"type:varchar(64)"`
+	TOTPLastCounter int64  `json:"-" gorm:"not null;default:0"`
+
+	// Employment status. A suspended or terminated status applies from
+	// EmploymentStatusFrom until EmploymentStatusUntil, each open ended when
+	// unset; outside that period the user is active. See IsActive.
+	EmploymentStatus      EmploymentStatus `json:"EmploymentStatus" gorm:"type:varchar(20);not null;default:'active';index"`
+	EmploymentStatusFrom  *time.Time       `json:"EmploymentStatusFrom,omitempty"`
+	EmploymentStatusUntil *time.Time       `json:"EmploymentStatusUntil,omitempty"`
+
+	// Work schedule assigned directly to the user, overrides the role's schedule
+	WorkScheduleID *uint         `json:"WorkScheduleID,omitempty"`
+	WorkSchedule   *WorkSchedule `json:"WorkSchedule,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// Locations where the user may check in, in addition to the role's locations
+	Locations []Location `json:"Locations,omitempty" gorm:"many2many:user_locations;"`
+
+	// Department the user belongs to
+	DepartmentID *uint       `json:"DepartmentID,omitempty" gorm:"index"`
+	Department   *Department `json:"Department,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
 	// Supervisor/Subordinate Relationship (Corrected)
 	// The constraint is defined here as the primary direction of the relationship.
 	SupervisorID *uint  `json:"SupervisorID,omitempty"`
@@ -27,6 +54,34 @@ type User struct {
 	LeaveRequests []LeaveRequest `json:"LeaveRequests,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// EmploymentStatus is the employment status of a user
+type EmploymentStatus string
+
+const (
+	EmploymentActive     EmploymentStatus = "active"
+	EmploymentSuspended  EmploymentStatus = "suspended"
+	EmploymentTerminated EmploymentStatus = "terminated"
+)
+
+// IsActive reports whether the user is employed and not suspended at the
+// given time. Inactive users cannot log in and are left out of absence
+// marking and reminders.
+func (u User) IsActive(now time.Time) bool {
+	if u.EmploymentStatus == "" || u.EmploymentStatus == EmploymentActive {
+		return true
+	}
+	if u.EmploymentStatusFrom != nil && now.Before(*u.EmploymentStatusFrom) {
+		return true
+	}
+	return u.EmploymentStatusUntil != nil && !now.Before(*u.EmploymentStatusUntil)
+}
+
+// ReportingPathEntry is a user on the reporting line from a supervisor down to a subordinate
+type ReportingPathEntry struct {
+	ID   uint   `json:"ID"`
+	Name string `json:"Name"`
+}
+
 type RoleName string
 
 const (
@@ -49,4 +104,14 @@ type Role struct {
 	Position string `json:"Position" validate:"required" gorm:"type:varchar(255);uniqueIndex:idx_role_position_deleted"`
 
 	PositionLevel uint `json:"PositionLevel" validate:"gte=0"`
+
+	// Work schedule shared by every user holding this role
+	WorkScheduleID *uint         `json:"WorkScheduleID,omitempty"`
+	WorkSchedule   *WorkSchedule `json:"WorkSchedule,omitempty" gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL;"`
+
+	// Locations where every user holding this role may check in
+	Locations []Location `json:"Locations,omitempty" gorm:"many2many:role_locations;"`
+
+	// Permissions granted to every user holding this role
+	Permissions []Permission `json:"Permissions,omitempty" gorm:"many2many:role_permissions;"`
 }
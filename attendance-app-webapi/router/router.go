@@ -2,10 +2,18 @@ package router
 
 import (
 	"attendance-app/handlers"
+	"attendance-app/handlers/apikeys"
 	"attendance-app/handlers/attendance"
+	"attendance-app/handlers/auditlogs"
+	"attendance-app/handlers/calendar"
+	"attendance-app/handlers/delegations"
+	"attendance-app/handlers/departments"
 	emailHandler "attendance-app/handlers/email"
 	"attendance-app/handlers/leave"
 	"attendance-app/handlers/locations"
+	"attendance-app/handlers/loginsecurity"
+	"attendance-app/handlers/reports"
+	"attendance-app/handlers/schedules"
 	"attendance-app/handlers/settings"
 	UserManagement "attendance-app/handlers/userManagement"
 	"attendance-app/middleware"
@@ -35,7 +43,7 @@ func SetupRouter(DB *gorm.DB) *gin.Engine {
 		"https://cluster-gotten-sciences-marathon.trycloudflare.com", // Cloudflared tunnel
 	}
 	config.AllowMethods = []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"}
-	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization"}
+	config.AllowHeaders = []string{"Origin", "Content-Type", "Authorization", "X-API-Key"}
 	config.AllowCredentials = true
 	config.ExposeHeaders = []string{"Content-Length"}
 	router.Use(cors.New(config))
@@ -53,60 +61,181 @@ func SetupRouter(DB *gorm.DB) *gin.Engine {
 		})
 
 		api.POST("/login", handlers.Login)
+		api.POST("/login/2fa", handlers.LoginTwoFactor)
+		api.POST("/refresh", handlers.Refresh)
+		api.POST("/password/forgot", handlers.ForgotPassword)
+		api.POST("/password/reset", handlers.ResetPassword)
+		api.POST("/email/verify", handlers.VerifyEmail)
 
 		// Auth required routes
 		auth := api.Group("")
 		auth.Use(middleware.AuthMiddleware())
 		{
 			auth.POST("/logout", handlers.Logout)
+			auth.POST("/logout/all", handlers.LogoutAll)
 
-			// Admin-only routes
+			// Admin routes, each requiring a permission of the user's role
 			admin := auth.Group("/admin")
-			admin.Use(middleware.RoleMiddleware(models.RoleAdmin))
+			admin.Use(middleware.PermissionMiddleware(), middleware.TwoFactorMiddleware())
 			{
-				// Settings endpoints (admin only - write operations)
+				// Settings endpoints (write operations)
 				adminSettings := admin.Group("/settings")
+				adminSettings.Use(middleware.RequirePermission(models.PermSettingsManage))
 				{
 					adminSettings.PUT("", settings.UpdateSettings)
 				}
 
 				// Locations endpoints
 				adminLocations := admin.Group("/locations")
+				adminLocations.Use(middleware.RequireAccess(models.PermLocationsRead, models.PermLocationsManage))
 				{
 					adminLocations.GET("", locations.GetAllLocations)
 					adminLocations.GET("/:id", locations.GetLocationByID)
 					adminLocations.POST("", locations.CreateLocation)
 					adminLocations.PUT("/:id", locations.UpdateLocation)
 					adminLocations.DELETE("/:id", locations.DeleteLocation)
+					adminLocations.GET("/:id/assignments", locations.GetLocationAssignments)
+					adminLocations.POST("/:id/assign", locations.AssignLocation)
+					adminLocations.POST("/:id/unassign", locations.UnassignLocation)
+				}
+
+				// Department endpoints
+				adminDepartments := admin.Group("/departments")
+				adminDepartments.Use(middleware.RequireAccess(models.PermDepartmentsRead, models.PermDepartmentsManage))
+				{
+					adminDepartments.GET("", departments.GetDepartments)
+					adminDepartments.GET("/:id", departments.GetDepartmentByID)
+					adminDepartments.POST("", departments.CreateDepartment)
+					adminDepartments.PUT("/:id", departments.UpdateDepartment)
+					adminDepartments.DELETE("/:id", departments.DeleteDepartment)
+					adminDepartments.GET("/:id/members", departments.GetDepartmentMembers)
+					adminDepartments.POST("/:id/assign", departments.AssignDepartment)
+					adminDepartments.POST("/unassign", departments.UnassignDepartment)
+				}
+
+				// Work schedule endpoints
+				adminSchedules := admin.Group("/schedules")
+				adminSchedules.Use(middleware.RequireAccess(models.PermSchedulesRead, models.PermSchedulesManage))
+				{
+					adminSchedules.GET("", schedules.GetAllSchedules)
+					adminSchedules.GET("/:id", schedules.GetScheduleByID)
+					adminSchedules.POST("", schedules.CreateSchedule)
+					adminSchedules.PUT("/:id", schedules.UpdateSchedule)
+					adminSchedules.DELETE("/:id", schedules.DeleteSchedule)
+					adminSchedules.POST("/:id/assign", schedules.AssignSchedule)
+					adminSchedules.POST("/unassign", schedules.UnassignSchedule)
+				}
+
+				// Company calendar endpoints (holidays and working day overrides)
+				adminCalendar := admin.Group("/calendar")
+				adminCalendar.Use(middleware.RequireAccess(models.PermCalendarRead, models.PermCalendarManage))
+				{
+					adminCalendar.GET("", calendar.GetCalendarDays)
+					adminCalendar.POST("", calendar.CreateCalendarDay)
+					adminCalendar.POST("/import", calendar.ImportCalendar)
+					adminCalendar.GET("/:id", calendar.GetCalendarDayByID)
+					adminCalendar.PUT("/:id", calendar.UpdateCalendarDay)
+					adminCalendar.DELETE("/:id", calendar.DeleteCalendarDay)
+				}
+
+				// Audit log endpoint
+				admin.GET("/audit-logs", middleware.RequirePermission(models.PermAuditRead), auditlogs.GetAuditLogs)
+
+				// Login security endpoints - failed logins and lockouts
+				admin.GET("/security-events", middleware.RequirePermission(models.PermSecurityRead, models.PermSecurityManage), loginsecurity.GetSecurityEvents)
+				adminLockouts := admin.Group("/lockouts")
+				adminLockouts.Use(middleware.RequireAccess(models.PermSecurityRead, models.PermSecurityManage))
+				{
+					adminLockouts.GET("", loginsecurity.GetLockouts)
+					adminLockouts.DELETE("/:id", loginsecurity.ClearLockout)
+				}
+
+				// API key endpoints
+				adminAPIKeys := admin.Group("/api-keys")
+				adminAPIKeys.Use(middleware.RequirePermission(models.PermAPIKeysManage))
+				{
+					adminAPIKeys.GET("", apikeys.GetAPIKeys)
+					adminAPIKeys.POST("", apikeys.CreateAPIKey)
+					adminAPIKeys.DELETE("/:id", apikeys.RevokeAPIKey)
+				}
+
+				// Approval delegation endpoints
+				adminDelegations := admin.Group("/delegations")
+				adminDelegations.Use(middleware.RequireAccess(models.PermDelegationsRead, models.PermDelegationsManage))
+				{
+					adminDelegations.GET("", delegations.GetDelegations)
+					adminDelegations.POST("", delegations.CreateDelegation)
+					adminDelegations.DELETE("/:id", delegations.RevokeDelegation)
+				}
+
+				// Leave configuration endpoints (types, entitlements and balance adjustments)
+				adminLeave := admin.Group("/leave")
+				adminLeave.Use(middleware.RequireAccess(models.PermLeaveRead, models.PermLeaveManage))
+				{
+					adminLeave.GET("/types", leave.GetAllLeaveTypes)
+					adminLeave.POST("/types", leave.CreateLeaveType)
+					adminLeave.PUT("/types/:id", leave.UpdateLeaveType)
+					adminLeave.DELETE("/types/:id", leave.DeleteLeaveType)
+					adminLeave.GET("/entitlements", leave.GetLeaveEntitlements)
+					adminLeave.POST("/entitlements", leave.CreateLeaveEntitlement)
+					adminLeave.PUT("/entitlements/:id", leave.UpdateLeaveEntitlement)
+					adminLeave.DELETE("/entitlements/:id", leave.DeleteLeaveEntitlement)
+					adminLeave.POST("/adjustments", leave.CreateLeaveAdjustment)
+					adminLeave.GET("/balances/:userId", leave.GetUserLeaveBalances)
+					adminLeave.GET("/approval-rules", leave.GetApprovalChainRules)
+					adminLeave.POST("/approval-rules", leave.CreateApprovalChainRule)
+					adminLeave.PUT("/approval-rules/:id", leave.UpdateApprovalChainRule)
+					adminLeave.DELETE("/approval-rules/:id", leave.DeleteApprovalChainRule)
 				}
 
 				// Email endpoints (testing and manual sending)
 				adminEmail := admin.Group("/email")
+				adminEmail.Use(middleware.RequirePermission(models.PermEmailSend))
 				{
 					adminEmail.POST("/test", emailHandler.TestEmail)
 					adminEmail.POST("/send-reminder", emailHandler.SendReminderToAll)
 					adminEmail.GET("/scheduler-status", emailHandler.GetSchedulerStatus)
 				}
 
+				// Monthly attendance summaries
+				adminReports := admin.Group("/reports")
+				{
+					adminReports.GET("/monthly", middleware.RequirePermission(models.PermReportsRead, models.PermReportsExport), reports.GetMonthlySummaries)
+					adminReports.GET("/monthly/export/excel", middleware.RequirePermission(models.PermReportsExport), reports.ExportMonthlySummariesToExcel)
+				}
+
+				// Permissions that can be granted to roles
+				admin.GET("/permissions", middleware.RequirePermission(models.PermRolesRead, models.PermRolesManage), UserManagement.GetPermissions)
+
+				usersRead := middleware.RequirePermission(models.PermUsersRead, models.PermUsersManage)
+				usersManage := middleware.RequirePermission(models.PermUsersManage)
+				rolesRead := middleware.RequirePermission(models.PermRolesRead, models.PermRolesManage)
+				rolesManage := middleware.RequirePermission(models.PermRolesManage)
+				reportsExport := middleware.RequirePermission(models.PermReportsExport)
+
 				users := admin.Group("/users")
 				{
-					users.GET("", UserManagement.GetAllUsers)
-					users.POST("/", UserManagement.CreateUser)
-					users.GET("/export/excel", UserManagement.ExportUsersToExcel)
-					users.GET("/:id", UserManagement.GetUser)
-					users.PUT("/:id", UserManagement.UpdateUser)
-					users.DELETE("/:id", UserManagement.DeleteUser)
-					users.GET("/subordinates", UserManagement.GetUserSubordinates)
+					users.GET("", usersRead, UserManagement.GetAllUsers)
+					users.POST("/", usersManage, UserManagement.CreateUser)
+					users.POST("/import", usersManage, UserManagement.ImportUsers)
+					users.GET("/export/excel", reportsExport, UserManagement.ExportUsersToExcel)
+					users.GET("/:id", usersRead, UserManagement.GetUser)
+					users.PUT("/:id", usersManage, UserManagement.UpdateUser)
+					users.DELETE("/:id", usersManage, UserManagement.DeleteUser)
+					users.PUT("/:id/employment-status", usersManage, UserManagement.UpdateEmploymentStatus)
+					users.DELETE("/:id/2fa", middleware.RequirePermission(models.PermSecurityManage), handlers.ResetUserTwoFactor)
+					users.GET("/subordinates", usersRead, UserManagement.GetUserSubordinates)
 
 					roles := users.Group("/roles")
 					{
-						roles.GET("", UserManagement.GetRoles)
-						roles.GET("/export/excel", UserManagement.ExportRolesToExcel)
-						roles.POST("", UserManagement.CreateRole)
-						roles.PUT("/:id", UserManagement.UpdateRole)
-						roles.DELETE("/:id", UserManagement.DeleteRole)
-						roles.GET("/admins", UserManagement.GetRolesAdmins)
-						roles.GET("/non-admins", UserManagement.GetRolesNonAdmins)
+						roles.GET("", rolesRead, UserManagement.GetRoles)
+						roles.GET("/export/excel", reportsExport, UserManagement.ExportRolesToExcel)
+						roles.POST("", rolesManage, UserManagement.CreateRole)
+						roles.PUT("/:id", rolesManage, UserManagement.UpdateRole)
+						roles.PUT("/:id/permissions", rolesManage, UserManagement.UpdateRolePermissions)
+						roles.DELETE("/:id", rolesManage, UserManagement.DeleteRole)
+						roles.GET("/admins", rolesRead, UserManagement.GetRolesAdmins)
+						roles.GET("/non-admins", rolesRead, UserManagement.GetRolesNonAdmins)
 					}
 				}
 			}
@@ -115,12 +244,52 @@ func SetupRouter(DB *gorm.DB) *gin.Engine {
 			user := auth.Group("/user")
 			user.Use(middleware.RoleMiddleware(models.RoleUser, models.RoleAdmin))
 			{
-				// Profile endpoint - get current user's profile
+				// Profile endpoints - get and update current user's profile and password
 				user.GET("/profile", UserManagement.GetMyProfile)
+				user.PUT("/profile", handlers.UpdateMyProfile)
+				user.POST("/password", handlers.ChangeMyPassword)
+
+				// Work schedule endpoint - get current user's work schedule
+				user.GET("/schedule", schedules.GetMySchedule)
+
+				// Calendar endpoint - get working days and holidays for the current user
+				user.GET("/calendar", calendar.GetMyCalendar)
+
+				// Locations endpoint - get locations where the current user may check in
+				user.GET("/locations", locations.GetMyLocations)
 
 				// Subordinates endpoint - get current user's subordinates
 				user.GET("/subordinates", UserManagement.GetUserSubordinates)
 
+				// Department endpoints - departments headed by the current user and their members
+				user.GET("/department", departments.GetMyDepartments)
+				user.GET("/department/members", departments.GetMyDepartmentMembers)
+
+				// Delegation endpoints - delegate the current user's approval rights
+				userDelegations := user.Group("/delegations")
+				{
+					userDelegations.GET("", delegations.GetMyDelegations)
+					userDelegations.POST("", delegations.CreateMyDelegation)
+					userDelegations.DELETE("/:id", delegations.RevokeMyDelegation)
+				}
+
+				// Session endpoints - list and revoke the current user's logins
+				userSessions := user.Group("/sessions")
+				{
+					userSessions.GET("", handlers.GetMySessions)
+					userSessions.DELETE("/:id", handlers.RevokeMySession)
+				}
+
+				// Two-factor authentication endpoints - set up TOTP and recovery codes
+				userTwoFactor := user.Group("/2fa")
+				{
+					userTwoFactor.GET("", handlers.GetMyTwoFactor)
+					userTwoFactor.POST("/setup", handlers.SetupMyTwoFactor)
+					userTwoFactor.POST("/enable", handlers.EnableMyTwoFactor)
+					userTwoFactor.POST("/disable", handlers.DisableMyTwoFactor)
+					userTwoFactor.POST("/recovery-codes", handlers.RegenerateMyRecoveryCodes)
+				}
+
 				// Settings endpoints (read-only for all users)
 				userSettings := user.Group("/settings")
 				{
@@ -135,11 +304,20 @@ func SetupRouter(DB *gorm.DB) *gin.Engine {
 					attendances.POST("/check-out", attendance.CheckOut)
 					attendances.GET("/my-records", attendance.GetMyAttendanceRecords)
 					attendances.GET("/export/excel", attendance.ExportMyAttendanceToExcel)
+					attendances.POST("/corrections", attendance.SubmitAttendanceCorrection)
+					attendances.GET("/corrections/my-requests", attendance.GetMyAttendanceCorrections)
 
 					// Supervisor-only endpoints
 					attendances.GET("/subordinates", attendance.GetSubordinateAttendanceRecords)
 					attendances.GET("/subordinates/export/excel", attendance.ExportSubordinateAttendanceToExcel)
 					attendances.PUT("/update/:id", attendance.UpdateSubordinateAttendanceRecord)
+					attendances.GET("/corrections/subordinates", attendance.GetSubordinateAttendanceCorrections)
+					attendances.PUT("/corrections/validate/:id", attendance.ValidateAttendanceCorrection)
+
+					// Department head endpoints
+					attendances.GET("/department", attendance.GetDepartmentAttendanceRecords)
+					attendances.GET("/department/export/excel", attendance.ExportDepartmentAttendanceToExcel)
+					attendances.GET("/corrections/department", attendance.GetDepartmentAttendanceCorrections)
 				}
 
 				// Leave request endpoints
@@ -147,12 +325,32 @@ func SetupRouter(DB *gorm.DB) *gin.Engine {
 				{
 					leaves.POST("", leave.SubmitLeaveRequest)
 					leaves.GET("/my-requests", leave.GetMyLeaveRequests)
+					leaves.GET("/balances", leave.GetMyLeaveBalances)
+					leaves.GET("/types", leave.GetActiveLeaveTypes)
 					leaves.GET("/export/excel", leave.ExportMyLeaveRequestsToExcel)
+					leaves.PUT("/cancel/:id", leave.CancelLeaveRequest)
 
 					// Supervisor-only endpoints
 					leaves.GET("/subordinates", leave.GetSubordinateLeaveRequests)
+					leaves.GET("/approvals", leave.GetPendingApprovals)
 					leaves.GET("/subordinates/export/excel", leave.ExportSubordinateLeaveRequestsToExcel)
 					leaves.PUT("/validate/:id", leave.ValidateLeaveRequest)
+					leaves.PUT("/cancellation/:id", leave.ValidateLeaveCancellation)
+
+					// Department head endpoints
+					leaves.GET("/department", leave.GetDepartmentLeaveRequests)
+					leaves.GET("/department/export/excel", leave.ExportDepartmentLeaveRequestsToExcel)
+				}
+
+				// Monthly attendance summary endpoints
+				userReports := user.Group("/reports")
+				{
+					userReports.GET("/monthly", reports.GetMyMonthlySummary)
+					userReports.GET("/monthly/export/excel", reports.ExportMyMonthlySummaryToExcel)
+
+					// Supervisor-only endpoints
+					userReports.GET("/monthly/subordinates", reports.GetSubordinateMonthlySummaries)
+					userReports.GET("/monthly/subordinates/export/excel", reports.ExportSubordinateMonthlySummariesToExcel)
 				}
 			}
 		}
@@ -2,6 +2,8 @@ package scheduler
 
 import (
 	"attendance-app/models"
+	"attendance-app/utils"
+	"fmt"
 	"log"
 	"time"
 
@@ -22,10 +24,11 @@ func NewAttendanceScheduler(db *gorm.DB) *AttendanceScheduler {
 }
 
 func (s *AttendanceScheduler) Start() {
-	// Schedule marking absent and didn't checkout at 17:00 every day
+	// Shifts end at different times, so check every 15 minutes for shifts that
+	// have finished and mark absent and didn't checkout records accordingly
 	// NOTE: We no longer auto-create pending records at midnight
 	// Users must manually check-in, which creates PRESENT status by default
-	s.cron.AddFunc("0 17 * * *", func() {
+	s.cron.AddFunc("*/15 * * * *", func() {
 		s.markAbsentRecords()
 		s.markDidntCheckout()
 	})
@@ -95,50 +98,105 @@ func (s *AttendanceScheduler) createPendingRecords() {
 	log.Printf("Successfully created pending attendance records for %d users", len(users))
 }
 
+// finishedShifts returns the working days of the schedule whose shift has
+// ended by now. Overnight shifts that started yesterday end today, so
+// yesterday is only considered for those. Holidays in the calendar are skipped.
+func finishedShifts(schedule models.WorkSchedule, calendar *utils.WorkingCalendar, now time.Time) []time.Time {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	candidates := []time.Time{today}
+	if schedule.IsOvernight() {
+		candidates = []time.Time{today.AddDate(0, 0, -1), today}
+	}
+
+	var days []time.Time
+	for _, day := range candidates {
+		if !calendar.IsWorkingDay(schedule, day) {
+			continue
+		}
+		if _, end := schedule.ShiftWindow(day); now.Before(end) {
+			continue
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+// loadRecentCalendar loads the calendar days that finishedShifts may consider
+func loadRecentCalendar(db *gorm.DB, now time.Time) (*utils.WorkingCalendar, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return utils.LoadWorkingCalendar(db, today.AddDate(0, 0, -1), today)
+}
+
 func (s *AttendanceScheduler) markAbsentRecords() {
 	now := time.Now()
 
-	// Mark users who didn't check-in at all today as ABSENT
-	// We need to create records for users who have no attendance today
+	// Mark users who didn't check-in at all during a finished shift as ABSENT
+	// We need to create records for users who have no attendance for that shift
+	// Suspended and terminated users are not expected to check in
 	var users []models.User
-	if err := s.db.Find(&users).Error; err != nil {
+	if err := utils.WithWorkSchedules(utils.WhereUserActive(s.db, now)).Find(&users).Error; err != nil {
 		log.Printf("Error fetching users: %v", err)
 		return
 	}
+	defaultSchedule, err := utils.GetDefaultWorkSchedule(s.db)
+	if err != nil {
+		log.Printf("Error loading default work schedule: %v", err)
+		return
+	}
+
+	calendar, err := loadRecentCalendar(s.db, now)
+	if err != nil {
+		log.Printf("Error loading calendar: %v", err)
+		return
+	}
 
 	tx := s.db.Begin()
 	absentCount := 0
 
 	for _, user := range users {
-		// Check if user has any attendance record for today
-		var exists bool
-		err := tx.Model(&models.Attendance{}).
-			Where("user_id = ? AND DATE(check_in_time) = DATE(?)", user.ID, now).
-			Select("1").
-			Limit(1).
-			Scan(&exists).Error
+		schedule := utils.UserWorkSchedule(user, defaultSchedule)
 
-		if err != nil {
-			log.Printf("Error checking attendance for user %d: %v", user.ID, err)
-			continue
-		}
+		for _, day := range finishedShifts(schedule, calendar, now) {
+			windowStart, windowEnd := schedule.AttendanceWindow(day)
 
-		// If no record exists, create an ABSENT record
-		if !exists {
-			checkInTime := time.Date(now.Year(), now.Month(), now.Day(), 7, 30, 0, 0, now.Location())
-			attendance := models.Attendance{
-				UserID:           user.ID,
-				CheckInTime:      &checkInTime,
-				Status:           "ABSENT",
-				ValidationStatus: models.Absent,
-				Notes:            "Automatically marked as absent - no check-in recorded",
-			}
+			// Check if user has any attendance record for this shift
+			var exists bool
+			err := tx.Model(&models.Attendance{}).
+				Where("user_id = ? AND check_in_time >= ? AND check_in_time < ?", user.ID, windowStart, windowEnd).
+				Select("1").
+				Limit(1).
+				Scan(&exists).Error
 
-			if err := tx.Create(&attendance).Error; err != nil {
-				log.Printf("Error creating absent record for user %d: %v", user.ID, err)
+			if err != nil {
+				log.Printf("Error checking attendance for user %d: %v", user.ID, err)
 				continue
 			}
-			absentCount++
+
+			// If no record exists, create an ABSENT record at the shift start
+			if !exists {
+				checkInTime, _ := schedule.ShiftWindow(day)
+				attendance := models.Attendance{
+					UserID:           user.ID,
+					CheckInTime:      &checkInTime,
+					Status:           "ABSENT",
+					ValidationStatus: models.Absent,
+					Notes:            "Automatically marked as absent - no check-in recorded",
+				}
+
+				// Partial-day leave only excuses part of the shift, keep it linked to the record
+				partialLeaves, err := utils.GetApprovedPartialLeaves(tx, user.ID, day)
+				if err != nil {
+					log.Printf("Error checking partial leave for user %d: %v", user.ID, err)
+				} else if len(partialLeaves) > 0 {
+					attendance.LeaveRequestID = &partialLeaves[0].ID
+				}
+
+				if err := tx.Create(&attendance).Error; err != nil {
+					log.Printf("Error creating absent record for user %d: %v", user.ID, err)
+					continue
+				}
+				absentCount++
+			}
 		}
 	}
 
@@ -148,24 +206,56 @@ func (s *AttendanceScheduler) markAbsentRecords() {
 		return
 	}
 
-	log.Printf("Marked %d users as absent (no check-in)", absentCount)
+	if absentCount > 0 {
+		log.Printf("Marked %d users as absent (no check-in)", absentCount)
+	}
 }
 
 func (s *AttendanceScheduler) markDidntCheckout() {
 	now := time.Now()
 
-	// Update all present records without checkout to didn't checkout
-	result := s.db.Model(&models.Attendance{}).
-		Where("DATE(check_in_time) = DATE(?) AND validation_status = ? AND check_out_time IS NULL", now, models.Present).
-		Updates(map[string]interface{}{
-			"validation_status": models.DidntCheckout,
-			"notes":             "Automatically marked as didn't checkout at 17:00",
-		})
+	var users []models.User
+	if err := utils.WithWorkSchedules(s.db).Find(&users).Error; err != nil {
+		log.Printf("Error fetching users: %v", err)
+		return
+	}
+	defaultSchedule, err := utils.GetDefaultWorkSchedule(s.db)
+	if err != nil {
+		log.Printf("Error loading default work schedule: %v", err)
+		return
+	}
 
-	if result.Error != nil {
-		log.Printf("Error marking didn't checkout records: %v", result.Error)
+	calendar, err := loadRecentCalendar(s.db, now)
+	if err != nil {
+		log.Printf("Error loading calendar: %v", err)
 		return
 	}
 
-	log.Printf("Marked %d records as didn't checkout", result.RowsAffected)
+	var markedCount int64
+	for _, user := range users {
+		schedule := utils.UserWorkSchedule(user, defaultSchedule)
+
+		for _, day := range finishedShifts(schedule, calendar, now) {
+			windowStart, windowEnd := schedule.AttendanceWindow(day)
+
+			// Update present records without checkout after the shift ended to didn't checkout
+			result := s.db.Model(&models.Attendance{}).
+				Where("user_id = ? AND check_in_time >= ? AND check_in_time < ? AND validation_status = ? AND check_out_time IS NULL",
+					user.ID, windowStart, windowEnd, models.Present).
+				Updates(map[string]interface{}{
+					"validation_status": models.DidntCheckout,
+					"notes":             fmt.Sprintf("Automatically marked as didn't checkout at end of shift (%s)", schedule.EndTime),
+				})
+
+			if result.Error != nil {
+				log.Printf("Error marking didn't checkout records for user %d: %v", user.ID, result.Error)
+				continue
+			}
+			markedCount += result.RowsAffected
+		}
+	}
+
+	if markedCount > 0 {
+		log.Printf("Marked %d records as didn't checkout", markedCount)
+	}
 }
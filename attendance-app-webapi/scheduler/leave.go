@@ -0,0 +1,50 @@
+package scheduler
+
+import (
+	"attendance-app/utils"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+type LeaveScheduler struct {
+	db   *gorm.DB
+	cron *cron.Cron
+}
+
+func NewLeaveScheduler(db *gorm.DB) *LeaveScheduler {
+	return &LeaveScheduler{
+		db:   db,
+		cron: cron.New(cron.WithLocation(time.Local)),
+	}
+}
+
+func (s *LeaveScheduler) Start() {
+	// Post accruals shortly after midnight so monthly and yearly credits are
+	// available on the first day of the period. Posting is idempotent, so it
+	// also runs once at startup to catch up after downtime.
+	s.cron.AddFunc("5 0 * * *", s.postLeaveAccruals)
+	go s.postLeaveAccruals()
+
+	s.cron.Start()
+}
+
+func (s *LeaveScheduler) Stop() {
+	s.cron.Stop()
+}
+
+func (s *LeaveScheduler) postLeaveAccruals() {
+	tx := s.db.Begin()
+	if err := utils.ProcessLeaveAccruals(tx, time.Now()); err != nil {
+		log.Printf("Error posting leave accruals: %v", err)
+		tx.Rollback()
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Printf("Error committing leave accruals: %v", err)
+		return
+	}
+}
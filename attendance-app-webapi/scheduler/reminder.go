@@ -2,6 +2,7 @@ package scheduler
 
 import (
 	"attendance-app/models"
+	"attendance-app/utils"
 	"attendance-app/utils/email"
 	"log"
 	"time"
@@ -85,9 +86,8 @@ func (s *ReminderScheduler) Stop() {
 func (s *ReminderScheduler) getAllUserEmails() ([]string, error) {
 	var users []models.User
 
-	// Query all users with non-empty email addresses
-	// You can add additional filters here (e.g., active users only)
-	if err := s.db.Where("email IS NOT NULL AND email != ''").Find(&users).Error; err != nil {
+	// Query all active users with non-empty email addresses
+	if err := utils.WhereUserActive(s.db, time.Now()).Where("email IS NOT NULL AND email != ''").Find(&users).Error; err != nil {
 		log.Printf("Error fetching user emails: %v", err)
 		return nil, err
 	}
@@ -103,8 +103,25 @@ func (s *ReminderScheduler) getAllUserEmails() ([]string, error) {
 	return emails, nil
 }
 
+// isHoliday reports whether today is a holiday in the company calendar
+func (s *ReminderScheduler) isHoliday() bool {
+	now := time.Now()
+	calendar, err := utils.LoadWorkingCalendar(s.db, now, now)
+	if err != nil {
+		log.Printf("Error loading calendar: %v", err)
+		return false
+	}
+	day, ok := calendar.Day(now)
+	return ok && !day.IsWorkingDay()
+}
+
 // sendClockInReminder sends morning clock-in reminder to all users
 func (s *ReminderScheduler) sendClockInReminder() {
+	if s.isHoliday() {
+		log.Println("Skipping clock-in reminder on a holiday")
+		return
+	}
+
 	// Get all user emails
 	emails, err := s.getAllUserEmails()
 	if err != nil {
@@ -130,6 +147,11 @@ func (s *ReminderScheduler) sendClockInReminder() {
 
 // sendClockOutReminder sends evening clock-out reminder to all users
 func (s *ReminderScheduler) sendClockOutReminder() {
+	if s.isHoliday() {
+		log.Println("Skipping clock-out reminder on a holiday")
+		return
+	}
+
 	// Get all user emails
 	emails, err := s.getAllUserEmails()
 	if err != nil {
@@ -0,0 +1,132 @@
+package scheduler
+
+import (
+	"attendance-app/blocklist"
+	"attendance-app/models"
+	"attendance-app/security"
+	"attendance-app/utils"
+	"log"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+)
+
+type TokenScheduler struct {
+	db   *gorm.DB
+	cron *cron.Cron
+}
+
+func NewTokenScheduler(db *gorm.DB) *TokenScheduler {
+	return &TokenScheduler{
+		db:   db,
+		cron: cron.New(cron.WithLocation(time.Local)),
+	}
+}
+
+func (s *TokenScheduler) Start() {
+	// Revoked tokens only need to be kept until they expire
+	s.cron.AddFunc("0 * * * *", s.purgeRevokedTokens)
+	s.cron.AddFunc("15 * * * *", s.purgeEmailTokens)
+	s.cron.AddFunc("20 * * * *", s.purgeSessions)
+	s.cron.AddFunc("30 * * * *", s.purgeRateLimitEvents)
+	s.cron.AddFunc("45 * * * *", s.purgeLoginThrottles)
+	s.cron.AddFunc("50 * * * *", s.purgeLoginChallenges)
+
+	s.cron.Start()
+}
+
+func (s *TokenScheduler) Stop() {
+	s.cron.Stop()
+}
+
+func (s *TokenScheduler) purgeRevokedTokens() {
+	purged, err := blocklist.Purge()
+	if err != nil {
+		log.Printf("Error purging revoked tokens: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d expired revoked tokens", purged)
+	}
+}
+
+func (s *TokenScheduler) purgeSessions() {
+	// A session is finished once its refresh token expired, or once it was
+	// revoked and its last access token expired as well
+	now := time.Now()
+	finished := s.db.Unscoped().Model(&models.UserSession{}).Select("id").
+		Where("expires_at < ? OR (revoked_at IS NOT NULL AND access_token_expires_at < ?)", now, now)
+
+	result := s.db.Unscoped().Where("expires_at < ? OR session_id IN (?)", now, finished).Delete(&models.RefreshToken{})
+	if result.Error != nil {
+		log.Printf("Error purging refresh tokens: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired refresh tokens", result.RowsAffected)
+	}
+
+	result = s.db.Unscoped().Where("expires_at < ? OR (revoked_at IS NOT NULL AND access_token_expires_at < ?)", now, now).
+		Delete(&models.UserSession{})
+	if result.Error != nil {
+		log.Printf("Error purging sessions: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired or revoked sessions", result.RowsAffected)
+	}
+}
+
+func (s *TokenScheduler) purgeEmailTokens() {
+	now := time.Now()
+
+	result := s.db.Unscoped().Where("expires_at < ?", now).Delete(&models.PasswordResetToken{})
+	if result.Error != nil {
+		log.Printf("Error purging password reset tokens: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired password reset tokens", result.RowsAffected)
+	}
+
+	result = s.db.Unscoped().Where("expires_at < ?", now).Delete(&models.EmailVerificationToken{})
+	if result.Error != nil {
+		log.Printf("Error purging email verification tokens: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired email verification tokens", result.RowsAffected)
+	}
+}
+
+func (s *TokenScheduler) purgeRateLimitEvents() {
+	purged, err := utils.PurgeRateLimitEvents(s.db, time.Now())
+	if err != nil {
+		log.Printf("Error purging rate limit events: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d old rate limit events", purged)
+	}
+}
+
+func (s *TokenScheduler) purgeLoginThrottles() {
+	// Failures older than the lockout duration are forgotten anyway
+	now := time.Now()
+	result := s.db.Where("last_failure_at < ? AND (blocked_until IS NULL OR blocked_until < ?)", now.Add(-security.LockoutDuration()), now).
+		Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		log.Printf("Error purging login throttles: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired login throttles", result.RowsAffected)
+	}
+}
+
+func (s *TokenScheduler) purgeLoginChallenges() {
+	// Used challenges are kept until they expire like the unused ones
+	result := s.db.Unscoped().Where("expires_at < ?", time.Now()).Delete(&models.LoginChallenge{})
+	if result.Error != nil {
+		log.Printf("Error purging login challenges: %v", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("Purged %d expired login challenges", result.RowsAffected)
+	}
+}
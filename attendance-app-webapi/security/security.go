@@ -0,0 +1,212 @@
+// Package security throttles failed logins and records security events
+package security
+
+import (
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"attendance-app/config"
+	"attendance-app/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Defaults when LOGIN_MAX_FAILURES, LOGIN_MAX_FAILURES_PER_IP or LOGIN_LOCKOUT_DURATION are not set.
+// IP addresses get a higher limit because a whole school or office may share one.
+const (
+	defaultMaxFailures      = 10
+	defaultMaxFailuresPerIP = 50
+	defaultLockoutDuration  = 15 * time.Minute
+)
+
+// baseBackoff is the delay after the first failure over the backoff threshold; it doubles with every further failure
+const baseBackoff = time.Second
+
+// policy is the throttling of one kind of throttle key
+type policy struct {
+	kind        string
+	maxFailures int
+}
+
+// backoffAfter is the number of failures after which attempts are delayed
+func (p policy) backoffAfter() int {
+	return p.maxFailures / 3
+}
+
+func usernamePolicy() policy {
+	return policy{kind: models.ThrottleUsername, maxFailures: configInt("LOGIN_MAX_FAILURES", defaultMaxFailures)}
+}
+
+func ipPolicy() policy {
+	return policy{kind: models.ThrottleIP, maxFailures: configInt("LOGIN_MAX_FAILURES_PER_IP", defaultMaxFailuresPerIP)}
+}
+
+// LockoutDuration returns how long a username or IP address stays locked out (LOGIN_LOCKOUT_DURATION).
+// Failures older than that are forgotten.
+func LockoutDuration() time.Duration {
+	value := config.Config("LOGIN_LOCKOUT_DURATION")
+	if value == "" {
+		return defaultLockoutDuration
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("Invalid LOGIN_LOCKOUT_DURATION configuration: %v", err)
+	}
+	return duration
+}
+
+func configInt(key string, fallback int) int {
+	value := config.Config(key)
+	if value == "" {
+		return fallback
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		log.Fatalf("Invalid %s configuration: %q", key, value)
+	}
+	return number
+}
+
+// normalizeUsername makes throttling of a username independent of its case
+func normalizeUsername(username string) string {
+	return truncate(strings.ToLower(strings.TrimSpace(username)), 100)
+}
+
+func truncate(value string, length int) string {
+	if len(value) > length {
+		return value[:length]
+	}
+	return value
+}
+
+// CheckLogin returns how long logins of the username from the IP address are
+// still blocked, or zero if they are allowed
+func CheckLogin(db *gorm.DB, username, ip string, now time.Time) (time.Duration, error) {
+	var throttles []models.LoginThrottle
+	if err := db.Where("(kind = ? AND value = ?) OR (kind = ? AND value = ?)",
+		models.ThrottleUsername, normalizeUsername(username), models.ThrottleIP, ip).
+		Find(&throttles).Error; err != nil {
+		return 0, err
+	}
+
+	var wait time.Duration
+	for _, throttle := range throttles {
+		if throttle.BlockedUntil != nil && throttle.BlockedUntil.Sub(now) > wait {
+			wait = throttle.BlockedUntil.Sub(now)
+		}
+	}
+	return wait, nil
+}
+
+// LoginFailed counts a failed login of the username from the request's IP
+// address and records it as a security event of the given type. Reaching the
+// limit locks the username or IP address out, which is recorded as well.
+func LoginFailed(db *gorm.DB, c *gin.Context, eventType, username string, userID *uint, detail string) error {
+	now := time.Now()
+
+	tx := db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	usernameLocked, err := fail(tx, usernamePolicy(), normalizeUsername(username), now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	ipLocked, err := fail(tx, ipPolicy(), c.ClientIP(), now)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := Record(tx, c, eventType, username, userID, detail); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if usernameLocked {
+		if err := Record(tx, c, models.SecurityLockedOut, username, userID, "Username locked out after too many failed logins"); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if ipLocked {
+		if err := Record(tx, c, models.SecurityLockedOut, username, userID, "IP address locked out after too many failed logins"); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+// fail counts a failure on the throttle and reports whether it locked it out
+func fail(tx *gorm.DB, p policy, value string, now time.Time) (bool, error) {
+	// Create the counter if needed, then lock it so that concurrent failures are all counted
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.LoginThrottle{Kind: p.kind, Value: value, LastFailureAt: now}).Error; err != nil {
+		return false, err
+	}
+	var throttle models.LoginThrottle
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kind = ? AND value = ?", p.kind, value).First(&throttle).Error; err != nil {
+		return false, err
+	}
+
+	lockout := LockoutDuration()
+	blocked := throttle.BlockedUntil != nil && now.Before(*throttle.BlockedUntil)
+	if !blocked && now.Sub(throttle.LastFailureAt) > lockout {
+		throttle.Failures = 0
+		throttle.Locked = false
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	lockedOut := false
+	switch {
+	case throttle.Failures >= p.maxFailures:
+		lockedOut = !throttle.Locked
+		throttle.Locked = true
+		until := now.Add(lockout)
+		throttle.BlockedUntil = &until
+	case throttle.Failures > p.backoffAfter():
+		delay := baseBackoff << (throttle.Failures - p.backoffAfter() - 1)
+		if delay > lockout || delay <= 0 {
+			delay = lockout
+		}
+		until := now.Add(delay)
+		throttle.BlockedUntil = &until
+	}
+
+	return lockedOut, tx.Save(&throttle).Error
+}
+
+// LoginSucceeded forgets the failed logins of the username. Failures of the
+// IP address are kept so that one valid account does not unlock guessing
+// others from the same address.
+func LoginSucceeded(db *gorm.DB, username string) error {
+	return db.Where("kind = ? AND value = ?", models.ThrottleUsername, normalizeUsername(username)).
+		Delete(&models.LoginThrottle{}).Error
+}
+
+// Record records a security event of the request
+func Record(db *gorm.DB, c *gin.Context, eventType, username string, userID *uint, detail string) error {
+	event := models.SecurityEvent{
+		Type:     eventType,
+		Username: truncate(username, 100),
+		UserID:   userID,
+		Detail:   truncate(detail, 255),
+	}
+	if c != nil {
+		event.IPAddress = c.ClientIP()
+		event.UserAgent = truncate(c.Request.UserAgent(), 255)
+	}
+	return db.Create(&event).Error
+}
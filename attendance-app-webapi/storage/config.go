@@ -14,6 +14,9 @@ var DefaultConfig = Config{
 	AllowedTypes: map[string][]string{
 		"attendance": {".jpg", ".jpeg", ".png"},
 		"leave":      {".jpg", ".jpeg", ".png", ".pdf"},
+		"calendar":   {".ics"},
+		"correction": {".jpg", ".jpeg", ".png", ".pdf"},
+		"import":     {".csv", ".xlsx"},
 	},
 }
 
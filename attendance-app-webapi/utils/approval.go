@@ -0,0 +1,110 @@
+package utils
+
+import (
+	"errors"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoApprover is returned when a user has no supervisor to approve their requests
+var ErrNoApprover = errors.New("no supervisor is assigned to approve this request")
+
+// RequiredApprovalLevels returns the number of approval levels a leave request
+// needs: the highest Levels of the matching rules, or 1 when none match.
+func RequiredApprovalLevels(db *gorm.DB, leaveType models.LeaveType, days float64) (uint, error) {
+	var levels uint
+	if err := db.Model(&models.ApprovalChainRule{}).
+		Where("(leave_type = ? OR leave_type = '' OR leave_type IS NULL) AND min_days <= ?", leaveType, roundDays(days)).
+		Select("COALESCE(MAX(levels), 0)").
+		Scan(&levels).Error; err != nil {
+		return 0, err
+	}
+	if levels == 0 {
+		levels = 1
+	}
+	return levels, nil
+}
+
+// GetApprovalChain returns up to levels approvers for the user, walking up the
+// supervisor hierarchy. The chain is shorter when the top is reached first.
+func GetApprovalChain(db *gorm.DB, userID uint, levels uint) ([]uint, error) {
+	chain := make([]uint, 0, levels)
+	visited := map[uint]bool{userID: true}
+	current := userID
+
+	for uint(len(chain)) < levels {
+		var user models.User
+		if err := db.Select("id", "supervisor_id").First(&user, current).Error; err != nil {
+			return nil, err
+		}
+		if user.SupervisorID == nil || visited[*user.SupervisorID] {
+			break
+		}
+		current = *user.SupervisorID
+		visited[current] = true
+		chain = append(chain, current)
+	}
+	return chain, nil
+}
+
+// CreateLeaveApprovalSteps creates the pending approval steps of a leave request
+// from the approval rules and the user's current supervisor hierarchy
+func CreateLeaveApprovalSteps(db *gorm.DB, request models.LeaveRequest) ([]models.LeaveApprovalStep, error) {
+	levels, err := RequiredApprovalLevels(db, request.LeaveType, request.Days)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := GetApprovalChain(db, request.UserID, levels)
+	if err != nil {
+		return nil, err
+	}
+	if len(chain) == 0 {
+		return nil, ErrNoApprover
+	}
+
+	steps := make([]models.LeaveApprovalStep, len(chain))
+	for i, approverID := range chain {
+		steps[i] = models.LeaveApprovalStep{
+			LeaveRequestID: request.ID,
+			Level:          uint(i + 1),
+			ApproverID:     approverID,
+			Status:         models.ApprovalStepPending,
+		}
+	}
+	if err := db.Create(&steps).Error; err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+// GetLeaveApprovalSteps returns the approval steps of a leave request in order.
+// Requests submitted before approval chains existed get their steps created here.
+func GetLeaveApprovalSteps(db *gorm.DB, request models.LeaveRequest) ([]models.LeaveApprovalStep, error) {
+	var steps []models.LeaveApprovalStep
+	if err := db.Where("leave_request_id = ?", request.ID).Order("level ASC").Find(&steps).Error; err != nil {
+		return nil, err
+	}
+	if len(steps) == 0 && request.Status == models.LeavePending {
+		return CreateLeaveApprovalSteps(db, request)
+	}
+	return steps, nil
+}
+
+// CurrentApprovalStep returns the first undecided step, or nil when every step is decided
+func CurrentApprovalStep(steps []models.LeaveApprovalStep) *models.LeaveApprovalStep {
+	for i := range steps {
+		if steps[i].Status == models.ApprovalStepPending {
+			return &steps[i]
+		}
+	}
+	return nil
+}
+
+// SkipPendingApprovalSteps closes the undecided steps of a request that was rejected or withdrawn
+func SkipPendingApprovalSteps(db *gorm.DB, requestID uint) error {
+	return db.Model(&models.LeaveApprovalStep{}).
+		Where("leave_request_id = ? AND status = ?", requestID, models.ApprovalStepPending).
+		Update("status", models.ApprovalStepSkipped).Error
+}
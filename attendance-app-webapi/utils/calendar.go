@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// CalendarDateFormat is the key format used to match calendar days
+const CalendarDateFormat = "2006-01-02"
+
+// WorkingCalendar combines the company calendar with work schedules to decide
+// which days are working days. It is the single source of truth used by the
+// absence scheduler, leave day counting and reports.
+type WorkingCalendar struct {
+	days map[string]models.CalendarDay
+}
+
+// LoadWorkingCalendar loads the calendar entries between from and to (inclusive)
+func LoadWorkingCalendar(db *gorm.DB, from, to time.Time) (*WorkingCalendar, error) {
+	var days []models.CalendarDay
+	if err := db.Where("date BETWEEN ? AND ?", from.Format(CalendarDateFormat), to.Format(CalendarDateFormat)).
+		Find(&days).Error; err != nil {
+		return nil, err
+	}
+
+	calendar := &WorkingCalendar{days: make(map[string]models.CalendarDay, len(days))}
+	for _, day := range days {
+		calendar.days[day.Date.Format(CalendarDateFormat)] = day
+	}
+	return calendar, nil
+}
+
+// Day returns the calendar entry for the given day, if any
+func (w *WorkingCalendar) Day(day time.Time) (models.CalendarDay, bool) {
+	if w == nil {
+		return models.CalendarDay{}, false
+	}
+	entry, ok := w.days[day.Format(CalendarDateFormat)]
+	return entry, ok
+}
+
+// IsWorkingDay reports whether the day is a working day for the schedule.
+// Calendar entries take precedence over the schedule's weekdays.
+func (w *WorkingCalendar) IsWorkingDay(schedule models.WorkSchedule, day time.Time) bool {
+	if entry, ok := w.Day(day); ok {
+		return entry.IsWorkingDay()
+	}
+	return schedule.IsWorkingDay(day)
+}
+
+// WorkingDays returns the working days for the schedule between from and to (inclusive)
+func (w *WorkingCalendar) WorkingDays(schedule models.WorkSchedule, from, to time.Time) []time.Time {
+	var days []time.Time
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		if w.IsWorkingDay(schedule, d) {
+			days = append(days, d)
+		}
+	}
+	return days
+}
+
+// GetUserWorkingDays resolves the user's schedule and returns their working
+// days between from and to (inclusive)
+func GetUserWorkingDays(db *gorm.DB, userID uint, from, to time.Time) ([]time.Time, error) {
+	schedule, err := GetUserWorkSchedule(db, userID)
+	if err != nil {
+		return nil, err
+	}
+	calendar, err := LoadWorkingCalendar(db, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return calendar.WorkingDays(schedule, from, to), nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// activeDelegations limits a query to delegations in effect on the day of at
+func activeDelegations(db *gorm.DB, at time.Time) *gorm.DB {
+	day := at.Format(CalendarDateFormat)
+	return db.Model(&models.ApprovalDelegation{}).
+		Where("revoked_at IS NULL AND start_date <= ? AND end_date >= ?", day, day)
+}
+
+// CanActFor reports whether the actor may make decisions assigned to the
+// approver now. The returned delegation is nil when the actor is the approver.
+func CanActFor(db *gorm.DB, approverID, actorID uint) (*models.ApprovalDelegation, bool, error) {
+	if approverID == actorID {
+		return nil, true, nil
+	}
+
+	var delegation models.ApprovalDelegation
+	err := activeDelegations(db, time.Now()).
+		Where("delegator_id = ? AND delegate_id = ?", approverID, actorID).
+		Order("id ASC").
+		First(&delegation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return &delegation, true, nil
+}
+
+// CanActForSupervisor is CanActFor with the supervisor of the given user as
+// the approver. It is false when the user has no supervisor or is the actor.
+func CanActForSupervisor(db *gorm.DB, userID, actorID uint) (*models.ApprovalDelegation, bool, error) {
+	var user models.User
+	if err := db.Select("id", "supervisor_id").First(&user, userID).Error; err != nil {
+		return nil, false, err
+	}
+	if user.SupervisorID == nil || user.ID == actorID {
+		return nil, false, nil
+	}
+	return CanActFor(db, *user.SupervisorID, actorID)
+}
+
+// GetDelegatorIDs returns the users who currently delegate their approval rights to the user
+func GetDelegatorIDs(db *gorm.DB, delegateID uint) ([]uint, error) {
+	var ids []uint
+	err := activeDelegations(db, time.Now()).
+		Where("delegate_id = ?", delegateID).
+		Distinct().
+		Pluck("delegator_id", &ids).Error
+	return ids, err
+}
@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+
+	"attendance-app/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ErrNotDepartmentHead is returned when a user asks for a department they do not head
+var ErrNotDepartmentHead = errors.New("not the head of the department")
+
+// GetDepartmentSubtreeIDs returns the given departments and every department below them
+func GetDepartmentSubtreeIDs(db *gorm.DB, rootIDs ...uint) ([]uint, error) {
+	seen := make(map[uint]bool)
+	var ids []uint
+	frontier := rootIDs
+	for len(frontier) > 0 {
+		var next []uint
+		for _, id := range frontier {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+				next = append(next, id)
+			}
+		}
+		if len(next) == 0 {
+			break
+		}
+
+		frontier = nil
+		if err := db.Model(&models.Department{}).
+			Where("parent_id IN ?", next).
+			Pluck("id", &frontier).Error; err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// IsDepartmentInSubtree reports whether the department is the root or lies below it
+func IsDepartmentInSubtree(db *gorm.DB, rootID, departmentID uint) (bool, error) {
+	ids, err := GetDepartmentSubtreeIDs(db, rootID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == departmentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetHeadedDepartmentIDs returns the departments headed by the user and every department below them
+func GetHeadedDepartmentIDs(db *gorm.DB, userID uint) ([]uint, error) {
+	var rootIDs []uint
+	if err := db.Model(&models.Department{}).
+		Where("head_id = ?", userID).
+		Pluck("id", &rootIDs).Error; err != nil {
+		return nil, err
+	}
+	return GetDepartmentSubtreeIDs(db, rootIDs...)
+}
+
+// GetDepartmentMemberIDs returns the users assigned to any of the departments
+func GetDepartmentMemberIDs(db *gorm.DB, departmentIDs []uint) ([]uint, error) {
+	if len(departmentIDs) == 0 {
+		return []uint{}, nil
+	}
+
+	var ids []uint
+	err := db.Model(&models.User{}).
+		Where("department_id IN ?", departmentIDs).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+// GetHeadedDepartmentMemberIDs returns the users in the departments headed by
+// the user and in every department below them, excluding the user. When a
+// department is given the result is limited to its subtree, which must lie
+// within the headed departments. ErrNotDepartmentHead is returned when the
+// user heads no department or not the given one.
+func GetHeadedDepartmentMemberIDs(db *gorm.DB, headID uint, departmentID *uint) ([]uint, error) {
+	headedIDs, err := GetHeadedDepartmentIDs(db, headID)
+	if err != nil {
+		return nil, err
+	}
+	if len(headedIDs) == 0 {
+		return nil, ErrNotDepartmentHead
+	}
+
+	departmentIDs := headedIDs
+	if departmentID != nil {
+		headed := false
+		for _, id := range headedIDs {
+			if id == *departmentID {
+				headed = true
+				break
+			}
+		}
+		if !headed {
+			return nil, ErrNotDepartmentHead
+		}
+		if departmentIDs, err = GetDepartmentSubtreeIDs(db, *departmentID); err != nil {
+			return nil, err
+		}
+	}
+
+	memberIDs, err := GetDepartmentMemberIDs(db, departmentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		if id != headID {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// DepartmentQuery parses the optional departmentId query parameter
+func DepartmentQuery(c *gin.Context) (*uint, error) {
+	value := c.Query("departmentId")
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 32)
+	if err != nil || id == 0 {
+		return nil, errors.New("invalid department ID")
+	}
+	departmentID := uint(id)
+	return &departmentID, nil
+}
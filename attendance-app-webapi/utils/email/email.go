@@ -3,9 +3,11 @@ package email
 import (
 	"bytes"
 	"fmt"
+	"html"
 	"log"
 	"net/smtp"
 	"strings"
+	"time"
 
 	"attendance-app/config"
 )
@@ -416,3 +418,103 @@ func SendClockOutReminder(recipients []string) error {
 
 	return SendEmail(recipients, subject, body)
 }
+
+// SendPasswordReset sends a single-use password reset link to one user
+func SendPasswordReset(recipient, name, link string, expiresAt time.Time) error {
+	subject := "Reset Password - Sistem Absensi Digital"
+
+	body := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+        }
+        .container {
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .button {
+            display: inline-block;
+            background-color: #428bff;
+            color: white !important;
+            padding: 12px 30px;
+            text-decoration: none;
+            border-radius: 5px;
+            font-weight: 600;
+        }
+        .footer {
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h2>Reset Password</h2>
+        <p>Yth. %s,</p>
+        <p>Kami menerima permintaan untuk mengatur ulang password akun Anda di Sistem Absensi Digital.</p>
+        <p><a href="%s" class="button">Atur Ulang Password</a></p>
+        <p>Tautan ini hanya dapat digunakan satu kali dan berlaku sampai %s.</p>
+        <p class="footer">Jika Anda tidak meminta reset password, abaikan email ini. Password Anda tidak akan berubah.</p>
+    </div>
+</body>
+</html>
+`, html.EscapeString(name), html.EscapeString(link), expiresAt.Format("02-01-2006 15:04"))
+
+	return SendEmail([]string{recipient}, subject, body)
+}
+
+// SendEmailVerification sends the link confirming a user's new email address
+func SendEmailVerification(recipient, name, link string, expiresAt time.Time) error {
+	subject := "Verifikasi Email - Sistem Absensi Digital"
+
+	body := fmt.Sprintf(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body {
+            font-family: Arial, sans-serif;
+            line-height: 1.6;
+            color: #333;
+        }
+        .container {
+            max-width: 600px;
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .button {
+            display: inline-block;
+            background-color: #428bff;
+            color: white !important;
+            padding: 12px 30px;
+            text-decoration: none;
+            border-radius: 5px;
+            font-weight: 600;
+        }
+        .footer {
+            color: #666;
+            font-size: 14px;
+        }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <h2>Verifikasi Email</h2>
+        <p>Yth. %s,</p>
+        <p>Alamat email ini dimasukkan sebagai email baru akun Anda di Sistem Absensi Digital. Konfirmasi perubahan dengan tautan berikut.</p>
+        <p><a href="%s" class="button">Konfirmasi Email</a></p>
+        <p>Tautan ini hanya dapat digunakan satu kali dan berlaku sampai %s.</p>
+        <p class="footer">Jika Anda tidak meminta perubahan ini, abaikan email ini. Email akun tidak akan berubah.</p>
+    </div>
+</body>
+</html>
+`, html.EscapeString(name), html.EscapeString(link), expiresAt.Format("02-01-2006 15:04"))
+
+	return SendEmail([]string{recipient}, subject, body)
+}
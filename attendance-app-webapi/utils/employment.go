@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// WhereUserActive limits a query on users to those who are active at the
+// given time, see models.User.IsActive
+func WhereUserActive(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("(users.employment_status = ? OR users.employment_status_from > ? OR users.employment_status_until <= ?)",
+		models.EmploymentActive, now, now)
+}
+
+// WhereUserInactive limits a query on users to those who are suspended or
+// terminated at the given time
+func WhereUserInactive(db *gorm.DB, now time.Time) *gorm.DB {
+	return db.Where("users.employment_status <> ? AND (users.employment_status_from IS NULL OR users.employment_status_from <= ?) AND (users.employment_status_until IS NULL OR users.employment_status_until > ?)",
+		models.EmploymentActive, now, now)
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Position is a GeoJSON position in [longitude, latitude] order
+type Position [2]float64
+
+// Ring is a closed linear ring of positions
+type Ring []Position
+
+// Polygon is an outer ring followed by optional holes
+type Polygon []Ring
+
+// geoJSONGeometry is the subset of a GeoJSON geometry object used for geofences
+type geoJSONGeometry struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+// ParseGeofence parses and validates a GeoJSON Polygon or MultiPolygon geometry
+func ParseGeofence(raw []byte) ([]Polygon, error) {
+	var geometry geoJSONGeometry
+	if err := json.Unmarshal(raw, &geometry); err != nil {
+		return nil, errors.New("geometry must be a GeoJSON object")
+	}
+
+	var polygons []Polygon
+	switch geometry.Type {
+	case "Polygon":
+		var coordinates [][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &coordinates); err != nil {
+			return nil, errors.New("invalid Polygon coordinates")
+		}
+		polygon, err := buildPolygon(coordinates)
+		if err != nil {
+			return nil, err
+		}
+		polygons = append(polygons, polygon)
+	case "MultiPolygon":
+		var coordinates [][][][]float64
+		if err := json.Unmarshal(geometry.Coordinates, &coordinates); err != nil {
+			return nil, errors.New("invalid MultiPolygon coordinates")
+		}
+		if len(coordinates) == 0 {
+			return nil, errors.New("MultiPolygon must contain at least one polygon")
+		}
+		for i, rings := range coordinates {
+			polygon, err := buildPolygon(rings)
+			if err != nil {
+				return nil, fmt.Errorf("polygon %d: %w", i+1, err)
+			}
+			polygons = append(polygons, polygon)
+		}
+	default:
+		return nil, errors.New("geometry type must be Polygon or MultiPolygon")
+	}
+
+	return polygons, nil
+}
+
+// buildPolygon validates the rings of a polygon
+func buildPolygon(coordinates [][][]float64) (Polygon, error) {
+	if len(coordinates) == 0 {
+		return nil, errors.New("polygon must have an outer ring")
+	}
+
+	polygon := make(Polygon, 0, len(coordinates))
+	for i, positions := range coordinates {
+		if len(positions) < 4 {
+			return nil, fmt.Errorf("ring %d must have at least 4 positions", i+1)
+		}
+
+		ring := make(Ring, 0, len(positions))
+		for _, position := range positions {
+			if len(position) < 2 {
+				return nil, fmt.Errorf("ring %d has a position without longitude and latitude", i+1)
+			}
+			lon, lat := position[0], position[1]
+			if lon < -180 || lon > 180 || lat < -90 || lat > 90 {
+				return nil, fmt.Errorf("ring %d has an out of range position [%v, %v]", i+1, lon, lat)
+			}
+			ring = append(ring, Position{lon, lat})
+		}
+
+		if ring[0] != ring[len(ring)-1] {
+			return nil, fmt.Errorf("ring %d is not closed, first and last positions must be equal", i+1)
+		}
+		polygon = append(polygon, ring)
+	}
+
+	return polygon, nil
+}
+
+// pointInRing uses ray casting to test whether a point lies inside a ring
+func pointInRing(lat, lon float64, ring Ring) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if (yi > lat) != (yj > lat) && lon < (xj-xi)*(lat-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// IsPointInGeofence reports whether the coordinate is inside any polygon and outside its holes
+func IsPointInGeofence(lat, lon float64, polygons []Polygon) bool {
+	for _, polygon := range polygons {
+		if !pointInRing(lat, lon, polygon[0]) {
+			continue
+		}
+		inHole := false
+		for _, hole := range polygon[1:] {
+			if pointInRing(lat, lon, hole) {
+				inHole = true
+				break
+			}
+		}
+		if !inHole {
+			return true
+		}
+	}
+	return false
+}
+
+// DistanceToGeofence returns the distance in meters from the coordinate to the
+// nearest polygon edge, or zero when the coordinate is inside the geofence.
+// Distances use a local flat projection, which is accurate at building scale.
+func DistanceToGeofence(lat, lon float64, polygons []Polygon) float64 {
+	if IsPointInGeofence(lat, lon, polygons) {
+		return 0
+	}
+
+	const metersPerDegreeLat = 110540.0
+	metersPerDegreeLon := 111320.0 * math.Cos(lat*math.Pi/180)
+	project := func(p Position) (float64, float64) {
+		return (p[0] - lon) * metersPerDegreeLon, (p[1] - lat) * metersPerDegreeLat
+	}
+
+	nearest := math.Inf(1)
+	for _, polygon := range polygons {
+		for _, ring := range polygon {
+			for i := 0; i < len(ring)-1; i++ {
+				ax, ay := project(ring[i])
+				bx, by := project(ring[i+1])
+				if d := distanceToSegment(ax, ay, bx, by); d < nearest {
+					nearest = d
+				}
+			}
+		}
+	}
+	return nearest
+}
+
+// distanceToSegment returns the distance from the origin to the segment AB
+func distanceToSegment(ax, ay, bx, by float64) float64 {
+	dx, dy := bx-ax, by-ay
+	lengthSquared := dx*dx + dy*dy
+	t := 0.0
+	if lengthSquared > 0 {
+		t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/lengthSquared))
+	}
+	px, py := ax+t*dx, ay+t*dy
+	return math.Hypot(px, py)
+}
+
+// GeofenceCenter returns the center of the bounding box of the geofence
+func GeofenceCenter(polygons []Polygon) (float64, float64) {
+	minLat, minLon := math.Inf(1), math.Inf(1)
+	maxLat, maxLon := math.Inf(-1), math.Inf(-1)
+	for _, polygon := range polygons {
+		for _, position := range polygon[0] {
+			minLon, maxLon = math.Min(minLon, position[0]), math.Max(maxLon, position[0])
+			minLat, maxLat = math.Min(minLat, position[1]), math.Max(maxLat, position[1])
+		}
+	}
+	return (minLat + maxLat) / 2, (minLon + maxLon) / 2
+}
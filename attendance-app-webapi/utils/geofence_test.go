@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// A 0.01° square around the origin with a 0.004° square hole in the middle, and
+// a second square further east, as a MultiPolygon
+const testMultiPolygon = `{
+	"type": "MultiPolygon",
+	"coordinates": [
+		[
+			[[0, 0], [0.01, 0], [0.01, 0.01], [0, 0.01], [0, 0]],
+			[[0.003, 0.003], [0.007, 0.003], [0.007, 0.007], [0.003, 0.007], [0.003, 0.003]]
+		],
+		[
+			[[0.02, 0], [0.03, 0], [0.03, 0.01], [0.02, 0.01], [0.02, 0]]
+		]
+	]
+}`
+
+// An L-shaped polygon, concave at its inner corner
+const testConcavePolygon = `{
+	"type": "Polygon",
+	"coordinates": [
+		[[0, 0], [0.02, 0], [0.02, 0.01], [0.01, 0.01], [0.01, 0.02], [0, 0.02], [0, 0]]
+	]
+}`
+
+func mustParseGeofence(t *testing.T, raw string) []Polygon {
+	t.Helper()
+	polygons, err := ParseGeofence([]byte(raw))
+	if err != nil {
+		t.Fatalf("ParseGeofence: %v", err)
+	}
+	return polygons
+}
+
+func TestIsPointInGeofence(t *testing.T) {
+	multi := mustParseGeofence(t, testMultiPolygon)
+	concave := mustParseGeofence(t, testConcavePolygon)
+
+	tests := []struct {
+		name     string
+		polygons []Polygon
+		lat, lon float64
+		want     bool
+	}{
+		{"inside the first polygon", multi, 0.001, 0.001, true},
+		{"inside the hole", multi, 0.005, 0.005, false},
+		{"between the hole and the outer ring", multi, 0.008, 0.005, true},
+		{"inside the second polygon", multi, 0.005, 0.025, true},
+		{"between the polygons", multi, 0.005, 0.015, false},
+		{"north of the polygons", multi, 0.02, 0.005, false},
+		{"inside the arm of the L", concave, 0.015, 0.005, true},
+		{"in the notch of the L", concave, 0.015, 0.015, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsPointInGeofence(tt.lat, tt.lon, tt.polygons); got != tt.want {
+				t.Errorf("IsPointInGeofence(%v, %v) = %v, want %v", tt.lat, tt.lon, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceToGeofence(t *testing.T) {
+	multi := mustParseGeofence(t, testMultiPolygon)
+
+	if d := DistanceToGeofence(0.001, 0.001, multi); d != 0 {
+		t.Errorf("distance from inside = %v, want 0", d)
+	}
+
+	// The hole's nearest edge is 0.002° of latitude away
+	want := 0.002 * 110540.0
+	if d := DistanceToGeofence(0.005, 0.005, multi); math.Abs(d-want) > 1 {
+		t.Errorf("distance from the hole = %.1f, want %.1f", d, want)
+	}
+
+	// 0.001° north of the first polygon
+	want = 0.001 * 110540.0
+	if d := DistanceToGeofence(0.011, 0.005, multi); math.Abs(d-want) > 1 {
+		t.Errorf("distance from the north = %.1f, want %.1f", d, want)
+	}
+}
+
+func TestGeofenceCenter(t *testing.T) {
+	lat, lon := GeofenceCenter(mustParseGeofence(t, testMultiPolygon))
+	if math.Abs(lat-0.005) > 1e-9 || math.Abs(lon-0.015) > 1e-9 {
+		t.Errorf("GeofenceCenter = (%v, %v), want (0.005, 0.015)", lat, lon)
+	}
+}
+
+func TestParseGeofenceRejectsInvalidGeometry(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+	}{
+		{"not an object", `[1, 2]`},
+		{"unsupported type", `{"type": "Point", "coordinates": [0, 0]}`},
+		{"no rings", `{"type": "Polygon", "coordinates": []}`},
+		{"too few positions", `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`},
+		{"open ring", `{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [1, 1], [0, 1]]]}`},
+		{"out of range", `{"type": "Polygon", "coordinates": [[[0, 0], [181, 0], [1, 1], [0, 0]]]}`},
+		{"empty MultiPolygon", `{"type": "MultiPolygon", "coordinates": []}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseGeofence([]byte(tt.raw)); err == nil {
+				t.Error("ParseGeofence accepted the geometry")
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MaxICSEventDays is the longest span an imported event may cover
+const MaxICSEventDays = 366
+
+// ICSEvent is an all-day event read from an iCalendar file.
+// End is exclusive, as in the DTEND property.
+type ICSEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Start       time.Time
+	End         time.Time
+}
+
+// Days returns every calendar day covered by the event
+func (e ICSEvent) Days() []time.Time {
+	var days []time.Time
+	for d := e.Start; d.Before(e.End); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// ParseICS reads the VEVENT entries of an iCalendar (.ics) file.
+// Only the properties needed for holidays are read; timed events cover every
+// day they overlap in the given location. Recurring events are rejected rather
+// than imported as their first occurrence only.
+func ParseICS(r io.Reader, loc *time.Location) ([]ICSEvent, error) {
+	lines, err := unfoldICSLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []ICSEvent
+	var current *ICSEvent
+	hasEnd := false
+
+	for i, line := range lines {
+		name, params, value, ok := splitICSLine(line)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case name == "BEGIN" && strings.EqualFold(value, "VEVENT"):
+			current = &ICSEvent{}
+			hasEnd = false
+		case name == "END" && strings.EqualFold(value, "VEVENT"):
+			if current == nil {
+				continue
+			}
+			if current.Start.IsZero() {
+				return nil, fmt.Errorf("line %d: event %q has no DTSTART", i+1, current.Summary)
+			}
+			if !hasEnd || !current.End.After(current.Start) {
+				current.End = current.Start.AddDate(0, 0, 1)
+			}
+			if current.End.After(current.Start.AddDate(0, 0, MaxICSEventDays)) {
+				return nil, fmt.Errorf("line %d: event %q spans more than %d days", i+1, current.Summary, MaxICSEventDays)
+			}
+			events = append(events, *current)
+			current = nil
+		case current == nil:
+			continue
+		case name == "UID":
+			current.UID = value
+		case name == "SUMMARY":
+			current.Summary = unescapeICSText(value)
+		case name == "DESCRIPTION":
+			current.Description = unescapeICSText(value)
+		case name == "DTSTART":
+			date, _, err := parseICSDate(params, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			current.Start = date
+		case name == "RRULE" || name == "RDATE":
+			return nil, fmt.Errorf("line %d: event %q repeats with %s, which is not supported; export the calendar with every occurrence as a separate event", i+1, current.Summary, name)
+		case name == "DTEND":
+			date, midnight, err := parseICSDate(params, value, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			// A timed event ending later on a day still covers that day
+			if !midnight {
+				date = date.AddDate(0, 0, 1)
+			}
+			current.End = date
+			hasEnd = true
+		}
+	}
+
+	if len(events) == 0 {
+		return nil, errors.New("no events found in calendar file")
+	}
+	return events, nil
+}
+
+// unfoldICSLines joins folded content lines (RFC 5545 section 3.1)
+func unfoldICSLines(r io.Reader) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar file: %w", err)
+	}
+	return lines, nil
+}
+
+// splitICSLine splits "NAME;PARAM=VALUE:content" into its parts
+func splitICSLine(line string) (string, map[string]string, string, bool) {
+	colon := strings.Index(line, ":")
+	if colon < 0 {
+		return "", nil, "", false
+	}
+
+	head := strings.Split(line[:colon], ";")
+	params := make(map[string]string, len(head)-1)
+	for _, param := range head[1:] {
+		if key, value, found := strings.Cut(param, "="); found {
+			params[strings.ToUpper(key)] = strings.Trim(value, `"`)
+		}
+	}
+	return strings.ToUpper(head[0]), params, strings.TrimSpace(line[colon+1:]), true
+}
+
+// parseICSDate parses a DATE or DATE-TIME value and returns the day it falls
+// on, and whether the value is exactly at the start of that day
+func parseICSDate(params map[string]string, value string, loc *time.Location) (time.Time, bool, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		date, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("invalid date %q", value)
+		}
+		return date, true, nil
+	}
+
+	var moment time.Time
+	var err error
+	if strings.HasSuffix(value, "Z") {
+		moment, err = time.Parse("20060102T150405Z", value)
+	} else {
+		zone := loc
+		if tzid, ok := params["TZID"]; ok {
+			if tz, tzErr := time.LoadLocation(tzid); tzErr == nil {
+				zone = tz
+			}
+		}
+		moment, err = time.ParseInLocation("20060102T150405", value, zone)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date-time %q", value)
+	}
+
+	moment = moment.In(loc)
+	day := time.Date(moment.Year(), moment.Month(), moment.Day(), 0, 0, 0, 0, loc)
+	return day, moment.Equal(day), nil
+}
+
+// unescapeICSText reverses the TEXT escaping of RFC 5545
+func unescapeICSText(value string) string {
+	replacer := strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+	return replacer.Replace(value)
+}
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// icsCalendar wraps the given event lines in a VCALENDAR with CRLF line endings
+func icsCalendar(lines ...string) string {
+	all := append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...)
+	all = append(all, "END:VCALENDAR")
+	return strings.Join(all, "\r\n") + "\r\n"
+}
+
+func TestParseICS(t *testing.T) {
+	loc := time.FixedZone("WIB", 7*60*60)
+	raw := icsCalendar(
+		"BEGIN:VEVENT",
+		"UID:new-year@example.com",
+		"SUMMARY:New Year",
+		"DTSTART;VALUE=DATE:20270101",
+		"DTEND;VALUE=DATE:20270102",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"UID:retreat@example.com",
+		"SUMMARY:Company retreat\\, day one",
+		"DESCRIPTION:Bring your laptop\\nand a jacket",
+		// A folded line continues after its single leading space
+		"  for the evening",
+		"DTSTART;VALUE=DATE:20270310",
+		"DTEND;VALUE=DATE:20270313",
+		"END:VEVENT",
+		"BEGIN:VEVENT",
+		"SUMMARY:Town hall",
+		// 18:00 UTC is 01:00 the next day in WIB
+		"DTSTART:20270415T180000Z",
+		"DTEND:20270415T200000Z",
+		"END:VEVENT",
+	)
+
+	events, err := ParseICS(strings.NewReader(raw), loc)
+	if err != nil {
+		t.Fatalf("ParseICS: %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, loc)
+	}
+	tests := []struct {
+		summary     string
+		description string
+		uid         string
+		days        []time.Time
+	}{
+		{"New Year", "", "new-year@example.com", []time.Time{day(2027, 1, 1)}},
+		{"Company retreat, day one", "Bring your laptop\nand a jacket for the evening", "retreat@example.com",
+			[]time.Time{day(2027, 3, 10), day(2027, 3, 11), day(2027, 3, 12)}},
+		{"Town hall", "", "", []time.Time{day(2027, 4, 16)}},
+	}
+	for i, tt := range tests {
+		event := events[i]
+		if event.Summary != tt.summary || event.Description != tt.description || event.UID != tt.uid {
+			t.Errorf("event %d = %q, %q, %q, want %q, %q, %q",
+				i, event.Summary, event.Description, event.UID, tt.summary, tt.description, tt.uid)
+		}
+		days := event.Days()
+		if len(days) != len(tt.days) {
+			t.Errorf("event %q covers %v, want %v", tt.summary, days, tt.days)
+			continue
+		}
+		for j := range days {
+			if !days[j].Equal(tt.days[j]) {
+				t.Errorf("event %q covers %v, want %v", tt.summary, days, tt.days)
+				break
+			}
+		}
+	}
+}
+
+func TestParseICSWithoutEnd(t *testing.T) {
+	raw := icsCalendar("BEGIN:VEVENT", "SUMMARY:Founding day", "DTSTART:20270601", "END:VEVENT")
+
+	events, err := ParseICS(strings.NewReader(raw), time.UTC)
+	if err != nil {
+		t.Fatalf("ParseICS: %v", err)
+	}
+	if days := events[0].Days(); len(days) != 1 || !days[0].Equal(time.Date(2027, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("event covers %v, want only 2027-06-01", days)
+	}
+}
+
+func TestParseICSRejectsInvalidFiles(t *testing.T) {
+	tests := []struct {
+		name  string
+		raw   string
+		error string
+	}{
+		{"no events", icsCalendar(), "no events"},
+		{"no start", icsCalendar("BEGIN:VEVENT", "SUMMARY:Holiday", "END:VEVENT"), "no DTSTART"},
+		{"invalid date", icsCalendar("BEGIN:VEVENT", "DTSTART;VALUE=DATE:20271341", "END:VEVENT"), "invalid date"},
+		{"too long", icsCalendar("BEGIN:VEVENT", "DTSTART:20270101", "DTEND:20280301", "END:VEVENT"), "more than"},
+		{"yearly rule", icsCalendar(
+			"BEGIN:VEVENT", "SUMMARY:Christmas", "DTSTART;VALUE=DATE:20271225",
+			"RRULE:FREQ=YEARLY", "END:VEVENT",
+		), "RRULE"},
+		{"extra dates", icsCalendar(
+			"BEGIN:VEVENT", "SUMMARY:Audit", "DTSTART;VALUE=DATE:20270105",
+			"RDATE;VALUE=DATE:20270705", "END:VEVENT",
+		), "RDATE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseICS(strings.NewReader(tt.raw), time.UTC)
+			if err == nil {
+				t.Fatal("ParseICS accepted the file")
+			}
+			if !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.error)
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrLeaveSpansYears is returned when a leave request that counts against a balance crosses a year boundary
+var ErrLeaveSpansYears = errors.New("leave requests that count against a balance cannot span two calendar years, please submit one request per year")
+
+// InsufficientLeaveBalanceError is returned when a request needs more days than are available
+type InsufficientLeaveBalanceError struct {
+	Requested float64
+	Available float64
+}
+
+func (e *InsufficientLeaveBalanceError) Error() string {
+	return fmt.Sprintf("insufficient leave balance: requested %.2f days, %.2f days available", e.Requested, e.Available)
+}
+
+// LeaveBalance summarizes a user's ledger for one leave type and year
+type LeaveBalance struct {
+	LeaveType      models.LeaveType     `json:"leaveType" example:"ANNUAL"`
+	Year           int                  `json:"year" example:"2025"`
+	AnnualDays     float64              `json:"annualDays" example:"12"`
+	AccrualMethod  models.AccrualMethod `json:"accrualMethod" example:"MONTHLY"`
+	CarryOverLimit float64              `json:"carryOverLimit" example:"5"`
+	Accrued        float64              `json:"accrued" example:"10"`
+	CarriedOver    float64              `json:"carriedOver" example:"2"`
+	Used           float64              `json:"used" example:"3"`
+	Adjusted       float64              `json:"adjusted" example:"0"`
+	// Balance is the sum of all ledger entries
+	Balance float64 `json:"balance" example:"9"`
+	// Pending is the number of days in requests awaiting approval
+	Pending float64 `json:"pending" example:"1"`
+	// Available is the balance minus pending days
+	Available float64 `json:"available" example:"8"`
+}
+
+// roundDays rounds a number of days to two decimals, matching the database precision
+func roundDays(days float64) float64 {
+	return math.Round(days*100) / 100
+}
+
+// yearRange returns the first day of the year and the first day of the next year
+func yearRange(year int) (time.Time, time.Time) {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	return start, start.AddDate(1, 0, 0)
+}
+
+// ensureLedgerEntry creates the entry unless one already exists for the same period
+func ensureLedgerEntry(db *gorm.DB, entry models.LeaveLedgerEntry) error {
+	var count int64
+	if err := db.Model(&models.LeaveLedgerEntry{}).
+		Where("user_id = ? AND leave_type = ? AND year = ? AND entry_type = ? AND period = ?",
+			entry.UserID, entry.LeaveType, entry.Year, entry.EntryType, entry.Period).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	return db.Create(&entry).Error
+}
+
+// ledgerSum returns the sum of ledger entries for a user, leave type and year
+func ledgerSum(db *gorm.DB, userID uint, leaveType models.LeaveType, year int) (float64, error) {
+	var sum float64
+	err := db.Model(&models.LeaveLedgerEntry{}).
+		Where("user_id = ? AND leave_type = ? AND year = ?", userID, leaveType, year).
+		Select("COALESCE(SUM(days), 0)").
+		Scan(&sum).Error
+	return roundDays(sum), err
+}
+
+// dueAccruals returns the accrual entries the entitlement should have received by now
+func dueAccruals(entitlement models.LeaveEntitlement, now time.Time) []models.LeaveLedgerEntry {
+	if entitlement.Year > now.Year() {
+		return nil
+	}
+
+	if entitlement.AccrualMethod != models.AccrueMonthly {
+		return []models.LeaveLedgerEntry{{
+			UserID:      entitlement.UserID,
+			LeaveType:   entitlement.LeaveType,
+			Year:        entitlement.Year,
+			EntryType:   models.LedgerAccrual,
+			Days:        entitlement.AnnualDays,
+			Period:      fmt.Sprintf("%d", entitlement.Year),
+			Description: fmt.Sprintf("Annual entitlement for %d", entitlement.Year),
+		}}
+	}
+
+	lastMonth := 12
+	if entitlement.Year == now.Year() {
+		lastMonth = int(now.Month())
+	}
+	monthly := roundDays(entitlement.AnnualDays / 12)
+	entries := make([]models.LeaveLedgerEntry, 0, lastMonth)
+	for month := 1; month <= lastMonth; month++ {
+		days := monthly
+		// The last month absorbs rounding so the year adds up to the entitlement
+		if month == 12 {
+			days = roundDays(entitlement.AnnualDays - monthly*11)
+		}
+		entries = append(entries, models.LeaveLedgerEntry{
+			UserID:      entitlement.UserID,
+			LeaveType:   entitlement.LeaveType,
+			Year:        entitlement.Year,
+			EntryType:   models.LedgerAccrual,
+			Days:        days,
+			Period:      fmt.Sprintf("%d-%02d", entitlement.Year, month),
+			Description: fmt.Sprintf("Monthly accrual for %s %d", time.Month(month), entitlement.Year),
+		})
+	}
+	return entries
+}
+
+// PostLeaveAccruals credits the accruals and carry-over that are due for the
+// entitlement up to now. It is idempotent and safe to run repeatedly.
+func PostLeaveAccruals(db *gorm.DB, entitlement models.LeaveEntitlement, now time.Time) error {
+	if entitlement.Year > now.Year() {
+		return nil
+	}
+
+	for _, entry := range dueAccruals(entitlement, now) {
+		if err := ensureLedgerEntry(db, entry); err != nil {
+			return err
+		}
+	}
+
+	return postCarryOver(db, entitlement)
+}
+
+// ReconcileLeaveAccruals is used after an entitlement changes. It posts the
+// difference between what was accrued so far and what the new settings would
+// have accrued, then posts any accruals that are still missing.
+func ReconcileLeaveAccruals(db *gorm.DB, entitlement models.LeaveEntitlement, now time.Time, actorID *uint) error {
+	var accrued float64
+	if err := db.Model(&models.LeaveLedgerEntry{}).
+		Where("user_id = ? AND leave_type = ? AND year = ? AND entry_type = ?",
+			entitlement.UserID, entitlement.LeaveType, entitlement.Year, models.LedgerAccrual).
+		Select("COALESCE(SUM(days), 0)").
+		Scan(&accrued).Error; err != nil {
+		return err
+	}
+
+	// Periods that were already posted keep their entries, so only compare those
+	var postedPeriods []string
+	if err := db.Model(&models.LeaveLedgerEntry{}).
+		Where("user_id = ? AND leave_type = ? AND year = ? AND entry_type = ? AND period != ''",
+			entitlement.UserID, entitlement.LeaveType, entitlement.Year, models.LedgerAccrual).
+		Pluck("period", &postedPeriods).Error; err != nil {
+		return err
+	}
+	posted := make(map[string]bool, len(postedPeriods))
+	for _, period := range postedPeriods {
+		posted[period] = true
+	}
+
+	expected := 0.0
+	for _, entry := range dueAccruals(entitlement, now) {
+		if posted[entry.Period] {
+			expected += entry.Days
+		}
+	}
+
+	if difference := roundDays(expected - accrued); difference != 0 {
+		if err := db.Create(&models.LeaveLedgerEntry{
+			UserID:      entitlement.UserID,
+			LeaveType:   entitlement.LeaveType,
+			Year:        entitlement.Year,
+			EntryType:   models.LedgerAccrual,
+			Days:        difference,
+			Description: fmt.Sprintf("Correction after entitlement changed to %.2f days", entitlement.AnnualDays),
+			CreatedByID: actorID,
+		}).Error; err != nil {
+			return err
+		}
+	}
+
+	return PostLeaveAccruals(db, entitlement, now)
+}
+
+// postCarryOver moves the unused balance of the previous year, capped by the
+// previous year's carry-over limit, into the entitlement's year
+func postCarryOver(db *gorm.DB, entitlement models.LeaveEntitlement) error {
+	var previous models.LeaveEntitlement
+	if err := db.Where("user_id = ? AND leave_type = ? AND year = ?",
+		entitlement.UserID, entitlement.LeaveType, entitlement.Year-1).First(&previous).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if previous.CarryOverLimit <= 0 {
+		return nil
+	}
+
+	remaining, err := ledgerSum(db, entitlement.UserID, entitlement.LeaveType, previous.Year)
+	if err != nil {
+		return err
+	}
+	carried := roundDays(math.Min(remaining, previous.CarryOverLimit))
+	if carried <= 0 {
+		return nil
+	}
+
+	return ensureLedgerEntry(db, models.LeaveLedgerEntry{
+		UserID:      entitlement.UserID,
+		LeaveType:   entitlement.LeaveType,
+		Year:        entitlement.Year,
+		EntryType:   models.LedgerCarryOver,
+		Days:        carried,
+		Period:      fmt.Sprintf("%d", previous.Year),
+		Description: fmt.Sprintf("Carried over from %d", previous.Year),
+	})
+}
+
+// ProcessLeaveAccruals copies last year's entitlements into the current year
+// when they have not been set up yet, then posts all due accruals.
+func ProcessLeaveAccruals(db *gorm.DB, now time.Time) error {
+	year := now.Year()
+
+	var previous []models.LeaveEntitlement
+	if err := db.Where("year = ?", year-1).Find(&previous).Error; err != nil {
+		return err
+	}
+	for _, entitlement := range previous {
+		var count int64
+		if err := db.Model(&models.LeaveEntitlement{}).
+			Where("user_id = ? AND leave_type = ? AND year = ?", entitlement.UserID, entitlement.LeaveType, year).
+			Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		next := models.LeaveEntitlement{
+			UserID:         entitlement.UserID,
+			LeaveType:      entitlement.LeaveType,
+			Year:           year,
+			AnnualDays:     entitlement.AnnualDays,
+			AccrualMethod:  entitlement.AccrualMethod,
+			CarryOverLimit: entitlement.CarryOverLimit,
+		}
+		if err := db.Create(&next).Error; err != nil {
+			return err
+		}
+	}
+
+	// Previous year first so its accruals are complete before carrying over
+	var entitlements []models.LeaveEntitlement
+	if err := db.Where("year IN ?", []int{year - 1, year}).Order("year ASC").Find(&entitlements).Error; err != nil {
+		return err
+	}
+	for _, entitlement := range entitlements {
+		if err := PostLeaveAccruals(db, entitlement, now); err != nil {
+			return fmt.Errorf("entitlement %d: %w", entitlement.ID, err)
+		}
+	}
+	return nil
+}
+
+// GetLeaveEntitlement returns the user's entitlement for a leave type and year,
+// or nil when the user has none
+func GetLeaveEntitlement(db *gorm.DB, userID uint, leaveType models.LeaveType, year int) (*models.LeaveEntitlement, error) {
+	var entitlement models.LeaveEntitlement
+	if err := db.Where("user_id = ? AND leave_type = ? AND year = ?", userID, leaveType, year).
+		First(&entitlement).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &entitlement, nil
+}
+
+// buildLeaveBalance summarizes the ledger and pending requests for an entitlement
+func buildLeaveBalance(db *gorm.DB, entitlement models.LeaveEntitlement) (LeaveBalance, error) {
+	balance := LeaveBalance{
+		LeaveType:      entitlement.LeaveType,
+		Year:           entitlement.Year,
+		AnnualDays:     entitlement.AnnualDays,
+		AccrualMethod:  entitlement.AccrualMethod,
+		CarryOverLimit: entitlement.CarryOverLimit,
+	}
+
+	var totals []struct {
+		EntryType models.LeaveLedgerEntryType
+		Days      float64
+	}
+	if err := db.Model(&models.LeaveLedgerEntry{}).
+		Select("entry_type, COALESCE(SUM(days), 0) AS days").
+		Where("user_id = ? AND leave_type = ? AND year = ?", entitlement.UserID, entitlement.LeaveType, entitlement.Year).
+		Group("entry_type").
+		Scan(&totals).Error; err != nil {
+		return LeaveBalance{}, err
+	}
+	for _, total := range totals {
+		switch total.EntryType {
+		case models.LedgerAccrual:
+			balance.Accrued += total.Days
+		case models.LedgerCarryOver:
+			balance.CarriedOver += total.Days
+		case models.LedgerDeduction, models.LedgerReversal:
+			// Used is shown as a positive number of days taken
+			balance.Used -= total.Days
+		default:
+			balance.Adjusted += total.Days
+		}
+		balance.Balance += total.Days
+	}
+
+	from, to := yearRange(entitlement.Year)
+	if err := db.Model(&models.LeaveRequest{}).
+		Where("user_id = ? AND leave_type = ? AND status = ? AND start_date >= ? AND start_date < ?",
+			entitlement.UserID, entitlement.LeaveType, models.LeavePending, from, to).
+		Select("COALESCE(SUM(days), 0)").
+		Scan(&balance.Pending).Error; err != nil {
+		return LeaveBalance{}, err
+	}
+
+	balance.Accrued = roundDays(balance.Accrued)
+	balance.CarriedOver = roundDays(balance.CarriedOver)
+	balance.Used = roundDays(balance.Used)
+	balance.Adjusted = roundDays(balance.Adjusted)
+	balance.Balance = roundDays(balance.Balance)
+	balance.Pending = roundDays(balance.Pending)
+	balance.Available = roundDays(balance.Balance - balance.Pending)
+	return balance, nil
+}
+
+// GetLeaveBalances returns the user's balance for every leave type with an entitlement in the year
+func GetLeaveBalances(db *gorm.DB, userID uint, year int) ([]LeaveBalance, error) {
+	var entitlements []models.LeaveEntitlement
+	if err := db.Where("user_id = ? AND year = ?", userID, year).Order("leave_type ASC").
+		Find(&entitlements).Error; err != nil {
+		return nil, err
+	}
+
+	balances := make([]LeaveBalance, 0, len(entitlements))
+	for _, entitlement := range entitlements {
+		balance, err := buildLeaveBalance(db, entitlement)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, balance)
+	}
+	return balances, nil
+}
+
+// CheckLeaveBalance verifies that a request of the given number of days fits
+// in the remaining balance of a leave type that counts against a balance.
+// Without an entitlement for the year the balance is zero. Pending requests are
+// counted as already taken; pass the request itself as excludeRequestID when
+// re-checking on approval.
+//
+// The entitlement row is locked FOR UPDATE, so db must be the transaction that
+// creates or approves the request; concurrent requests for the same balance then
+// wait for each other instead of both fitting in the same remaining days.
+func CheckLeaveBalance(db *gorm.DB, userID uint, leaveType models.LeaveType, start, end time.Time, days float64, excludeRequestID uint) error {
+	if start.Year() != end.Year() {
+		return ErrLeaveSpansYears
+	}
+
+	entitlement, err := GetLeaveEntitlement(db.Clauses(clause.Locking{Strength: "UPDATE"}), userID, leaveType, start.Year())
+	if err != nil {
+		return err
+	}
+	if entitlement == nil {
+		return &InsufficientLeaveBalanceError{Requested: roundDays(days), Available: 0}
+	}
+	balance, err := buildLeaveBalance(db, *entitlement)
+	if err != nil {
+		return err
+	}
+
+	available := balance.Available
+	if excludeRequestID != 0 {
+		var excluded models.LeaveRequest
+		if err := db.Select("days", "status").First(&excluded, excludeRequestID).Error; err == nil &&
+			excluded.Status == models.LeavePending {
+			available = roundDays(available + excluded.Days)
+		}
+	}
+
+	if roundDays(days) > available {
+		return &InsufficientLeaveBalanceError{Requested: roundDays(days), Available: available}
+	}
+	return nil
+}
+
+// DeductLeaveBalance records the days of an approved request in the ledger.
+// It should only be called for leave types that count against a balance.
+func DeductLeaveBalance(db *gorm.DB, request models.LeaveRequest, approverID *uint) error {
+	entitlement, err := GetLeaveEntitlement(db, request.UserID, request.LeaveType, request.StartDate.Year())
+	if err != nil || entitlement == nil {
+		return err
+	}
+
+	return db.Create(&models.LeaveLedgerEntry{
+		UserID:         request.UserID,
+		LeaveType:      request.LeaveType,
+		Year:           entitlement.Year,
+		EntryType:      models.LedgerDeduction,
+		Days:           -roundDays(request.Days),
+		LeaveRequestID: &request.ID,
+		Description: fmt.Sprintf("Leave from %s to %s",
+			request.StartDate.Format(CalendarDateFormat), request.EndDate.Format(CalendarDateFormat)),
+		CreatedByID: approverID,
+	}).Error
+}
+
+// ReverseLeaveDeduction credits back the days deducted for a request whose
+// leave is cancelled. It does nothing when nothing is left to reverse.
+func ReverseLeaveDeduction(db *gorm.DB, request models.LeaveRequest, actorID *uint) error {
+	var deductions []struct {
+		LeaveType models.LeaveType
+		Year      int
+		Days      float64
+	}
+	if err := db.Model(&models.LeaveLedgerEntry{}).
+		Select("leave_type, year, COALESCE(SUM(days), 0) AS days").
+		Where("leave_request_id = ? AND entry_type IN ?", request.ID,
+			[]models.LeaveLedgerEntryType{models.LedgerDeduction, models.LedgerReversal}).
+		Group("leave_type, year").
+		Scan(&deductions).Error; err != nil {
+		return err
+	}
+
+	for _, deduction := range deductions {
+		days := roundDays(-deduction.Days)
+		if days <= 0 {
+			continue
+		}
+		if err := db.Create(&models.LeaveLedgerEntry{
+			UserID:         request.UserID,
+			LeaveType:      deduction.LeaveType,
+			Year:           deduction.Year,
+			EntryType:      models.LedgerReversal,
+			Days:           days,
+			LeaveRequestID: &request.ID,
+			Description: fmt.Sprintf("Cancelled leave from %s to %s",
+				request.StartDate.Format(CalendarDateFormat), request.EndDate.Format(CalendarDateFormat)),
+			CreatedByID: actorID,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"errors"
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// LocalDate returns midnight in local time of the calendar date of t.
+// Leave dates are stored as UTC midnight, while shifts are in local time.
+func LocalDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// LeavePortionWindow returns the span covered by a partial-day leave on the
+// given day and the fraction of a working day it represents. MORNING and
+// AFTERNOON split the shift in two halves, or at its break for a split shift;
+// HOURS uses the given "HH:MM" times, which must fall within the shift, and
+// does not count the break.
+func LeavePortionWindow(schedule models.WorkSchedule, day time.Time, portion models.LeaveDayPortion, startTime, endTime string) (time.Time, time.Time, float64, error) {
+	shiftStart, shiftEnd := schedule.ShiftWindow(LocalDate(day))
+	morningEnd := shiftStart.Add(shiftEnd.Sub(shiftStart) / 2)
+	afternoonStart := morningEnd
+	if schedule.HasBreak() {
+		morningEnd, afternoonStart = schedule.BreakWindow(LocalDate(day))
+	}
+
+	switch portion {
+	case models.LeaveMorning:
+		return shiftStart, morningEnd, 0.5, nil
+	case models.LeaveAfternoon:
+		return afternoonStart, shiftEnd, 0.5, nil
+	case models.LeaveHours:
+		startHour, startMinute, err := models.ParseClock(startTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+		endHour, endMinute, err := models.ParseClock(endTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, err
+		}
+
+		from := time.Date(shiftStart.Year(), shiftStart.Month(), shiftStart.Day(), startHour, startMinute, 0, 0, time.Local)
+		// Times before the shift start belong to the next day of an overnight shift
+		if from.Before(shiftStart) && schedule.IsOvernight() {
+			from = from.AddDate(0, 0, 1)
+		}
+		to := time.Date(from.Year(), from.Month(), from.Day(), endHour, endMinute, 0, 0, time.Local)
+		if !to.After(from) {
+			to = to.AddDate(0, 0, 1)
+		}
+
+		if from.Before(shiftStart) || to.After(shiftEnd) {
+			return time.Time{}, time.Time{}, 0, errors.New("leave hours must fall within the work schedule " +
+				schedule.StartTime + " - " + schedule.EndTime)
+		}
+
+		worked := to.Sub(from) - schedule.BreakOverlap(LocalDate(day), from, to)
+		if worked <= 0 {
+			return time.Time{}, time.Time{}, 0, errors.New("leave hours cannot fall entirely within the break " +
+				schedule.BreakStart + " - " + schedule.BreakEnd)
+		}
+		days := roundDays(worked.Hours() / schedule.WorkingDuration(LocalDate(day)).Hours())
+		return from, to, days, nil
+	}
+
+	return time.Time{}, time.Time{}, 0, errors.New("day portion must be MORNING, AFTERNOON or HOURS")
+}
+
+// LeaveRequestWindow returns the span covered by an existing partial-day leave request
+func LeaveRequestWindow(schedule models.WorkSchedule, leave models.LeaveRequest) (time.Time, time.Time, error) {
+	from, to, _, err := LeavePortionWindow(schedule, leave.StartDate, models.LeaveHours, leave.StartTime, leave.EndTime)
+	return from, to, err
+}
+
+// LateThreshold returns the time after which a check-in for the shift on the
+// given day is late. Partial-day leave that covers the start of the shift,
+// given earliest first, moves the threshold to the end of the leave, or to the
+// end of the break when the leave ends where the break starts.
+func LateThreshold(schedule models.WorkSchedule, shiftDate time.Time, partialLeaves []models.LeaveRequest) time.Time {
+	lateAfter := schedule.LateAfter(shiftDate)
+	for _, leave := range partialLeaves {
+		from, to, err := LeaveRequestWindow(schedule, leave)
+		if err != nil || from.After(lateAfter) {
+			continue
+		}
+		if leaveLateAfter := schedule.AddWorkingTime(shiftDate, to, time.Duration(schedule.GracePeriodMinutes)*time.Minute); leaveLateAfter.After(lateAfter) {
+			lateAfter = leaveLateAfter
+		}
+	}
+	return lateAfter
+}
+
+// GetApprovedPartialLeaves returns the user's approved partial-day leave on the given shift day, earliest first
+func GetApprovedPartialLeaves(db *gorm.DB, userID uint, day time.Time) ([]models.LeaveRequest, error) {
+	var leaves []models.LeaveRequest
+	err := db.Where("user_id = ? AND status IN ? AND day_portion != ? AND DATE(start_date) = ?",
+		userID, models.LeaveInEffectStatuses, models.LeaveFullDay, day.Format(CalendarDateFormat)).
+		Order("start_time ASC").
+		Find(&leaves).Error
+	return leaves, err
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"errors"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownLeaveType is returned when a leave type code is not defined or not active
+var ErrUnknownLeaveType = errors.New("unknown or inactive leave type")
+
+// GetLeaveTypeDefinition returns the definition of a leave type by its code
+func GetLeaveTypeDefinition(db *gorm.DB, code models.LeaveType) (models.LeaveTypeDefinition, error) {
+	var definition models.LeaveTypeDefinition
+	if err := db.Where("code = ?", code).First(&definition).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.LeaveTypeDefinition{}, ErrUnknownLeaveType
+		}
+		return models.LeaveTypeDefinition{}, err
+	}
+	return definition, nil
+}
+
+// GetActiveLeaveTypeDefinition returns the definition of a leave type that can still be requested
+func GetActiveLeaveTypeDefinition(db *gorm.DB, code models.LeaveType) (models.LeaveTypeDefinition, error) {
+	definition, err := GetLeaveTypeDefinition(db, code)
+	if err != nil {
+		return definition, err
+	}
+	if !definition.IsActive {
+		return models.LeaveTypeDefinition{}, ErrUnknownLeaveType
+	}
+	return definition, nil
+}
@@ -1,7 +1,13 @@
 package utils
 
 import (
+	"errors"
 	"math"
+	"strconv"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
 )
 
 // CalculateDistance calculates the distance between two points on Earth using the Haversine formula
@@ -27,3 +33,111 @@ func IsLocationValid(userLat, userLon, locationLat, locationLon float64, radiusM
 	distance := CalculateDistance(userLat, userLon, locationLat, locationLon)
 	return distance <= float64(radiusMeters)
 }
+
+// LocationMatch describes how a coordinate relates to a location
+type LocationMatch struct {
+	Location models.Location
+	// Distance in meters between the coordinate and the location center
+	Distance float64
+	// Within reports whether the coordinate is inside the location's geofence
+	Within bool
+}
+
+// locationGeofence returns the polygon geofence of a location, if it has a valid one
+func locationGeofence(location models.Location) ([]Polygon, bool) {
+	if len(location.Geometry) == 0 || string(location.Geometry) == "null" {
+		return nil, false
+	}
+	polygons, err := ParseGeofence(location.Geometry)
+	if err != nil {
+		return nil, false
+	}
+	return polygons, true
+}
+
+// FindNearestLocation returns the nearest location whose geofence (polygon or
+// radius) contains the coordinate. When none contains it, the closest location is returned with
+// Within set to false. It returns nil when no locations are given.
+func FindNearestLocation(lat, lon float64, locations []models.Location) *LocationMatch {
+	var nearestInside, nearest *LocationMatch
+	for _, location := range locations {
+		match := &LocationMatch{Location: location}
+		if polygons, ok := locationGeofence(location); ok {
+			// Polygon geofence: distance is measured to the nearest edge
+			match.Distance = DistanceToGeofence(lat, lon, polygons)
+			match.Within = match.Distance == 0
+		} else {
+			match.Distance = CalculateDistance(location.Latitude, location.Longitude, lat, lon)
+			match.Within = match.Distance <= float64(location.Radius)
+		}
+		distance := match.Distance
+
+		if match.Within && (nearestInside == nil || distance < nearestInside.Distance) {
+			nearestInside = match
+		}
+		if nearest == nil || distance < nearest.Distance {
+			nearest = match
+		}
+	}
+
+	if nearestInside != nil {
+		return nearestInside
+	}
+	return nearest
+}
+
+// GetDefaultLocation returns the location referenced by the default_location_id setting
+func GetDefaultLocation(db *gorm.DB) (models.Location, error) {
+	var setting models.Setting
+	if err := db.Where("`key` = ?", "default_location_id").First(&setting).Error; err != nil {
+		return models.Location{}, errors.New("default location not configured in settings")
+	}
+
+	locationID, err := strconv.ParseUint(setting.Value, 10, 32)
+	if err != nil {
+		return models.Location{}, errors.New("invalid location ID in settings")
+	}
+
+	var location models.Location
+	if err := db.First(&location, uint(locationID)).Error; err != nil {
+		return models.Location{}, errors.New("default location not found")
+	}
+	return location, nil
+}
+
+// GetUserLocations returns every location assigned to the user directly or
+// through their role. Users without any assignment fall back to the default
+// location from settings.
+func GetUserLocations(db *gorm.DB, userID uint) ([]models.Location, error) {
+	var user models.User
+	if err := db.Preload("Locations").Preload("Role.Locations").First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+
+	seen := make(map[uint]bool)
+	var locations []models.Location
+	for _, location := range user.Locations {
+		if !seen[location.ID] {
+			seen[location.ID] = true
+			locations = append(locations, location)
+		}
+	}
+	if user.Role != nil {
+		for _, location := range user.Role.Locations {
+			if !seen[location.ID] {
+				seen[location.ID] = true
+				locations = append(locations, location)
+			}
+		}
+	}
+
+	if len(locations) == 0 {
+		location, err := GetDefaultLocation(db)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, location)
+	}
+
+	return locations, nil
+}
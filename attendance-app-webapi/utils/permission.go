@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UserPermissions returns the set of permissions the user's role holds
+func UserPermissions(db *gorm.DB, userID uint) (map[string]bool, error) {
+	var names []string
+	if err := db.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN users ON users.role_id = role_permissions.role_id AND users.deleted_at IS NULL").
+		Where("users.id = ?", userID).
+		Pluck("permissions.name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+	return permissions, nil
+}
+
+// RolePermissions returns the set of permissions the role holds
+func RolePermissions(db *gorm.DB, roleID uint) (map[string]bool, error) {
+	var names []string
+	if err := db.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Where("role_permissions.role_id = ?", roleID).
+		Pluck("permissions.name", &names).Error; err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]bool, len(names))
+	for _, name := range names {
+		permissions[name] = true
+	}
+	return permissions, nil
+}
+
+// HoldsPermissions reports whether the user's role holds every one of the permissions
+func HoldsPermissions(db *gorm.DB, userID uint, permissions map[string]bool) (bool, error) {
+	granted, err := UserPermissions(db, userID)
+	if err != nil {
+		return false, err
+	}
+	for permission := range permissions {
+		if !granted[permission] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// HasPermission reports whether the user's role holds the permission
+func HasPermission(db *gorm.DB, userID uint, permission string) (bool, error) {
+	var count int64
+	err := db.Table("permissions").
+		Joins("JOIN role_permissions ON role_permissions.permission_id = permissions.id").
+		Joins("JOIN users ON users.role_id = role_permissions.role_id AND users.deleted_at IS NULL").
+		Where("users.id = ? AND permissions.name = ?", userID, permission).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GrantAllPermissions gives the role every permission, as admin roles hold
+func GrantAllPermissions(db *gorm.DB, role *models.Role) error {
+	var permissions []models.Permission
+	if err := db.Find(&permissions).Error; err != nil {
+		return err
+	}
+	if len(permissions) == 0 {
+		return nil
+	}
+	return db.Model(role).Association("Permissions").Append(&permissions)
+}
+
+// SyncPermissions creates the permissions of models.Permissions, updates
+// their descriptions and grants every permission to the admin roles
+func SyncPermissions(db *gorm.DB) error {
+	permissions := make([]models.Permission, len(models.Permissions))
+	copy(permissions, models.Permissions)
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"description"}),
+	}).Create(&permissions).Error; err != nil {
+		return err
+	}
+
+	var roles []models.Role
+	if err := db.Where("name = ?", models.RoleAdmin).Find(&roles).Error; err != nil {
+		return err
+	}
+	for i := range roles {
+		if err := GrantAllPermissions(db, &roles[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
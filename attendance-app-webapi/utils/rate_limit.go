@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// RateLimitRetention is how long rate limit events are kept; limits cannot use longer windows
+const RateLimitRetention = 24 * time.Hour
+
+// AllowRateLimited records an occurrence of the action identified by key and
+// reports whether it stays within limit occurrences per window. Rejected
+// occurrences are not recorded, so a blocked client is let through again once
+// the window has passed.
+func AllowRateLimited(db *gorm.DB, key string, limit int, window time.Duration) (bool, error) {
+	now := time.Now()
+
+	var count int64
+	if err := db.Model(&models.RateLimitEvent{}).
+		Where("`key` = ? AND created_at > ?", key, now.Add(-window)).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count >= int64(limit) {
+		return false, nil
+	}
+
+	if err := db.Create(&models.RateLimitEvent{Key: key, CreatedAt: now}).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PurgeRateLimitEvents removes the events older than RateLimitRetention
+func PurgeRateLimitEvents(db *gorm.DB, now time.Time) (int64, error) {
+	result := db.Where("created_at < ?", now.Add(-RateLimitRetention)).Delete(&models.RateLimitEvent{})
+	return result.RowsAffected, result.Error
+}
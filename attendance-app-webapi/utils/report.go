@@ -0,0 +1,193 @@
+package utils
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// MonthlySummary sums up a user's attendance and leave in one calendar month
+type MonthlySummary struct {
+	UserID   uint   `json:"userId" example:"5"`
+	Username string `json:"username" example:"john_doe"`
+	Name     string `json:"name" example:"John Doe"`
+	Year     int    `json:"year" example:"2025"`
+	Month    int    `json:"month" example:"10"`
+	// WorkingDays are the working days of the whole month by the user's
+	// schedule and the company calendar
+	WorkingDays int `json:"workingDays" example:"23"`
+	// PresentDays are the shifts the user checked in for, on time or late
+	PresentDays int `json:"presentDays" example:"20"`
+	OnTimeDays  int `json:"onTimeDays" example:"18"`
+	LateDays    int `json:"lateDays" example:"2"`
+	// LateMinutes is the time from the late threshold to the check-in, summed over the late days
+	LateMinutes   int `json:"lateMinutes" example:"35"`
+	Absences      int `json:"absences" example:"1"`
+	DidntCheckout int `json:"didntCheckout" example:"1"`
+	// HoursWorked is the time from check-in to check-out, summed over the shifts with a check-out
+	HoursWorked float64 `json:"hoursWorked" example:"161.5"`
+	// LeaveDays are the working days in the month covered by approved leave
+	LeaveDays       float64                      `json:"leaveDays" example:"2"`
+	LeaveDaysByType map[models.LeaveType]float64 `json:"leaveDaysByType"`
+	// ReportingPath is filled in for supervisors viewing their subordinates' summaries
+	ReportingPath []models.ReportingPathEntry `json:"reportingPath,omitempty"`
+}
+
+// MonthRange returns the first and the last day of the month
+func MonthRange(year int, month time.Month) (time.Time, time.Time) {
+	first := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+	return first, first.AddDate(0, 1, -1)
+}
+
+// GetMonthlySummaries computes the summary of the month for each of the
+// users, ordered by name, from their attendance records and approved leave
+// requests. Records count for the month their shift starts in.
+func GetMonthlySummaries(db *gorm.DB, userIDs []uint, year int, month time.Month) ([]MonthlySummary, error) {
+	summaries := []MonthlySummary{}
+	if len(userIDs) == 0 {
+		return summaries, nil
+	}
+	first, last := MonthRange(year, month)
+
+	var users []models.User
+	if err := WithWorkSchedules(db).Where("id IN ?", userIDs).Order("name ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	defaultSchedule, err := GetDefaultWorkSchedule(db)
+	if err != nil {
+		return nil, err
+	}
+
+	calendar, err := LoadWorkingCalendar(db, first, last)
+	if err != nil {
+		return nil, err
+	}
+
+	// Overnight shifts of the last day end in the next month
+	var attendances []models.Attendance
+	if err := db.Where("user_id IN ? AND check_in_time >= ? AND check_in_time < ?", userIDs, first, last.AddDate(0, 0, 2)).
+		Order("check_in_time ASC").
+		Find(&attendances).Error; err != nil {
+		return nil, err
+	}
+	attendancesByUser := make(map[uint][]models.Attendance)
+	for _, attendance := range attendances {
+		attendancesByUser[attendance.UserID] = append(attendancesByUser[attendance.UserID], attendance)
+	}
+
+	var leaves []models.LeaveRequest
+	if err := db.Where("user_id IN ? AND status IN ? AND DATE(start_date) <= ? AND DATE(end_date) >= ?",
+		userIDs, models.LeaveInEffectStatuses, last.Format(CalendarDateFormat), first.Format(CalendarDateFormat)).
+		Order("start_date ASC, start_time ASC").
+		Find(&leaves).Error; err != nil {
+		return nil, err
+	}
+	leavesByUser := make(map[uint][]models.LeaveRequest)
+	for _, leave := range leaves {
+		leavesByUser[leave.UserID] = append(leavesByUser[leave.UserID], leave)
+	}
+
+	for _, user := range users {
+		schedule := UserWorkSchedule(user, defaultSchedule)
+		summary := MonthlySummary{
+			UserID:          user.ID,
+			Username:        user.Username,
+			Name:            user.Name,
+			Year:            year,
+			Month:           int(month),
+			WorkingDays:     len(calendar.WorkingDays(schedule, first, last)),
+			LeaveDaysByType: make(map[models.LeaveType]float64),
+		}
+
+		// Leave days are counted on the current calendar, like the working days
+		partialLeaves := make(map[string][]models.LeaveRequest)
+		for _, leave := range leavesByUser[user.ID] {
+			var days float64
+			if leave.IsPartialDay() {
+				day := LocalDate(leave.StartDate)
+				if day.Before(first) || day.After(last) {
+					continue
+				}
+				key := day.Format(CalendarDateFormat)
+				partialLeaves[key] = append(partialLeaves[key], leave)
+				days = leave.Days
+			} else {
+				from, to := LocalDate(leave.StartDate), LocalDate(leave.EndDate)
+				if from.Before(first) {
+					from = first
+				}
+				if to.After(last) {
+					to = last
+				}
+				days = float64(len(calendar.WorkingDays(schedule, from, to)))
+			}
+			summary.LeaveDaysByType[leave.LeaveType] += days
+			summary.LeaveDays += days
+		}
+
+		var worked time.Duration
+		for _, attendance := range attendancesByUser[user.ID] {
+			checkIn := attendance.CheckInTime.In(time.Local)
+			shiftDate := schedule.ShiftDate(checkIn)
+			if shiftDate.Before(first) || shiftDate.After(last) {
+				continue
+			}
+
+			switch attendance.ValidationStatus {
+			case models.Absent:
+				summary.Absences++
+				continue
+			case models.Leave, models.Rejected:
+				// Full-day leave is counted from the leave requests, rejected records do not count
+				continue
+			case models.DidntCheckout:
+				summary.DidntCheckout++
+			}
+
+			summary.PresentDays++
+			if attendance.Status == models.Late {
+				summary.LateDays++
+				lateAfter := LateThreshold(schedule, shiftDate, partialLeaves[shiftDate.Format(CalendarDateFormat)])
+				// Arriving after the break of a split shift is not late by the length of the break
+				checkInMinute := checkIn.Truncate(time.Minute)
+				if late := checkInMinute.Sub(lateAfter) - schedule.BreakOverlap(shiftDate, lateAfter, checkInMinute); late > 0 {
+					summary.LateMinutes += int(late.Minutes())
+				}
+			} else {
+				summary.OnTimeDays++
+			}
+
+			if attendance.CheckOutTime != nil && attendance.CheckOutTime.After(checkIn) {
+				worked += attendance.CheckOutTime.Sub(checkIn) - schedule.BreakOverlap(shiftDate, checkIn, *attendance.CheckOutTime)
+			}
+		}
+
+		summary.HoursWorked = math.Round(worked.Hours()*100) / 100
+		summary.LeaveDays = roundDays(summary.LeaveDays)
+		for leaveType, days := range summary.LeaveDaysByType {
+			summary.LeaveDaysByType[leaveType] = roundDays(days)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+// SummaryLeaveTypes returns the leave types that occur in the summaries, sorted by code
+func SummaryLeaveTypes(summaries []MonthlySummary) []models.LeaveType {
+	seen := make(map[models.LeaveType]bool)
+	var leaveTypes []models.LeaveType
+	for _, summary := range summaries {
+		for leaveType := range summary.LeaveDaysByType {
+			if !seen[leaveType] {
+				seen[leaveType] = true
+				leaveTypes = append(leaveTypes, leaveType)
+			}
+		}
+	}
+	sort.Slice(leaveTypes, func(i, j int) bool { return leaveTypes[i] < leaveTypes[j] })
+	return leaveTypes
+}
@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"attendance-app/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// ReportingLines are the users below a supervisor, with the reporting path
+// from the supervisor, or the delegating supervisor, down to each user
+type ReportingLines struct {
+	UserIDs []uint
+	Paths   map[uint][]models.ReportingPathEntry
+}
+
+// Path returns the reporting path of the user, nil when the user is not below the supervisor
+func (l ReportingLines) Path(userID uint) []models.ReportingPathEntry {
+	return l.Paths[userID]
+}
+
+// ReportingDepthQuery parses the depth query parameter: the number of
+// reporting levels to include, or "all" for the whole reporting tree (0).
+// Direct reports only (1) when it is missing.
+func ReportingDepthQuery(c *gin.Context) (int, error) {
+	value := c.Query("depth")
+	if value == "" {
+		return 1, nil
+	}
+	if value == "all" {
+		return 0, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 1 {
+		return 0, errors.New("depth must be a positive number or all")
+	}
+	return depth, nil
+}
+
+// GetReportingLines returns the users below the user and below the
+// supervisors who currently delegate to them, up to maxDepth reporting
+// levels (0 for no limit), excluding the user themselves. Each user is
+// included once, on their shortest reporting path; cycles in the supervisor
+// links are not followed.
+func GetReportingLines(db *gorm.DB, userID uint, maxDepth int) (ReportingLines, error) {
+	lines := ReportingLines{UserIDs: []uint{}, Paths: make(map[uint][]models.ReportingPathEntry)}
+
+	delegatorIDs, err := GetDelegatorIDs(db, userID)
+	if err != nil {
+		return lines, err
+	}
+
+	// The user's own tree comes first so that paths start with them wherever
+	// the trees overlap
+	rootIDs := append([]uint{userID}, delegatorIDs...)
+	var roots []models.ReportingPathEntry
+	if err := db.Model(&models.User{}).Where("id IN ?", rootIDs).Select("id, name").Scan(&roots).Error; err != nil {
+		return lines, err
+	}
+	rootsByID := make(map[uint]models.ReportingPathEntry)
+	for _, root := range roots {
+		rootsByID[root.ID] = root
+	}
+	orderedRoots := make([]models.ReportingPathEntry, 0, len(rootIDs))
+	for _, rootID := range rootIDs {
+		if root, ok := rootsByID[rootID]; ok {
+			orderedRoots = append(orderedRoots, root)
+		}
+	}
+
+	return walkReportingLines(userID, orderedRoots, maxDepth, func(supervisorIDs []uint) ([]reportingRow, error) {
+		var rows []reportingRow
+		err := db.Model(&models.User{}).
+			Where("supervisor_id IN ?", supervisorIDs).
+			Select("id, name, supervisor_id").
+			Order("id ASC").
+			Scan(&rows).Error
+		return rows, err
+	})
+}
+
+// reportingRow is a user and the supervisor they report to
+type reportingRow struct {
+	ID           uint
+	Name         string
+	SupervisorID uint
+}
+
+// walkReportingLines walks the reporting trees below the roots breadth first,
+// one level per call of subordinates, which returns the users reporting to any
+// of the given supervisors ordered by ID
+func walkReportingLines(userID uint, roots []models.ReportingPathEntry, maxDepth int, subordinates func(supervisorIDs []uint) ([]reportingRow, error)) (ReportingLines, error) {
+	lines := ReportingLines{UserIDs: []uint{}, Paths: make(map[uint][]models.ReportingPathEntry)}
+
+	visited := map[uint]bool{userID: true}
+	for _, root := range roots {
+		// A delegating supervisor who reports to the user keeps their path below the user
+		rootPath := []models.ReportingPathEntry{root}
+		if path, ok := lines.Paths[root.ID]; ok {
+			rootPath = path
+		}
+		paths := map[uint][]models.ReportingPathEntry{root.ID: rootPath}
+		frontier := []uint{root.ID}
+		for depth := 1; len(frontier) > 0 && (maxDepth == 0 || depth <= maxDepth); depth++ {
+			rows, err := subordinates(frontier)
+			if err != nil {
+				return lines, err
+			}
+
+			frontier = nil
+			for _, row := range rows {
+				if visited[row.ID] {
+					continue
+				}
+				visited[row.ID] = true
+
+				parent := paths[row.SupervisorID]
+				path := make([]models.ReportingPathEntry, len(parent), len(parent)+1)
+				copy(path, parent)
+				path = append(path, models.ReportingPathEntry{ID: row.ID, Name: row.Name})
+
+				paths[row.ID] = path
+				lines.Paths[row.ID] = path
+				lines.UserIDs = append(lines.UserIDs, row.ID)
+				frontier = append(frontier, row.ID)
+			}
+		}
+	}
+	return lines, nil
+}
+
+// FormatReportingPath joins the names on a reporting path, e.g. "Ana > Budi > Citra"
+func FormatReportingPath(path []models.ReportingPathEntry) string {
+	names := make([]string, len(path))
+	for i, entry := range path {
+		names[i] = entry.Name
+	}
+	return strings.Join(names, " > ")
+}
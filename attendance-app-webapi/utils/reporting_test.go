@@ -0,0 +1,120 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"attendance-app/models"
+)
+
+// fakeOrg answers subordinate lookups from a user ID to supervisor ID map
+type fakeOrg struct {
+	names       map[uint]string
+	supervisors map[uint]uint
+	lookups     int
+}
+
+func (o *fakeOrg) subordinates(supervisorIDs []uint) ([]reportingRow, error) {
+	o.lookups++
+	wanted := make(map[uint]bool)
+	for _, id := range supervisorIDs {
+		wanted[id] = true
+	}
+	var rows []reportingRow
+	for id, supervisorID := range o.supervisors {
+		if wanted[supervisorID] {
+			rows = append(rows, reportingRow{ID: id, Name: o.names[id], SupervisorID: supervisorID})
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ID < rows[j].ID })
+	return rows, nil
+}
+
+func (o *fakeOrg) entry(id uint) models.ReportingPathEntry {
+	return models.ReportingPathEntry{ID: id, Name: o.names[id]}
+}
+
+func (o *fakeOrg) path(ids ...uint) []models.ReportingPathEntry {
+	path := make([]models.ReportingPathEntry, len(ids))
+	for i, id := range ids {
+		path[i] = o.entry(id)
+	}
+	return path
+}
+
+func newFakeOrg(supervisors map[uint]uint) *fakeOrg {
+	names := map[uint]string{1: "Ana", 2: "Budi", 3: "Citra", 4: "Dewi", 5: "Eko", 6: "Fajar"}
+	return &fakeOrg{names: names, supervisors: supervisors}
+}
+
+func TestWalkReportingLinesDepth(t *testing.T) {
+	// Ana > Budi > Citra > Dewi, and Ana > Eko
+	org := newFakeOrg(map[uint]uint{2: 1, 3: 2, 4: 3, 5: 1})
+
+	lines, err := walkReportingLines(1, []models.ReportingPathEntry{org.entry(1)}, 2, org.subordinates)
+	if err != nil {
+		t.Fatalf("walkReportingLines: %v", err)
+	}
+	if want := []uint{2, 5, 3}; !reflect.DeepEqual(lines.UserIDs, want) {
+		t.Errorf("UserIDs = %v, want %v", lines.UserIDs, want)
+	}
+	if got, want := lines.Path(3), org.path(1, 2, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("Path(3) = %v, want %v", got, want)
+	}
+	if lines.Path(4) != nil {
+		t.Errorf("Path(4) = %v, want nil beyond the depth limit", lines.Path(4))
+	}
+
+	lines, err = walkReportingLines(1, []models.ReportingPathEntry{org.entry(1)}, 0, org.subordinates)
+	if err != nil {
+		t.Fatalf("walkReportingLines: %v", err)
+	}
+	if want := []uint{2, 5, 3, 4}; !reflect.DeepEqual(lines.UserIDs, want) {
+		t.Errorf("UserIDs without a depth limit = %v, want %v", lines.UserIDs, want)
+	}
+}
+
+func TestWalkReportingLinesStopsAtCycles(t *testing.T) {
+	// Ana > Budi > Citra > Ana, with Dewi below Citra
+	org := newFakeOrg(map[uint]uint{1: 3, 2: 1, 3: 2, 4: 3})
+
+	lines, err := walkReportingLines(1, []models.ReportingPathEntry{org.entry(1)}, 0, org.subordinates)
+	if err != nil {
+		t.Fatalf("walkReportingLines: %v", err)
+	}
+	if want := []uint{2, 3, 4}; !reflect.DeepEqual(lines.UserIDs, want) {
+		t.Errorf("UserIDs = %v, want %v", lines.UserIDs, want)
+	}
+	if lines.Path(1) != nil {
+		t.Errorf("the user is listed below themselves: %v", lines.Path(1))
+	}
+	if got, want := lines.Path(4), org.path(1, 2, 3, 4); !reflect.DeepEqual(got, want) {
+		t.Errorf("Path(4) = %v, want %v", got, want)
+	}
+	// One lookup per level, plus the one that finds nothing new below Dewi
+	if org.lookups != 4 {
+		t.Errorf("lookups = %d, want 4", org.lookups)
+	}
+}
+
+func TestWalkReportingLinesDelegatedTrees(t *testing.T) {
+	// Ana > Budi > Citra, and Eko > Fajar; Budi and Eko delegate to Ana
+	org := newFakeOrg(map[uint]uint{2: 1, 3: 2, 6: 5})
+	roots := []models.ReportingPathEntry{org.entry(1), org.entry(2), org.entry(5)}
+
+	lines, err := walkReportingLines(1, roots, 0, org.subordinates)
+	if err != nil {
+		t.Fatalf("walkReportingLines: %v", err)
+	}
+	if want := []uint{2, 3, 6}; !reflect.DeepEqual(lines.UserIDs, want) {
+		t.Errorf("UserIDs = %v, want %v", lines.UserIDs, want)
+	}
+	// Citra keeps the path through Ana although Budi is also a root
+	if got, want := lines.Path(3), org.path(1, 2, 3); !reflect.DeepEqual(got, want) {
+		t.Errorf("Path(3) = %v, want %v", got, want)
+	}
+	if got, want := lines.Path(6), org.path(5, 6); !reflect.DeepEqual(got, want) {
+		t.Errorf("Path(6) = %v, want %v", got, want)
+	}
+}
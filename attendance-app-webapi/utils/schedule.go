@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"errors"
+
+	"attendance-app/models"
+
+	"gorm.io/gorm"
+)
+
+// GetUserWorkSchedule resolves the schedule that applies to a user.
+// Precedence: the user's own schedule, then the role's schedule, then the
+// schedule flagged as default, and finally the built-in office hours.
+func GetUserWorkSchedule(db *gorm.DB, userID uint) (models.WorkSchedule, error) {
+	var user models.User
+	if err := WithWorkSchedules(db).First(&user, userID).Error; err != nil {
+		return models.WorkSchedule{}, err
+	}
+
+	if user.WorkSchedule != nil {
+		return *user.WorkSchedule, nil
+	}
+	if user.Role != nil && user.Role.WorkSchedule != nil {
+		return *user.Role.WorkSchedule, nil
+	}
+
+	return GetDefaultWorkSchedule(db)
+}
+
+// WithWorkSchedules preloads the schedules UserWorkSchedule resolves from
+func WithWorkSchedules(db *gorm.DB) *gorm.DB {
+	return db.Preload("WorkSchedule").Preload("Role.WorkSchedule")
+}
+
+// UserWorkSchedule resolves the schedule of a user loaded WithWorkSchedules,
+// with the precedence of GetUserWorkSchedule. When working through many users,
+// load them at once and the default schedule with GetDefaultWorkSchedule once.
+func UserWorkSchedule(user models.User, defaultSchedule models.WorkSchedule) models.WorkSchedule {
+	if user.WorkSchedule != nil {
+		return *user.WorkSchedule
+	}
+	if user.Role != nil && user.Role.WorkSchedule != nil {
+		return *user.Role.WorkSchedule
+	}
+	return defaultSchedule
+}
+
+// GetDefaultWorkSchedule returns the schedule flagged as default, falling back
+// to the built-in office hours when none is configured.
+func GetDefaultWorkSchedule(db *gorm.DB) (models.WorkSchedule, error) {
+	var schedule models.WorkSchedule
+	if err := db.Where("is_default = ?", true).First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.DefaultWorkSchedule, nil
+		}
+		return models.WorkSchedule{}, err
+	}
+	return schedule, nil
+}
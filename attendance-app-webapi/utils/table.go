@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ReadTable reads the rows of a CSV file, or of the first sheet of an XLSX
+// file, depending on the file name's extension. Cells are trimmed. Empty
+// sheet rows are kept so that row numbers match the sheet; blank lines of CSV
+// files are skipped.
+func ReadTable(filename string, src io.Reader) ([][]string, error) {
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".csv":
+		reader := csv.NewReader(src)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		rows = records
+	case ".xlsx":
+		f, err := excelize.OpenReader(src)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		sheets := f.GetSheetList()
+		if len(sheets) == 0 {
+			return nil, errors.New("workbook has no sheets")
+		}
+		if rows, err = f.GetRows(sheets[0]); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("unsupported file type, use .csv or .xlsx")
+	}
+
+	for _, row := range rows {
+		for i := range row {
+			row[i] = strings.TrimSpace(row[i])
+		}
+	}
+	return rows, nil
+}
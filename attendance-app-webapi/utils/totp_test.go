@@ -0,0 +1,118 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 Appendix B, "12345678901234567890", in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists 8-digit codes; the last six digits are the 6-digit codes
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	key, err := totpEncoding.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatalf("decode secret: %v", err)
+	}
+
+	for _, v := range rfc6238Vectors {
+		counter := v.unix / int64(totpPeriod.Seconds())
+		if got := totpCode(key, counter); got != v.code {
+			t.Errorf("totpCode at %d = %s, want %s", v.unix, got, v.code)
+		}
+
+		got, ok := ValidateTOTP(rfc6238Secret, v.code, time.Unix(v.unix, 0), 0)
+		if !ok || got != counter {
+			t.Errorf("ValidateTOTP at %d = (%d, %v), want (%d, true)", v.unix, got, ok, counter)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	// 1111111109 is in period 37037036; 1111111111 in the next one
+	code := "081804"
+	counter := int64(37037036)
+	period := int64(totpPeriod.Seconds())
+
+	tests := []struct {
+		name string
+		at   int64
+		ok   bool
+	}{
+		{"same period", counter * period, true},
+		{"one period later", (counter + 1) * period, true},
+		{"one period earlier", (counter - 1) * period, true},
+		{"two periods later", (counter + 2) * period, false},
+		{"two periods earlier", (counter - 2) * period, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ValidateTOTP(rfc6238Secret, code, time.Unix(tt.at, 0), 0)
+			if ok != tt.ok {
+				t.Fatalf("ValidateTOTP ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && got != counter {
+				t.Errorf("ValidateTOTP counter = %d, want %d", got, counter)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111109, 0)
+	code := "081804"
+
+	counter, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+	if !ok {
+		t.Fatal("first use of the code was rejected")
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, code, now, counter); ok {
+		t.Error("code was accepted again after its period was used")
+	}
+	// A later period is still accepted once an earlier one was used
+	next := "050471"
+	if _, ok := ValidateTOTP(rfc6238Secret, next, time.Unix(1111111111, 0), counter); !ok {
+		t.Error("code of the next period was rejected")
+	}
+}
+
+func TestValidateTOTPRejectsMalformedInput(t *testing.T) {
+	now := time.Unix(59, 0)
+
+	tests := []struct {
+		name   string
+		secret string
+		code   string
+	}{
+		{"short code", rfc6238Secret, "28708"},
+		{"long code", rfc6238Secret, "2870820"},
+		{"wrong code", rfc6238Secret, "123456"},
+		{"invalid secret", "not base32!", "287082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := ValidateTOTP(tt.secret, tt.code, now, 0); ok {
+				t.Error("ValidateTOTP accepted the code")
+			}
+		})
+	}
+
+	// Surrounding whitespace and a lowercase secret are accepted
+	if _, ok := ValidateTOTP("gezdgnbvgy3tqojqgezdgnbvgy3tqojq", " 287082 ", now, 0); !ok {
+		t.Error("ValidateTOTP rejected a lowercase secret or a padded code")
+	}
+}